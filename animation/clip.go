@@ -0,0 +1,133 @@
+package animation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Direction is the way a character faces, sheets have a variant of a clip
+// for each of them
+type Direction string
+
+const (
+	Down  Direction = "down"
+	Up    Direction = "up"
+	Left  Direction = "left"
+	Right Direction = "right"
+)
+
+var Directions = []Direction{Down, Up, Left, Right}
+
+// Cell is a frame position on a sheet, in frames not pixels
+type Cell struct {
+	Column int
+	Row    int
+}
+
+type Frame struct {
+	Column   int
+	Row      int
+	Duration int    //ticks
+	Event    string //fired when the frame starts showing, empty for none
+}
+
+// Clip is one animation on a sheet. Directions move the frames by a number of
+// cells, a clip without them looks the same in every direction.
+type Clip struct {
+	Name       string
+	Sheet      string //image file, relative to the clips file
+	Loop       bool   //one shot clips stop on the last frame
+	Frames     []Frame
+	Directions map[Direction]Cell
+}
+
+// Cell is where the frame is on the sheet for the direction
+func (c *Clip) Cell(index int, direction Direction) Cell {
+	frame := c.Frames[index]
+	offset := c.Directions[direction]
+	return Cell{Column: frame.Column + offset.Column, Row: frame.Row + offset.Row}
+}
+
+// Set is all the clips of a character, the frames of all its sheets have the
+// same size
+type Set struct {
+	FrameWidth  int
+	FrameHeight int
+	Clips       map[string]*Clip
+}
+
+// Load reads a clips file, the sheet paths come back joined with the
+// directory of the file
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := struct {
+		FrameWidth  int
+		FrameHeight int
+		Clips       []Clip
+	}{}
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("Reading clips %s: %w", path, err)
+	}
+	if file.FrameWidth <= 0 || file.FrameHeight <= 0 {
+		return nil, fmt.Errorf("Clips %s have no frame size", path)
+	}
+
+	set := &Set{FrameWidth: file.FrameWidth, FrameHeight: file.FrameHeight, Clips: map[string]*Clip{}}
+	for _, clip := range file.Clips {
+		if _, ok := set.Clips[clip.Name]; ok {
+			return nil, fmt.Errorf("Clip %s is defined twice", clip.Name)
+		}
+		if clip.Sheet == "" {
+			return nil, fmt.Errorf("Clip %s has no sheet", clip.Name)
+		}
+		if len(clip.Frames) == 0 {
+			return nil, fmt.Errorf("Clip %s has no frames", clip.Name)
+		}
+		for i, frame := range clip.Frames {
+			if frame.Duration <= 0 {
+				return nil, fmt.Errorf("Frame %d of clip %s has no duration", i, clip.Name)
+			}
+		}
+		for direction := range clip.Directions {
+			if !validDirection(direction) {
+				return nil, fmt.Errorf("Clip %s has unknown direction %s", clip.Name, direction)
+			}
+		}
+		clip.Sheet = filepath.Join(filepath.Dir(path), clip.Sheet)
+		set.Clips[clip.Name] = &clip
+	}
+	return set, nil
+}
+
+func validDirection(direction Direction) bool {
+	for _, d := range Directions {
+		if d == direction {
+			return true
+		}
+	}
+	return false
+}
+
+// DirectionOf is the direction of a movement, the bigger axis wins. Without
+// movement it stays the current one.
+func DirectionOf(dx float64, dy float64, current Direction) Direction {
+	switch {
+	case dx == 0 && dy == 0:
+		return current
+	case math.Abs(dx) > math.Abs(dy) && dx > 0:
+		return Right
+	case math.Abs(dx) > math.Abs(dy):
+		return Left
+	case dy > 0:
+		return Down
+	default:
+		return Up
+	}
+}
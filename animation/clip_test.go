@@ -0,0 +1,95 @@
+package animation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClipCell(t *testing.T) {
+	clip := &Clip{
+		Frames:     []Frame{{Column: 0, Row: 0, Duration: 1}, {Column: 1, Row: 0, Duration: 1}},
+		Directions: map[Direction]Cell{Down: {}, Up: {Row: 1}, Left: {Row: 2}, Right: {Column: 4, Row: 2}},
+	}
+	cases := []struct {
+		index     int
+		direction Direction
+		want      Cell
+	}{
+		{0, Down, Cell{0, 0}},
+		{1, Down, Cell{1, 0}},
+		{1, Up, Cell{1, 1}},
+		{0, Left, Cell{0, 2}},
+		{1, Right, Cell{5, 2}},
+	}
+	for _, c := range cases {
+		if got := clip.Cell(c.index, c.direction); got != c.want {
+			t.Fatalf("Frame %d %s at %+v, want %+v", c.index, c.direction, got, c.want)
+		}
+	}
+
+	// without directions every one looks the same
+	clip.Directions = nil
+	if got := clip.Cell(1, Right); got != (Cell{1, 0}) {
+		t.Fatalf("Undirected frame at %+v", got)
+	}
+}
+
+func TestDirectionOf(t *testing.T) {
+	cases := []struct {
+		dx, dy  float64
+		current Direction
+		want    Direction
+	}{
+		{1, 0, Down, Right},
+		{-1, 0.5, Down, Left},
+		{0.2, 1, Up, Down},
+		{0.2, -1, Down, Up},
+		{0, 0, Left, Left},
+		// on a tie the vertical axis wins
+		{1, 1, Up, Down},
+		{-1, -1, Down, Up},
+		{1, -1, Down, Up},
+		{-1, 1, Up, Down},
+	}
+	for _, c := range cases {
+		if got := DirectionOf(c.dx, c.dy, c.current); got != c.want {
+			t.Fatalf("%v,%v from %s gives %s, want %s", c.dx, c.dy, c.current, got, c.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join("..", "assets", "images", "Shaman", "SeparateAnim", "clips.json")
+	set, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Clips) == 0 {
+		t.Fatal("No clips")
+	}
+	for name, clip := range set.Clips {
+		if filepath.Dir(clip.Sheet) != filepath.Dir(path) {
+			t.Fatalf("Clip %s sheet %s not next to the clips file", name, clip.Sheet)
+		}
+	}
+
+	broken := map[string]string{
+		"frame size":  `{"Clips": []}`,
+		"twice":       `{"FrameWidth": 8, "FrameHeight": 8, "Clips": [{"Name": "a", "Sheet": "a.png", "Frames": [{"Duration": 1}]}, {"Name": "a", "Sheet": "a.png", "Frames": [{"Duration": 1}]}]}`,
+		"no sheet":    `{"FrameWidth": 8, "FrameHeight": 8, "Clips": [{"Name": "a", "Frames": [{"Duration": 1}]}]}`,
+		"no frames":   `{"FrameWidth": 8, "FrameHeight": 8, "Clips": [{"Name": "a", "Sheet": "a.png"}]}`,
+		"no duration": `{"FrameWidth": 8, "FrameHeight": 8, "Clips": [{"Name": "a", "Sheet": "a.png", "Frames": [{"Duration": 1}, {}]}]}`,
+		"direction":   `{"FrameWidth": 8, "FrameHeight": 8, "Clips": [{"Name": "a", "Sheet": "a.png", "Frames": [{"Duration": 1}], "Directions": {"north": {}}}]}`,
+		"json":        `{`,
+	}
+	for name, data := range broken {
+		path := filepath.Join(t.TempDir(), "clips.json")
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+	}
+}
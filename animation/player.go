@@ -0,0 +1,77 @@
+package animation
+
+// Player plays one clip at a time, it is advanced by a tick in Update
+type Player struct {
+	Clip      *Clip
+	Direction Direction
+	Index     int //frame of the clip
+	ticks     int //ticks the frame has been shown
+	started   bool
+	done      bool
+}
+
+// Play switches to the clip, playing the same clip again doesnt restart it
+func (p *Player) Play(clip *Clip) {
+	if p.Clip == clip {
+		return
+	}
+	p.Restart(clip)
+}
+
+// Restart plays the clip from the first frame
+func (p *Player) Restart(clip *Clip) {
+	p.Clip = clip
+	p.Index = 0
+	p.ticks = 0
+	p.started = false
+	p.done = false
+}
+
+// Done is true when a one shot clip showed its last frame for its duration
+func (p *Player) Done() bool {
+	return p.done
+}
+
+// Update advances the clip by a tick and returns the events of the frames
+// that started showing, the first frame counts on the first update
+func (p *Player) Update() []string {
+	if p.Clip == nil {
+		return nil
+	}
+	var events []string
+	if !p.started {
+		p.started = true
+		events = p.event(events)
+	}
+	if p.done {
+		return events
+	}
+
+	p.ticks++
+	if p.ticks < p.Clip.Frames[p.Index].Duration {
+		return events
+	}
+	p.ticks = 0
+	switch {
+	case p.Index < len(p.Clip.Frames)-1:
+		p.Index++
+	case p.Clip.Loop:
+		p.Index = 0
+	default:
+		p.done = true
+		return events
+	}
+	return p.event(events)
+}
+
+func (p *Player) event(events []string) []string {
+	if event := p.Clip.Frames[p.Index].Event; event != "" {
+		events = append(events, event)
+	}
+	return events
+}
+
+// Cell is where the current frame is on the sheet
+func (p *Player) Cell() Cell {
+	return p.Clip.Cell(p.Index, p.Direction)
+}
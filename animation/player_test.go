@@ -0,0 +1,106 @@
+package animation
+
+import (
+	"reflect"
+	"testing"
+)
+
+// attack is a one shot clip that lands its hit on the second frame
+var attack = &Clip{
+	Name: "attack",
+	Frames: []Frame{
+		{Column: 0, Duration: 2, Event: "swing"},
+		{Column: 1, Duration: 3, Event: "hit"},
+		{Column: 2, Duration: 1},
+	},
+	Directions: map[Direction]Cell{Up: {Row: 1}},
+}
+
+// play updates the player for the ticks and gives the frame and events of
+// each of them
+func play(p *Player, ticks int) ([]int, [][]string) {
+	frames := []int{}
+	events := [][]string{}
+	for i := 0; i < ticks; i++ {
+		events = append(events, p.Update())
+		frames = append(frames, p.Index)
+	}
+	return frames, events
+}
+
+func TestPlayerDurations(t *testing.T) {
+	p := &Player{}
+	p.Play(attack)
+	frames, events := play(p, 8)
+	if want := []int{0, 1, 1, 1, 2, 2, 2, 2}; !reflect.DeepEqual(frames, want) {
+		t.Fatalf("Frames %v, want %v", frames, want)
+	}
+	// the first frame fires on the first update
+	want := [][]string{{"swing"}, {"hit"}, nil, nil, nil, nil, nil, nil}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("Events %v, want %v", events, want)
+	}
+}
+
+func TestPlayerOneShotDone(t *testing.T) {
+	p := &Player{}
+	p.Play(attack)
+	for i := 0; i < 5; i++ {
+		p.Update()
+		if p.Done() {
+			t.Fatalf("Done after %d ticks", i+1)
+		}
+	}
+	// the last frame still shows for its duration
+	p.Update()
+	if !p.Done() || p.Index != 2 {
+		t.Fatalf("Done %v on frame %d", p.Done(), p.Index)
+	}
+	if events := p.Update(); events != nil || p.Index != 2 {
+		t.Fatalf("Finished clip gave %v on frame %d", events, p.Index)
+	}
+
+	// playing it again keeps it finished, restarting doesnt
+	p.Play(attack)
+	if !p.Done() {
+		t.Fatal("Play restarted the same clip")
+	}
+	p.Restart(attack)
+	if p.Done() || p.Index != 0 {
+		t.Fatal("Restart kept the clip finished")
+	}
+	if events := p.Update(); !reflect.DeepEqual(events, []string{"swing"}) {
+		t.Fatalf("Restarted clip gave %v", events)
+	}
+}
+
+func TestPlayerLoop(t *testing.T) {
+	walk := &Clip{Name: "walk", Loop: true, Frames: []Frame{{Duration: 2, Event: "step"}, {Column: 1, Duration: 1}}}
+	p := &Player{}
+	p.Play(walk)
+	frames, events := play(p, 7)
+	if want := []int{0, 1, 0, 0, 1, 0, 0}; !reflect.DeepEqual(frames, want) {
+		t.Fatalf("Frames %v, want %v", frames, want)
+	}
+	steps := 0
+	for _, e := range events {
+		steps += len(e)
+	}
+	if steps != 3 || p.Done() {
+		t.Fatalf("%d steps, done %v", steps, p.Done())
+	}
+}
+
+func TestPlayerCell(t *testing.T) {
+	p := &Player{Direction: Up}
+	p.Play(attack)
+	p.Update()
+	p.Update()
+	if got := p.Cell(); got != (Cell{Column: 1, Row: 1}) {
+		t.Fatalf("Cell %+v", got)
+	}
+	p.Direction = Down
+	if got := p.Cell(); got != (Cell{Column: 1}) {
+		t.Fatalf("Cell %+v facing down", got)
+	}
+}
@@ -22,14 +22,14 @@ type AudioAssets struct {
 
 type VideoAssets struct {
 	Images    map[string]map[string]*ebiten.Image //tileset type(floors) => tileset name()TilesetFloor => image
-	Tilecashe map[int]*ebiten.Image
+	Tilecashe map[string]map[int]*ebiten.Image    //tileset name => local tile id => image
 }
 
 func InitAssets() (*Assets, error) {
 	assets := &Assets{
 		Video: VideoAssets{
 			Images:    map[string]map[string]*ebiten.Image{},
-			Tilecashe: map[int]*ebiten.Image{},
+			Tilecashe: map[string]map[int]*ebiten.Image{},
 		},
 	}
 	err := LoadAllAssets(assets)
@@ -80,16 +80,40 @@ func LoadAllAssets(assets *Assets) error {
 func (a *Assets) GetImage(setname string, filename string) *ebiten.Image {
 	return a.Video.Images[setname][filename]
 }
-func (a *Assets) GetTileImage(setname string, filename string, columns int, tileid int) *ebiten.Image {
-	//TODO make a cashe tileid => image
-	// return cashe[tileid]
-	tile, ok := a.Video.Tilecashe[tileid]
+
+// GetTilesetTile returns the image of a tile by its local id, works for both
+// tilesets made from one image and image collections
+func (a *Assets) GetTilesetTile(tileset *TilesetData, id int) *ebiten.Image {
+	cashe, ok := a.Video.Tilecashe[tileset.Name]
+	if !ok {
+		cashe = map[int]*ebiten.Image{}
+		a.Video.Tilecashe[tileset.Name] = cashe
+	}
+	tile, ok := cashe[id]
 	if ok {
 		return tile
 	}
-	x0 := ((tileid - 1) % columns) * 16
-	y0 := ((tileid - 1) / columns) * 16
 
-	a.Video.Tilecashe[tileid] = a.Video.Images[setname][filename].SubImage(image.Rect(x0, y0, x0+config.TileSize, y0+config.TileSize)).(*ebiten.Image)
-	return a.Video.Tilecashe[tileid]
+	if !tileset.TilesImage {
+		data := tileset.Tile(id)
+		if data == nil {
+			return nil
+		}
+		cashe[id] = a.GetImage(tileset.Name, data.Image)
+		return cashe[id]
+	}
+
+	source := a.GetImage(tileset.Name, tileset.Image)
+	if source == nil || tileset.Columns == 0 {
+		return nil
+	}
+	tilew, tileh := tileset.TileWidth, tileset.TileHeight
+	if tilew == 0 || tileh == 0 {
+		tilew, tileh = config.TileSize, config.TileSize
+	}
+	x0 := tileset.Margin + (id%tileset.Columns)*(tilew+tileset.Spacing)
+	y0 := tileset.Margin + (id/tileset.Columns)*(tileh+tileset.Spacing)
+
+	cashe[id] = source.SubImage(image.Rect(x0, y0, x0+tilew, y0+tileh)).(*ebiten.Image)
+	return cashe[id]
 }
@@ -2,6 +2,7 @@ package assets
 
 import (
 	"encoding/json"
+	"math"
 	"os"
 )
 
@@ -23,13 +24,91 @@ type TilesetData struct {
 	Image      string     `json:"image"`
 	Columns    int        `json:"columns"`
 	Name       string     `json:"name"`
+	TileWidth  int        `json:"tilewidth"`
+	TileHeight int        `json:"tileheight"`
+	Margin     int        `json:"margin"`
+	Spacing    int        `json:"spacing"`
 	Tiles      []TileData `json:"tiles"`
+	tilesByID  map[int]*TileData
 }
+
+// Tile returns the per tile data by the local tile ID, nil if the tileset has
+// nothing about the tile
+func (t *TilesetData) Tile(id int) *TileData {
+	if t.tilesByID == nil {
+		t.tilesByID = map[int]*TileData{}
+		for i := range t.Tiles {
+			t.tilesByID[t.Tiles[i].ID] = &t.Tiles[i]
+		}
+	}
+	return t.tilesByID[id]
+}
+
 type TileData struct {
-	ID          int    `json:"id"`
-	Image       string `json:"image"`
-	ImageHeight int    `json:"imageheight"`
-	ImageWidth  int    `json:"imagewidth"`
+	ID          int          `json:"id"`
+	Image       string       `json:"image"`
+	ImageHeight int          `json:"imageheight"`
+	ImageWidth  int          `json:"imagewidth"`
+	Properties  []Property   `json:"properties"`
+	ObjectGroup *ObjectGroup `json:"objectgroup"`
+}
+
+// Property is a Tiled custom property, Value is whatever json gave us
+// (string, float64, bool)
+type Property struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+func FindProperty(properties []Property, name string) (Property, bool) {
+	for _, property := range properties {
+		if property.Name == name {
+			return property, true
+		}
+	}
+	return Property{}, false
+}
+
+func PropertyString(properties []Property, name string) (string, bool) {
+	property, ok := FindProperty(properties, name)
+	if !ok {
+		return "", false
+	}
+	value, ok := property.Value.(string)
+	return value, ok
+}
+
+func PropertyFloat(properties []Property, name string) (float64, bool) {
+	property, ok := FindProperty(properties, name)
+	if !ok {
+		return 0, false
+	}
+	value, ok := property.Value.(float64)
+	return value, ok
+}
+
+// PropertyInt reads a property of the int type, json gives its value as a
+// float
+func PropertyInt(properties []Property, name string) (int, bool) {
+	property, ok := FindProperty(properties, name)
+	if !ok || property.Type != "int" {
+		return 0, false
+	}
+	value, ok := property.Value.(float64)
+	if !ok || value != math.Trunc(value) {
+		return 0, false
+	}
+	return int(value), true
+}
+
+func PropertyBool(properties []Property, name string) (bool, bool) {
+	property, ok := FindProperty(properties, name)
+	if !ok {
+		return false, false
+	}
+	value, ok := property.Value.(bool)
+	return value, ok
 }
 
 func InitTilemap() Tilemap {
@@ -54,27 +133,58 @@ func LoadTilemap(id string) (*Tilemap, error) {
 }
 
 type TilemapLayer struct {
-	ID      int      `json:"id"` //TODO dont know if needed
-	Name    string   `json:"name"`
-	Opacity float64  `json:"opacity"` //TODO not using right now, could be interesting
-	Type    string   `json:"type"`
-	Visible bool     `json:"visible"`
-	Data    []int    `json:"data"`
-	Objects []Object `json:"objects"`
-	Width   int      `json:"width"`
-	Height  int      `json:"height"`
+	ID         int            `json:"id"` //TODO dont know if needed
+	Name       string         `json:"name"`
+	Opacity    float64        `json:"opacity"`
+	Type       string         `json:"type"` //tilelayer, objectgroup, group
+	Visible    bool           `json:"visible"`
+	Data       []uint32       `json:"data"` //gids with the flip flags
+	Objects    []Object       `json:"objects"`
+	Layers     []TilemapLayer `json:"layers"` //children of a group
+	Width      int            `json:"width"`
+	Height     int            `json:"height"`
+	Properties []Property     `json:"properties"`
+}
+
+// Tiled stores flipping in the highest bits of the global tile IDs
+const (
+	FlipHorizontal uint32 = 0x80000000
+	FlipVertical   uint32 = 0x40000000
+	FlipDiagonal   uint32 = 0x20000000
+	flipMask              = FlipHorizontal | FlipVertical | FlipDiagonal | 0x10000000
+)
+
+// SplitGID returns the global tile ID without the flip flags and the flags
+func SplitGID(raw uint32) (int, uint32) {
+	return int(raw &^ flipMask), raw & flipMask
 }
 
 type Object struct {
-	GID      int     `json:"gid"`
-	ID       int     `json:"id"`
-	X        int     `json:"x"`
-	Y        int     `json:"y"`
-	Width    int     `json:"Width"`
-	Height   int     `json:"Height"`
-	Rotation float64 `json:"rotation"`
-	Visible  bool    `json:"visible"`
-	Name     string
+	GID        uint32        `json:"gid"` //with the flip flags, see SplitGID
+	ID         int           `json:"id"`
+	X          float64       `json:"x"`
+	Y          float64       `json:"y"`
+	Width      float64       `json:"Width"`
+	Height     float64       `json:"Height"`
+	Rotation   float64       `json:"rotation"` //degrees, clockwise
+	Visible    bool          `json:"visible"`
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Ellipse    bool          `json:"ellipse"`
+	Point      bool          `json:"point"`
+	Polygon    []ObjectPoint `json:"polygon"`  //relative to X, Y
+	Polyline   []ObjectPoint `json:"polyline"` //relative to X, Y
+	Properties []Property    `json:"properties"`
+}
+
+type ObjectPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ObjectGroup is used by tilesets for the per tile collision shapes
+type ObjectGroup struct {
+	Objects []Object `json:"objects"`
 }
 
 /*
@@ -0,0 +1,72 @@
+package combat
+
+// Action is one step of a computer controlled turn, Path is set for moves and
+// Target for attacks
+type Action struct {
+	Path   []Tile
+	Target *Combatant
+	Attack Attack
+	Result AttackResult
+}
+
+// AIStep does the next thing the current combatant wants to do, false when it
+// is done and the turn should end. It attacks the closest enemy it can and
+// walks towards it otherwise.
+func (e *Encounter) AIStep(m Map) (Action, bool) {
+	c := e.Current()
+	if c == nil || e.Over() {
+		return Action{}, false
+	}
+	target := e.closestEnemy(c)
+	if target == nil {
+		return Action{}, false
+	}
+
+	for _, attack := range c.Attacks {
+		if e.CanAttack(m, c, target, attack) == nil {
+			result, _ := e.Attack(m, target, attack)
+			return Action{Target: target, Attack: attack, Result: result}, true
+		}
+	}
+
+	// the reachable tile closest to the target, ties go to the cheaper one
+	best := c.Tile
+	bestDistance := Distance(c.Tile, target.Tile)
+	bestCost := 0
+	for tile, cost := range e.MovementRange(m, c) {
+		distance := Distance(tile, target.Tile)
+		if distance < bestDistance || (distance == bestDistance && (cost < bestCost || (cost == bestCost && tileLess(tile, best)))) {
+			best, bestDistance, bestCost = tile, distance, cost
+		}
+	}
+	if best == c.Tile {
+		return Action{}, false
+	}
+	path, err := e.Move(m, best)
+	if err != nil {
+		return Action{}, false
+	}
+	return Action{Path: path}, true
+}
+
+func (e *Encounter) closestEnemy(c *Combatant) *Combatant {
+	var closest *Combatant
+	for _, other := range e.Combatants {
+		if other.Team == c.Team || !other.Alive() {
+			continue
+		}
+		if closest == nil || Distance(c.Tile, other.Tile) < Distance(c.Tile, closest.Tile) ||
+			(Distance(c.Tile, other.Tile) == Distance(c.Tile, closest.Tile) && other.ID < closest.ID) {
+			closest = other
+		}
+	}
+	return closest
+}
+
+// tileLess orders tiles so map iteration order doesnt change the result
+func tileLess(a Tile, b Tile) bool {
+	if a.Y != b.Y {
+		return a.Y < b.Y
+	}
+	return a.X < b.X
+}
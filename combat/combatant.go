@@ -0,0 +1,75 @@
+package combat
+
+// Tile is a position on the level grid
+type Tile struct {
+	X, Y int
+}
+
+// Attack is something a combatant can do to another one
+type Attack struct {
+	Name      string
+	Range     int //in tiles, 1 is melee
+	APCost    int
+	MinDamage int
+	MaxDamage int
+	HitChance float64 //at point blank, 0-1
+}
+
+func (a Attack) Ranged() bool {
+	return a.Range > 1
+}
+
+var (
+	Melee = Attack{
+		Name:      "melee",
+		Range:     1,
+		APCost:    2,
+		MinDamage: 3,
+		MaxDamage: 6,
+		HitChance: 0.8,
+	}
+	Ranged = Attack{
+		Name:      "ranged",
+		Range:     6,
+		APCost:    3,
+		MinDamage: 2,
+		MaxDamage: 4,
+		HitChance: 0.7,
+	}
+)
+
+// AttacksByName are the attacks classes can list in their data files
+var AttacksByName = map[string]Attack{
+	Melee.Name:  Melee,
+	Ranged.Name: Ranged,
+}
+
+// Combatant is one side of a fight, ID has to be unique in the encounter
+type Combatant struct {
+	ID         string
+	Team       int
+	Tile       Tile
+	HP         int
+	MaxHP      int
+	AP         int
+	MaxAP      int
+	Initiative int //bonus added to the initiative roll
+	Armour     int //taken away from the damage of every hit
+	Attacks    []Attack
+	// set by the AI for computer controlled combatants, the player moves the
+	// others
+	AI bool
+}
+
+func (c *Combatant) Alive() bool {
+	return c.HP > 0
+}
+
+func (c *Combatant) AttackByName(name string) (Attack, bool) {
+	for _, attack := range c.Attacks {
+		if attack.Name == name {
+			return attack, true
+		}
+	}
+	return Attack{}, false
+}
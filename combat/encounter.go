@@ -0,0 +1,303 @@
+// Package combat has the rules of the turn based fights, it knows nothing
+// about drawing so it can run without a window
+package combat
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Map is what combat needs to know about the level
+type Map interface {
+	Walkable(x, y int) bool
+	LineOfSight(from Tile, to Tile) bool
+}
+
+const (
+	initiativeDie = 20
+	// hit chance lost for every tile of distance after the first one
+	rangePenalty = 0.05
+	minHitChance = 0.05
+	maxHitChance = 0.95
+	// NoTeam is the winner while the encounter is still going
+	NoTeam = -1
+)
+
+var neighborTiles = []Tile{
+	{-1, -1}, {0, -1}, {1, -1},
+	{-1, 0}, {1, 0},
+	{-1, 1}, {0, 1}, {1, 1},
+}
+
+type Encounter struct {
+	Combatants []*Combatant
+	Order      []*Combatant //initiative order
+	Turn       int          //index into Order
+	Round      int
+	Log        []string
+	rng        *rand.Rand
+}
+
+// NewEncounter rolls the initiative, the same seed gives the same fight
+func NewEncounter(combatants []*Combatant, seed int64) (*Encounter, error) {
+	ids := map[string]bool{}
+	for _, c := range combatants {
+		if ids[c.ID] {
+			return nil, fmt.Errorf("Combatant %s is in the encounter twice", c.ID)
+		}
+		ids[c.ID] = true
+	}
+
+	e := &Encounter{
+		Combatants: combatants,
+		Round:      1,
+		rng:        rand.New(rand.NewSource(seed)),
+	}
+	rolls := map[*Combatant]int{}
+	for _, c := range combatants {
+		rolls[c] = e.rng.Intn(initiativeDie) + 1 + c.Initiative
+		e.Order = append(e.Order, c)
+	}
+	sort.SliceStable(e.Order, func(i, j int) bool {
+		a, b := e.Order[i], e.Order[j]
+		if rolls[a] != rolls[b] {
+			return rolls[a] > rolls[b]
+		}
+		return a.ID < b.ID
+	})
+
+	e.Turn = -1
+	e.EndTurn()
+	return e, nil
+}
+
+// Current is the combatant whose turn it is
+func (e *Encounter) Current() *Combatant {
+	if e.Turn < 0 || e.Turn >= len(e.Order) {
+		return nil
+	}
+	return e.Order[e.Turn]
+}
+
+// EndTurn gives the turn to the next living combatant and refills its AP
+func (e *Encounter) EndTurn() {
+	if e.Over() {
+		return
+	}
+	// Over makes sure somebody is alive, one lap is enough to find them
+	for range e.Order {
+		e.Turn++
+		if e.Turn >= len(e.Order) {
+			e.Turn = 0
+			e.Round++
+		}
+		current := e.Order[e.Turn]
+		if current.Alive() {
+			current.AP = current.MaxAP
+			return
+		}
+	}
+}
+
+// Winner is the last team standing, NoTeam while more teams are alive and
+// when nobody is
+func (e *Encounter) Winner() int {
+	winner, _ := e.standing()
+	return winner
+}
+
+// Over is true when at most one team is left, a fight where everybody went
+// down is over without a winner
+func (e *Encounter) Over() bool {
+	_, teams := e.standing()
+	return teams <= 1
+}
+
+// standing returns the team of the living combatants and how many teams are
+// alive, it stops counting at two
+func (e *Encounter) standing() (int, int) {
+	winner := NoTeam
+	for _, c := range e.Combatants {
+		if !c.Alive() {
+			continue
+		}
+		if winner != NoTeam && winner != c.Team {
+			return NoTeam, 2
+		}
+		winner = c.Team
+	}
+	if winner == NoTeam {
+		return NoTeam, 0
+	}
+	return winner, 1
+}
+
+func (e *Encounter) occupant(tile Tile) *Combatant {
+	for _, c := range e.Combatants {
+		if c.Alive() && c.Tile == tile {
+			return c
+		}
+	}
+	return nil
+}
+
+// MovementRange returns every tile the combatant can walk to with its AP and
+// what it costs, one AP per step
+func (e *Encounter) MovementRange(m Map, c *Combatant) map[Tile]int {
+	costs := map[Tile]int{c.Tile: 0}
+	frontier := []Tile{c.Tile}
+	for step := 1; step <= c.AP && len(frontier) > 0; step++ {
+		next := []Tile{}
+		for _, tile := range frontier {
+			for _, offset := range neighborTiles {
+				neighbor := Tile{X: tile.X + offset.X, Y: tile.Y + offset.Y}
+				if _, seen := costs[neighbor]; seen {
+					continue
+				}
+				if !m.Walkable(neighbor.X, neighbor.Y) || e.occupant(neighbor) != nil {
+					continue
+				}
+				// no cutting corners around walls
+				if offset.X != 0 && offset.Y != 0 && (!m.Walkable(tile.X+offset.X, tile.Y) || !m.Walkable(tile.X, tile.Y+offset.Y)) {
+					continue
+				}
+				costs[neighbor] = step
+				next = append(next, neighbor)
+			}
+		}
+		frontier = next
+	}
+	return costs
+}
+
+// PathTo is the shortest path inside of the movement range, without the start
+func (e *Encounter) PathTo(m Map, c *Combatant, to Tile) ([]Tile, bool) {
+	costs := e.MovementRange(m, c)
+	cost, ok := costs[to]
+	if !ok || cost == 0 {
+		return nil, false
+	}
+	// walk back down the costs, neighbors are checked in a fixed order
+	path := make([]Tile, cost)
+	current := to
+	for step := cost; step > 0; step-- {
+		path[step-1] = current
+		for _, offset := range neighborTiles {
+			previous := Tile{X: current.X + offset.X, Y: current.Y + offset.Y}
+			if prevCost, ok := costs[previous]; ok && prevCost == step-1 && adjacentStep(m, previous, current) {
+				current = previous
+				break
+			}
+		}
+	}
+	return path, true
+}
+
+func adjacentStep(m Map, from Tile, to Tile) bool {
+	dx, dy := to.X-from.X, to.Y-from.Y
+	if dx == 0 || dy == 0 {
+		return true
+	}
+	return m.Walkable(from.X+dx, from.Y) && m.Walkable(from.X, from.Y+dy)
+}
+
+// Move walks the current combatant to the tile and returns the path it took
+func (e *Encounter) Move(m Map, to Tile) ([]Tile, error) {
+	c := e.Current()
+	if c == nil || e.Over() {
+		return nil, fmt.Errorf("Encounter is over")
+	}
+	path, ok := e.PathTo(m, c, to)
+	if !ok {
+		return nil, fmt.Errorf("%s cant reach %d,%d", c.ID, to.X, to.Y)
+	}
+	c.AP -= len(path)
+	c.Tile = to
+	e.logf("%s moves to %d,%d", c.ID, to.X, to.Y)
+	return path, nil
+}
+
+// Distance in tiles, diagonal steps count as one like when walking
+func Distance(a Tile, b Tile) int {
+	return max(abs(a.X-b.X), abs(a.Y-b.Y))
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// HitChance is the chance of the attack hitting the target from where the
+// attacker stands
+func HitChance(attacker *Combatant, target *Combatant, attack Attack) float64 {
+	distance := Distance(attacker.Tile, target.Tile)
+	chance := attack.HitChance - rangePenalty*float64(max(distance-1, 0))
+	return math.Max(minHitChance, math.Min(maxHitChance, chance))
+}
+
+// CanAttack tells why the attack cant be done, nil when it can
+func (e *Encounter) CanAttack(m Map, attacker *Combatant, target *Combatant, attack Attack) error {
+	if e.Over() {
+		return fmt.Errorf("Encounter is over")
+	}
+	if !target.Alive() {
+		return fmt.Errorf("%s is already down", target.ID)
+	}
+	if attacker.Team == target.Team {
+		return fmt.Errorf("%s wont attack a friend", attacker.ID)
+	}
+	if attacker.AP < attack.APCost {
+		return fmt.Errorf("%s needs %d AP for %s", attacker.ID, attack.APCost, attack.Name)
+	}
+	distance := Distance(attacker.Tile, target.Tile)
+	if distance > attack.Range {
+		return fmt.Errorf("%s is out of range", target.ID)
+	}
+	if attack.Ranged() && !m.LineOfSight(attacker.Tile, target.Tile) {
+		return fmt.Errorf("%s cant see %s", attacker.ID, target.ID)
+	}
+	return nil
+}
+
+type AttackResult struct {
+	Hit    bool
+	Damage int
+	Killed bool
+}
+
+// Attack makes the current combatant attack the target
+func (e *Encounter) Attack(m Map, target *Combatant, attack Attack) (AttackResult, error) {
+	attacker := e.Current()
+	if attacker == nil {
+		return AttackResult{}, fmt.Errorf("Encounter has no combatants")
+	}
+	err := e.CanAttack(m, attacker, target, attack)
+	if err != nil {
+		return AttackResult{}, err
+	}
+
+	attacker.AP -= attack.APCost
+	result := AttackResult{}
+	if e.rng.Float64() >= HitChance(attacker, target, attack) {
+		e.logf("%s misses %s", attacker.ID, target.ID)
+		return result, nil
+	}
+	result.Hit = true
+	// a hit always does at least one damage
+	result.Damage = max(attack.MinDamage+e.rng.Intn(attack.MaxDamage-attack.MinDamage+1)-target.Armour, 1)
+	target.HP = max(target.HP-result.Damage, 0)
+	result.Killed = !target.Alive()
+	e.logf("%s hits %s with %s for %d", attacker.ID, target.ID, attack.Name, result.Damage)
+	if result.Killed {
+		e.logf("%s is down", target.ID)
+	}
+	return result, nil
+}
+
+func (e *Encounter) logf(format string, args ...interface{}) {
+	e.Log = append(e.Log, fmt.Sprintf(format, args...))
+}
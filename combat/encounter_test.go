@@ -0,0 +1,254 @@
+package combat
+
+import (
+	"reflect"
+	"testing"
+)
+
+// gridMap is a map from rows of text, # is a wall
+type gridMap []string
+
+func (g gridMap) Walkable(x, y int) bool {
+	return y >= 0 && y < len(g) && x >= 0 && x < len(g[y]) && g[y][x] != '#'
+}
+
+// LineOfSight is blocked by walls on the tiles of the line
+func (g gridMap) LineOfSight(from Tile, to Tile) bool {
+	steps := Distance(from, to)
+	for i := 1; i < steps; i++ {
+		x := from.X + (to.X-from.X)*i/steps
+		y := from.Y + (to.Y-from.Y)*i/steps
+		if !g.Walkable(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+var openMap = gridMap{
+	"..........",
+	"..........",
+	"..........",
+	"..........",
+	"..........",
+}
+
+func fighter(id string, team int, x, y int) *Combatant {
+	return &Combatant{ID: id, Team: team, Tile: Tile{X: x, Y: y}, HP: 10, MaxHP: 10, MaxAP: 4, Attacks: []Attack{Melee, Ranged}}
+}
+
+func party() []*Combatant {
+	return []*Combatant{
+		fighter("a", 0, 0, 0),
+		fighter("b", 0, 0, 1),
+		fighter("c", 1, 5, 0),
+		fighter("d", 1, 5, 1),
+	}
+}
+
+func ids(order []*Combatant) []string {
+	list := []string{}
+	for _, c := range order {
+		list = append(list, c.ID)
+	}
+	return list
+}
+
+func TestInitiativeSeeded(t *testing.T) {
+	first, err := NewEncounter(party(), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := NewEncounter(party(), 42)
+	if !reflect.DeepEqual(ids(first.Order), ids(second.Order)) {
+		t.Fatalf("Same seed gave %v and %v", ids(first.Order), ids(second.Order))
+	}
+
+	differs := false
+	for seed := int64(0); seed < 20 && !differs; seed++ {
+		other, _ := NewEncounter(party(), seed)
+		differs = !reflect.DeepEqual(ids(first.Order), ids(other.Order))
+	}
+	if !differs {
+		t.Fatal("Order never depends on the seed")
+	}
+	if first.Current() != first.Order[0] || first.Current().AP != first.Current().MaxAP {
+		t.Fatal("First in order doesnt start with full AP")
+	}
+}
+
+func TestInitiativeBonus(t *testing.T) {
+	combatants := party()
+	combatants[3].Initiative = initiativeDie
+	e, _ := NewEncounter(combatants, 7)
+	if e.Order[0].ID != "d" {
+		t.Fatalf("Order %v, d always rolls highest", ids(e.Order))
+	}
+}
+
+func TestNewEncounterDuplicate(t *testing.T) {
+	combatants := party()
+	combatants[1].ID = "a"
+	if _, err := NewEncounter(combatants, 1); err == nil {
+		t.Fatal("Duplicate id accepted")
+	}
+}
+
+func TestEndTurnSkipsDead(t *testing.T) {
+	e, _ := NewEncounter(party(), 3)
+	next := e.Order[1]
+	next.HP = 0
+	e.EndTurn()
+	if e.Current() != e.Order[2] {
+		t.Fatalf("Turn went to %s", e.Current().ID)
+	}
+	for i := 0; i < 3; i++ {
+		e.EndTurn()
+	}
+	if e.Round != 2 {
+		t.Fatalf("Round %d after a lap", e.Round)
+	}
+}
+
+func TestEndTurnEverybodyDown(t *testing.T) {
+	combatants := party()
+	e, _ := NewEncounter(combatants, 3)
+	for _, c := range combatants {
+		c.HP = 0
+	}
+	e.EndTurn() // used to loop forever
+	if !e.Over() || e.Winner() != NoTeam {
+		t.Fatalf("Over %v winner %d", e.Over(), e.Winner())
+	}
+}
+
+func TestEmptyEncounter(t *testing.T) {
+	e, err := NewEncounter(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.EndTurn()
+	if e.Current() != nil || !e.Over() {
+		t.Fatal("Empty encounter is going on")
+	}
+	if _, ok := e.AIStep(openMap); ok {
+		t.Fatal("Empty encounter did something")
+	}
+}
+
+func TestWinner(t *testing.T) {
+	combatants := party()
+	e, _ := NewEncounter(combatants, 1)
+	if e.Winner() != NoTeam || e.Over() {
+		t.Fatal("Fight over before it began")
+	}
+	combatants[2].HP = 0
+	if e.Over() {
+		t.Fatal("Over with d still standing")
+	}
+	combatants[3].HP = 0
+	if e.Winner() != 0 || !e.Over() {
+		t.Fatalf("Winner %d, want team 0", e.Winner())
+	}
+}
+
+func TestMovementRangeCorners(t *testing.T) {
+	// the wall at 1,1 keeps the diagonal steps around it from cutting its
+	// corners
+	m := gridMap{
+		"..#",
+		".#.",
+		"...",
+	}
+	c := fighter("a", 0, 0, 0)
+	c.AP = 2
+	e := &Encounter{Combatants: []*Combatant{c}}
+	costs := e.MovementRange(m, c)
+	want := map[Tile]int{{0, 0}: 0, {1, 0}: 1, {0, 1}: 1, {0, 2}: 2}
+	if !reflect.DeepEqual(costs, want) {
+		t.Fatalf("Range %v, want %v", costs, want)
+	}
+}
+
+func TestMovementRangeOccupied(t *testing.T) {
+	combatants := party()
+	e := &Encounter{Combatants: combatants}
+	a := combatants[0]
+	a.AP = 1
+	costs := e.MovementRange(openMap, a)
+	if _, ok := costs[combatants[1].Tile]; ok {
+		t.Fatal("Can walk onto b")
+	}
+	combatants[1].HP = 0
+	if _, ok := e.MovementRange(openMap, a)[combatants[1].Tile]; !ok {
+		t.Fatal("Dead b still blocks")
+	}
+}
+
+func TestPathTo(t *testing.T) {
+	m := gridMap{
+		".#...",
+		".#.#.",
+		"...#.",
+	}
+	c := fighter("a", 0, 0, 0)
+	c.AP = 10
+	e := &Encounter{Combatants: []*Combatant{c}}
+	path, ok := e.PathTo(m, c, Tile{X: 2, Y: 0})
+	if !ok {
+		t.Fatal("No path")
+	}
+	// going round the wall ends, the diagonals would cut their corners
+	want := []Tile{{0, 1}, {0, 2}, {1, 2}, {2, 2}, {2, 1}, {2, 0}}
+	if !reflect.DeepEqual(path, want) {
+		t.Fatalf("Path %v, want %v", path, want)
+	}
+	for i := 1; i < len(path); i++ {
+		if !adjacentStep(m, path[i-1], path[i]) {
+			t.Fatalf("Cuts a corner from %v to %v", path[i-1], path[i])
+		}
+	}
+
+	c.AP = 5
+	if _, ok := e.PathTo(m, c, Tile{X: 2, Y: 0}); ok {
+		t.Fatal("Reached a tile further than the AP")
+	}
+	if _, ok := e.PathTo(m, c, c.Tile); ok {
+		t.Fatal("Path to where it stands")
+	}
+}
+
+func TestHitChanceClamped(t *testing.T) {
+	a := fighter("a", 0, 0, 0)
+	near := fighter("b", 1, 1, 0)
+	far := fighter("c", 1, 40, 0)
+	sure := Attack{Range: 100, HitChance: 2}
+	if chance := HitChance(a, near, sure); chance != maxHitChance {
+		t.Fatalf("Chance %v, want the max", chance)
+	}
+	if chance := HitChance(a, far, Ranged); chance != minHitChance {
+		t.Fatalf("Chance %v, want the min", chance)
+	}
+	// 3 tiles away loses two steps of the range penalty
+	mid := fighter("d", 1, 3, 0)
+	if chance := HitChance(a, mid, Ranged); chance < Ranged.HitChance-2*rangePenalty-1e-9 || chance > Ranged.HitChance-2*rangePenalty+1e-9 {
+		t.Fatalf("Chance %v at 3 tiles", chance)
+	}
+}
+
+func TestAttackSeeded(t *testing.T) {
+	fight := func() []string {
+		combatants := []*Combatant{fighter("a", 0, 0, 0), fighter("b", 1, 1, 0)}
+		e, _ := NewEncounter(combatants, 99)
+		for i := 0; i < 50 && !e.Over(); i++ {
+			if _, ok := e.AIStep(openMap); !ok {
+				e.EndTurn()
+			}
+		}
+		return e.Log
+	}
+	first, second := fight(), fight()
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("Same seed fought differently\n%v\n%v", first, second)
+	}
+}
@@ -1,34 +1,203 @@
 package config
 
-import "github.com/hajimehoshi/ebiten/v2"
+import (
+	"math"
 
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Camera maps world pixels to screen pixels, screen = (world - X,Y) * Scale.
+// X, Y and Scale are what is drawn right now, the Target values are where the
+// camera is heading to, Update moves it there smoothly.
 type Camera struct {
 	X, Y  float64
 	Scale float64
-	Speed float64
+	Speed float64 //screen pixels per tick when scrolling
+
+	TargetX, TargetY float64
+	TargetScale      float64
+	MinScale         float64
+	MaxScale         float64
+	Smoothing        float64 //part of the remaining distance covered each tick, 1 is instant
+
+	// world size in pixels, zero means no clamping
+	BoundsW, BoundsH float64
+	// screen size in pixels
+	ViewW, ViewH float64
+
+	Following  bool
+	EdgeMargin int //cursor this close to the screen edge scrolls, 0 turns it off
+
+	// zooming keeps the world point under the anchor on the same screen spot
+	zooming       bool
+	anchorScreenX float64
+	anchorScreenY float64
+	anchorWorldX  float64
+	anchorWorldY  float64
 }
 
-//TODO implement Y sorted
-// https://claude.ai/chat/4f32ea21-3789-45e8-900c-45b31c46dd77
+func NewCamera() *Camera {
+	return &Camera{
+		Scale:       1.0,
+		TargetScale: 1.0,
+		Speed:       4.0,
+		MinScale:    0.5,
+		MaxScale:    3.0,
+		Smoothing:   0.2,
+		ViewW:       ScreenW,
+		ViewH:       ScreenH,
+		EdgeMargin:  4,
+	}
+}
 
 func (c *Camera) ScreenToWorld(x, y float64) (worldx float64, worldy float64) {
-	worldx = (x / c.Scale) + c.X*c.Speed
-	worldy = (y / c.Scale) + c.Y*c.Speed
+	worldx = x/c.Scale + c.X
+	worldy = y/c.Scale + c.Y
 
 	return
 }
 
-func (c *Camera) WorldToScreen(x, y float64) (worldx float64, worldy float64) {
-	worldx = (x / c.Scale) + c.X*c.Speed
-	worldy = (y / c.Scale) + c.Y*c.Speed
+func (c *Camera) WorldToScreen(x, y float64) (screenx float64, screeny float64) {
+	screenx = (x - c.X) * c.Scale
+	screeny = (y - c.Y) * c.Scale
 
 	return
 }
 
+// GeoM is the world to screen transformation
+func (c *Camera) GeoM() ebiten.GeoM {
+	geom := ebiten.GeoM{}
+	geom.Translate(-c.X, -c.Y)
+	geom.Scale(c.Scale, c.Scale)
+	return geom
+}
+
+// WorldToScreenGeom adds moving to x, y in the world and the camera
+// transformation to whatever is already in opts
 func (c *Camera) WorldToScreenGeom(opts *ebiten.DrawImageOptions, x int, y int) {
 	if opts != nil {
 		opts.GeoM.Translate(float64(x), float64(y))
-		opts.GeoM.Translate(-c.X*c.Speed, -c.Y*c.Speed)
+		opts.GeoM.Translate(-c.X, -c.Y)
 		opts.GeoM.Scale(c.Scale, c.Scale)
 	}
 }
+
+// ViewRect returns the visible part of the world in world pixels
+func (c *Camera) ViewRect() (minx, miny, maxx, maxy float64) {
+	minx, miny = c.ScreenToWorld(0, 0)
+	maxx, maxy = c.ScreenToWorld(c.ViewW, c.ViewH)
+	return
+}
+
+func (c *Camera) SetBounds(w, h float64) {
+	c.BoundsW = w
+	c.BoundsH = h
+	c.TargetX, c.TargetY = c.clamp(c.TargetX, c.TargetY, c.TargetScale)
+}
+
+// Move scrolls by screen pixels
+func (c *Camera) Move(dx, dy float64) {
+	c.Following = false
+	c.TargetX += dx / c.TargetScale
+	c.TargetY += dy / c.TargetScale
+	if c.zooming {
+		c.anchorWorldX += dx / c.TargetScale
+		c.anchorWorldY += dy / c.TargetScale
+	}
+	c.TargetX, c.TargetY = c.clamp(c.TargetX, c.TargetY, c.TargetScale)
+}
+
+// ZoomAt multiplies the zoom, the world point under the screen point stays put
+func (c *Camera) ZoomAt(factor float64, screenx, screeny float64) {
+	scale := math.Max(c.MinScale, math.Min(c.MaxScale, c.TargetScale*factor))
+	if scale == c.TargetScale {
+		return
+	}
+
+	worldx, worldy := c.ScreenToWorld(screenx, screeny)
+	c.zooming = true
+	c.anchorScreenX, c.anchorScreenY = screenx, screeny
+	c.anchorWorldX, c.anchorWorldY = worldx, worldy
+
+	c.TargetScale = scale
+	c.TargetX = worldx - screenx/scale
+	c.TargetY = worldy - screeny/scale
+	c.TargetX, c.TargetY = c.clamp(c.TargetX, c.TargetY, c.TargetScale)
+}
+
+// CenterOn points the middle of the screen at the world point
+func (c *Camera) CenterOn(worldx, worldy float64) {
+	c.zooming = false
+	c.TargetX = worldx - c.ViewW/2/c.TargetScale
+	c.TargetY = worldy - c.ViewH/2/c.TargetScale
+	c.TargetX, c.TargetY = c.clamp(c.TargetX, c.TargetY, c.TargetScale)
+}
+
+// EdgeScroll moves the camera when the cursor is at the edge of the screen
+func (c *Camera) EdgeScroll(cursorx, cursory int) {
+	if c.EdgeMargin <= 0 {
+		return
+	}
+	if cursorx < 0 || cursory < 0 || float64(cursorx) > c.ViewW || float64(cursory) > c.ViewH {
+		// outside of the window
+		return
+	}
+	dx, dy := 0.0, 0.0
+	if cursorx < c.EdgeMargin {
+		dx = -c.Speed
+	} else if float64(cursorx) >= c.ViewW-float64(c.EdgeMargin) {
+		dx = c.Speed
+	}
+	if cursory < c.EdgeMargin {
+		dy = -c.Speed
+	} else if float64(cursory) >= c.ViewH-float64(c.EdgeMargin) {
+		dy = c.Speed
+	}
+	if dx != 0 || dy != 0 {
+		c.Move(dx, dy)
+	}
+}
+
+// clamp keeps the view inside of the bounds, a view bigger than the world is
+// centered instead
+func (c *Camera) clamp(x, y, scale float64) (float64, float64) {
+	if c.BoundsW > 0 {
+		x = clampAxis(x, c.ViewW/scale, c.BoundsW)
+	}
+	if c.BoundsH > 0 {
+		y = clampAxis(y, c.ViewH/scale, c.BoundsH)
+	}
+	return x, y
+}
+
+func clampAxis(position, view, bounds float64) float64 {
+	if view >= bounds {
+		return (bounds - view) / 2
+	}
+	return math.Max(0, math.Min(position, bounds-view))
+}
+
+// Update moves the camera towards the target, call once per tick
+func (c *Camera) Update() {
+	c.Scale += (c.TargetScale - c.Scale) * c.Smoothing
+	if math.Abs(c.TargetScale-c.Scale) < 0.001 {
+		c.Scale = c.TargetScale
+	}
+
+	if c.zooming {
+		c.X = c.anchorWorldX - c.anchorScreenX/c.Scale
+		c.Y = c.anchorWorldY - c.anchorScreenY/c.Scale
+		c.X, c.Y = c.clamp(c.X, c.Y, c.Scale)
+		if c.Scale == c.TargetScale {
+			c.zooming = false
+			c.X, c.Y = c.TargetX, c.TargetY
+		}
+		return
+	}
+
+	c.X += (c.TargetX - c.X) * c.Smoothing
+	c.Y += (c.TargetY - c.Y) * c.Smoothing
+	if math.Abs(c.TargetX-c.X) < 0.01 && math.Abs(c.TargetY-c.Y) < 0.01 {
+		c.X, c.Y = c.TargetX, c.TargetY
+	}
+}
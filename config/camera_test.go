@@ -0,0 +1,104 @@
+package config
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// settle runs updates until the camera reaches its target
+func settle(c *Camera) {
+	for i := 0; i < 1000; i++ {
+		c.Update()
+	}
+}
+
+func TestScreenWorldRoundTrip(t *testing.T) {
+	points := [][2]float64{{0, 0}, {100, 50}, {-30, 400}, {1234.5, 77.25}}
+	for _, scale := range []float64{0.5, 1, 1.7, 3} {
+		for _, pan := range [][2]float64{{0, 0}, {250, -40}, {-12.5, 999}} {
+			c := NewCamera()
+			c.X, c.Y, c.Scale = pan[0], pan[1], scale
+			for _, p := range points {
+				sx, sy := c.WorldToScreen(p[0], p[1])
+				wx, wy := c.ScreenToWorld(sx, sy)
+				if !near(wx, p[0]) || !near(wy, p[1]) {
+					t.Fatalf("Scale %v pan %v: %v went to %v,%v", scale, pan, p, wx, wy)
+				}
+				// the geom used for drawing agrees with WorldToScreen
+				geom := c.GeoM()
+				gx, gy := geom.Apply(p[0], p[1])
+				if !near(gx, sx) || !near(gy, sy) {
+					t.Fatalf("GeoM puts %v at %v,%v, WorldToScreen at %v,%v", p, gx, gy, sx, sy)
+				}
+			}
+		}
+	}
+}
+
+func TestZoomAtKeepsAnchor(t *testing.T) {
+	anchors := [][2]float64{{0, 0}, {ScreenW / 2, ScreenH / 2}, {ScreenW - 1, 17}}
+	for _, factor := range []float64{1.25, 0.8, 2} {
+		for _, anchor := range anchors {
+			c := NewCamera()
+			c.X, c.Y = 300, 200
+			c.TargetX, c.TargetY = c.X, c.Y
+			wx, wy := c.ScreenToWorld(anchor[0], anchor[1])
+
+			c.ZoomAt(factor, anchor[0], anchor[1])
+			// every frame of the smooth zoom keeps the point under the cursor
+			for i := 0; i < 100; i++ {
+				c.Update()
+				sx, sy := c.WorldToScreen(wx, wy)
+				if !near(sx, anchor[0]) || !near(sy, anchor[1]) {
+					t.Fatalf("Zoom %v at %v: tick %d the point is at %v,%v", factor, anchor, i, sx, sy)
+				}
+			}
+			if !near(c.Scale, factor) {
+				t.Fatalf("Scale %v, want %v", c.Scale, factor)
+			}
+		}
+	}
+}
+
+func TestZoomAtLimits(t *testing.T) {
+	c := NewCamera()
+	for i := 0; i < 20; i++ {
+		c.ZoomAt(2, 10, 10)
+	}
+	settle(c)
+	if c.Scale != c.MaxScale {
+		t.Fatalf("Scale %v, want the max %v", c.Scale, c.MaxScale)
+	}
+	for i := 0; i < 20; i++ {
+		c.ZoomAt(0.5, 10, 10)
+	}
+	settle(c)
+	if c.Scale != c.MinScale {
+		t.Fatalf("Scale %v, want the min %v", c.Scale, c.MinScale)
+	}
+}
+
+func TestZoomAndPanStayInBounds(t *testing.T) {
+	c := NewCamera()
+	c.SetBounds(2000, 1500)
+	steps := []func(){
+		func() { c.ZoomAt(1.5, 0, 0) },
+		func() { c.Move(-5000, -5000) },
+		func() { c.ZoomAt(0.5, ScreenW, ScreenH) },
+		func() { c.Move(9000, 9000) },
+		func() { c.ZoomAt(3, ScreenW/2, ScreenH/2) },
+		func() { c.CenterOn(1990, 10) },
+	}
+	for i, step := range steps {
+		step()
+		settle(c)
+		minx, miny, maxx, maxy := c.ViewRect()
+		if minx < -1e-6 || miny < -1e-6 || maxx > 2000+1e-6 || maxy > 1500+1e-6 {
+			t.Fatalf("Step %d sees %v,%v %v,%v outside of the world", i, minx, miny, maxx, maxy)
+		}
+	}
+}
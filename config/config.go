@@ -11,9 +11,35 @@ const (
 
 	TileSize  = 16
 	Tolerance = 8
+
+	PathWorkers = 4
+
+	SightRadius = 8 //tiles
+
+	EncounterRadius = 8 //tiles, npcs this close to the party join a fight
+
+	// hostile npcs, distances in tiles
+	EnemyThinkTicks     = 10 //ticks between enemy decisions
+	EnemySightRadius    = 7
+	EnemySightAngle     = 120 //degrees
+	EnemyHearingRadius  = 4
+	EnemyLeash          = 14
+	EnemyAlertRadius    = 10
+	EngageRange         = 1.5 //a chasing enemy this close starts a fight
+	EngageCooldownTicks = 300 //no new fight right after one
+
+	InventorySlots  = 20
+	InventoryWeight = 40.0
+
+	// ticks a character waits on a blocked tile before sidestepping and
+	// before asking for a new path
+	BlockedWaitTicks   = 15
+	BlockedRepathTicks = 45
+	MaxRepaths         = 3
 )
 
 var PlayableCharacters map[int]string
+var PlayableClasses map[string]string //character name => class name
 
 func init() {
 	PlayableCharacters = map[int]string{
@@ -21,4 +47,9 @@ func init() {
 		1: "green",
 		2: "blue",
 	}
+	PlayableClasses = map[string]string{
+		"red":   "warrior",
+		"green": "ranger",
+		"blue":  "mage",
+	}
 }
@@ -0,0 +1,154 @@
+package dialogue
+
+import "fmt"
+
+// State is what conversations read and change in the game, the items are the
+// ones of the party member doing the talking
+type State interface {
+	Flag(name string) int
+	SetFlag(name string, value int)
+	ItemCount(item string) int
+	GiveItem(item string, count int) error
+	TakeItem(item string, count int) error
+	StartQuest(id string) error
+}
+
+// Conversation is a running dialogue
+type Conversation struct {
+	Dialogue Dialogue
+	NodeID   string
+	Node     Node
+	Choices  []Choice //the choices of the node that can be taken right now
+	Ended    bool
+	state    State
+}
+
+// Start begins the dialogue at its first possible start, a dialogue without
+// any ends right away
+func Start(d Dialogue, state State) (*Conversation, error) {
+	c := &Conversation{Dialogue: d, state: state}
+	for _, choice := range d.Start {
+		if c.allowed(choice.Conditions) {
+			return c, c.take(choice)
+		}
+	}
+	c.Ended = true
+	return c, nil
+}
+
+func (c *Conversation) Speaker() string {
+	if c.Node.Speaker != "" {
+		return c.Node.Speaker
+	}
+	return c.Dialogue.Speaker
+}
+
+func (c *Conversation) Faceset() string {
+	if c.Node.Faceset != "" {
+		return c.Node.Faceset
+	}
+	return c.Dialogue.Faceset
+}
+
+// Continue moves past a node without choices
+func (c *Conversation) Continue() error {
+	if c.Ended {
+		return nil
+	}
+	if len(c.Choices) > 0 {
+		return fmt.Errorf("Node %s needs a choice", c.NodeID)
+	}
+	return c.enter(c.Node.Next)
+}
+
+// Choose takes one of the available choices
+func (c *Conversation) Choose(index int) error {
+	if c.Ended {
+		return nil
+	}
+	if index < 0 || index >= len(c.Choices) {
+		return fmt.Errorf("Node %s has no choice %d", c.NodeID, index)
+	}
+	return c.take(c.Choices[index])
+}
+
+func (c *Conversation) take(choice Choice) error {
+	err := c.apply(choice.Effects)
+	if err != nil {
+		return err
+	}
+	return c.enter(choice.Next)
+}
+
+func (c *Conversation) enter(id string) error {
+	if id == "" {
+		c.Ended = true
+		c.NodeID = ""
+		c.Node = Node{}
+		c.Choices = nil
+		return nil
+	}
+	node, ok := c.Dialogue.Nodes[id]
+	if !ok {
+		return fmt.Errorf("Dialogue %s has no node %s", c.Dialogue.ID, id)
+	}
+	c.NodeID = id
+	c.Node = node
+	err := c.apply(node.Effects)
+	if err != nil {
+		return err
+	}
+
+	c.Choices = []Choice{}
+	for _, choice := range node.Choices {
+		if c.allowed(choice.Conditions) {
+			c.Choices = append(c.Choices, choice)
+		}
+	}
+	if len(node.Choices) > 0 && len(c.Choices) == 0 {
+		// every choice got filtered out, dont get stuck
+		return c.enter(node.Next)
+	}
+	return nil
+}
+
+func (c *Conversation) allowed(conditions []Condition) bool {
+	for _, condition := range conditions {
+		if c.holds(condition) == condition.Not {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Conversation) holds(condition Condition) bool {
+	if condition.Item != "" {
+		return c.state.ItemCount(condition.Item) >= max(condition.Count, 1)
+	}
+	return c.state.Flag(condition.Flag) == condition.Value
+}
+
+func (c *Conversation) apply(effects []Effect) error {
+	for _, effect := range effects {
+		count := max(effect.Count, 1)
+		if effect.SetFlag != "" {
+			c.state.SetFlag(effect.SetFlag, effect.Value)
+		}
+		if effect.TakeItem != "" {
+			if err := c.state.TakeItem(effect.TakeItem, count); err != nil {
+				return err
+			}
+		}
+		if effect.GiveItem != "" {
+			if err := c.state.GiveItem(effect.GiveItem, count); err != nil {
+				return err
+			}
+		}
+		if effect.StartQuest != "" {
+			if err := c.state.StartQuest(effect.StartQuest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
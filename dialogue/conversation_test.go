@@ -0,0 +1,217 @@
+package dialogue
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testState keeps flags and items in maps and records what the effects did
+type testState struct {
+	flags  map[string]int
+	items  map[string]int
+	quests []string
+}
+
+func newTestState() *testState {
+	return &testState{flags: map[string]int{}, items: map[string]int{}}
+}
+
+func (s *testState) Flag(name string) int           { return s.flags[name] }
+func (s *testState) SetFlag(name string, value int) { s.flags[name] = value }
+func (s *testState) ItemCount(item string) int      { return s.items[item] }
+
+func (s *testState) GiveItem(item string, count int) error {
+	s.items[item] += count
+	return nil
+}
+
+func (s *testState) TakeItem(item string, count int) error {
+	if s.items[item] < count {
+		return fmt.Errorf("Not enough %s", item)
+	}
+	s.items[item] -= count
+	return nil
+}
+
+func (s *testState) StartQuest(id string) error {
+	s.quests = append(s.quests, id)
+	return nil
+}
+
+// trader greets differently once met and sells a sword for 3 gold
+var trader = Dialogue{
+	ID:      "trader",
+	Speaker: "Trader",
+	Start: []Choice{
+		{Next: "again", Conditions: []Condition{{Flag: "met", Value: 1}}},
+		{Next: "hello"},
+	},
+	Nodes: map[string]Node{
+		"hello": {
+			Text:    "Hello stranger",
+			Effects: []Effect{{SetFlag: "met", Value: 1}},
+			Next:    "offer",
+		},
+		"again": {Speaker: "Old trader", Text: "Back again?", Next: "offer"},
+		"offer": {
+			Text: "Want a sword?",
+			Choices: []Choice{
+				{
+					Text:       "Buy",
+					Next:       "sold",
+					Conditions: []Condition{{Item: "gold", Count: 3}},
+					Effects:    []Effect{{TakeItem: "gold", Count: 3}, {GiveItem: "sword"}},
+				},
+				{Text: "Who are you?", Next: "quest", Conditions: []Condition{{Flag: "quest", Value: 1, Not: true}}},
+				{Text: "Bye"},
+			},
+		},
+		"sold":  {Text: "Enjoy it"},
+		"quest": {Text: "Find my cat", Effects: []Effect{{StartQuest: "cat", SetFlag: "quest", Value: 1}}},
+	},
+}
+
+func choiceTexts(c *Conversation) []string {
+	texts := []string{}
+	for _, choice := range c.Choices {
+		texts = append(texts, choice.Text)
+	}
+	return texts
+}
+
+func TestStartChoice(t *testing.T) {
+	state := newTestState()
+	c, err := Start(trader, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.NodeID != "hello" || c.Speaker() != "Trader" {
+		t.Fatalf("Started at %s by %s", c.NodeID, c.Speaker())
+	}
+
+	// the first start that holds wins
+	c, err = Start(trader, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.NodeID != "again" || c.Speaker() != "Old trader" {
+		t.Fatalf("Met trader started at %s by %s", c.NodeID, c.Speaker())
+	}
+
+	nobody := Dialogue{ID: "nobody", Start: []Choice{{Next: "x", Conditions: []Condition{{Flag: "never", Value: 1}}}}}
+	c, err = Start(nobody, state)
+	if err != nil || !c.Ended {
+		t.Fatalf("No possible start, ended %v, %v", c.Ended, err)
+	}
+}
+
+func TestConditions(t *testing.T) {
+	state := newTestState()
+	state.flags["met"] = 1
+	c, err := Start(trader, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	if got := choiceTexts(c); !reflect.DeepEqual(got, []string{"Who are you?", "Bye"}) {
+		t.Fatalf("Choices without gold %v", got)
+	}
+
+	// enough of the item makes buying possible, Not hides the asked question
+	state.items["gold"] = 3
+	state.flags["quest"] = 1
+	c, _ = Start(trader, state)
+	c.Continue()
+	if got := choiceTexts(c); !reflect.DeepEqual(got, []string{"Buy", "Bye"}) {
+		t.Fatalf("Choices with gold %v", got)
+	}
+
+	if err := c.Choose(2); err == nil {
+		t.Fatal("Choice out of range taken")
+	}
+	if err := c.Choose(0); err != nil {
+		t.Fatal(err)
+	}
+	if c.NodeID != "sold" || state.items["gold"] != 0 || state.items["sword"] != 1 {
+		t.Fatalf("At %s with items %v", c.NodeID, state.items)
+	}
+	if err := c.Continue(); err != nil || !c.Ended {
+		t.Fatalf("Not ended after the last node, %v", err)
+	}
+}
+
+func TestEffects(t *testing.T) {
+	state := newTestState()
+	c, _ := Start(trader, state)
+	// entering hello already set the flag
+	if state.flags["met"] != 1 {
+		t.Fatal("Node effects not applied on entering")
+	}
+	c.Continue()
+	if len(state.quests) != 0 {
+		t.Fatalf("Quests %v before choosing", state.quests)
+	}
+	if err := c.Choose(0); err != nil {
+		t.Fatal(err)
+	}
+	if c.NodeID != "quest" || !reflect.DeepEqual(state.quests, []string{"cat"}) || state.flags["quest"] != 1 {
+		t.Fatalf("At %s, quests %v, flags %v", c.NodeID, state.quests, state.flags)
+	}
+
+	// choice effects run before the next node, a failing one stops there
+	state.items["gold"] = 3
+	c, _ = Start(trader, state)
+	c.Continue()
+	state.items["gold"] = 1
+	if err := c.Choose(0); err == nil {
+		t.Fatal("Took gold that wasnt there")
+	}
+	if c.NodeID != "offer" || state.items["sword"] != 0 {
+		t.Fatalf("At %s with items %v after a failed choice", c.NodeID, state.items)
+	}
+}
+
+func TestFilteredChoicesFallThrough(t *testing.T) {
+	d := Dialogue{
+		ID:    "guard",
+		Start: []Choice{{Next: "gate"}},
+		Nodes: map[string]Node{
+			"gate": {
+				Text: "Password?",
+				Choices: []Choice{
+					{Text: "Swordfish", Next: "open", Conditions: []Condition{{Flag: "password", Value: 1}}},
+				},
+				Next: "away",
+			},
+			"open": {Text: "Go on"},
+			"away": {Text: "Go away"},
+		},
+	}
+	c, err := Start(d, newTestState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.NodeID != "away" {
+		t.Fatalf("At %s, want the next node of the gate", c.NodeID)
+	}
+
+	// without a Next the conversation just ends
+	gate := d.Nodes["gate"]
+	gate.Next = ""
+	d.Nodes = map[string]Node{"gate": gate, "open": d.Nodes["open"]}
+	c, err = Start(d, newTestState())
+	if err != nil || !c.Ended {
+		t.Fatalf("Stuck at %s, %v", c.NodeID, err)
+	}
+
+	c, _ = Start(d, &testState{flags: map[string]int{"password": 1}})
+	if c.NodeID != "gate" || len(c.Choices) != 1 {
+		t.Fatalf("At %s with %d choices", c.NodeID, len(c.Choices))
+	}
+	if err := c.Continue(); err == nil {
+		t.Fatal("Continued past a node with choices")
+	}
+}
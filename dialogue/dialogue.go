@@ -0,0 +1,116 @@
+// Package dialogue runs conversation trees loaded from data files, it only
+// talks to the game through State so it can run without a window
+package dialogue
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const DialoguesPath = "assets/data/dialogues.json"
+
+// Condition is true when the flag equals Value or, with Item set, when the
+// party member has at least Count of the item. Not flips it.
+type Condition struct {
+	Flag  string
+	Value int
+	Item  string
+	Count int
+	Not   bool
+}
+
+// Effect changes the game when a node is entered or a choice is taken
+type Effect struct {
+	SetFlag    string
+	Value      int
+	GiveItem   string
+	TakeItem   string
+	Count      int //of the given or taken item, 0 means 1
+	StartQuest string
+}
+
+// Choice leads to Next, an empty Next ends the conversation. It is only
+// offered when all of its conditions hold.
+type Choice struct {
+	Text       string
+	Next       string
+	Conditions []Condition
+	Effects    []Effect
+}
+
+// Node is one line of the conversation. Speaker and Faceset fall back to the
+// ones of the dialogue. Without choices the conversation goes on to Next.
+type Node struct {
+	Speaker string
+	Faceset string
+	Text    string
+	Effects []Effect
+	Choices []Choice
+	Next    string
+}
+
+// Dialogue is a conversation tree, it starts at the first of the Start
+// choices whose conditions hold so the same npc can greet differently
+type Dialogue struct {
+	ID      string
+	Speaker string
+	Faceset string //path of the portrait image
+	Start   []Choice
+	Nodes   map[string]Node
+}
+
+// Load reads every dialogue from the file by ID
+func Load(path string) (map[string]Dialogue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list := []Dialogue{}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("Reading dialogues %s: %w", path, err)
+	}
+
+	dialogues := map[string]Dialogue{}
+	for _, d := range list {
+		if _, ok := dialogues[d.ID]; ok {
+			return nil, fmt.Errorf("Dialogue %s is defined twice", d.ID)
+		}
+		err = d.validate()
+		if err != nil {
+			return nil, err
+		}
+		dialogues[d.ID] = d
+	}
+	return dialogues, nil
+}
+
+// validate checks that every link points to an existing node
+func (d Dialogue) validate() error {
+	if len(d.Start) == 0 {
+		return fmt.Errorf("Dialogue %s has no start", d.ID)
+	}
+	check := func(next string) error {
+		if _, ok := d.Nodes[next]; next != "" && !ok {
+			return fmt.Errorf("Dialogue %s links to unknown node %s", d.ID, next)
+		}
+		return nil
+	}
+	for _, choice := range d.Start {
+		if err := check(choice.Next); err != nil {
+			return err
+		}
+	}
+	for _, node := range d.Nodes {
+		if err := check(node.Next); err != nil {
+			return err
+		}
+		for _, choice := range node.Choices {
+			if err := check(choice.Next); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package dialogue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dialogues, err := Load(filepath.Join("..", DialoguesPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dialogues) == 0 {
+		t.Fatal("No dialogues")
+	}
+
+	broken := map[string]string{
+		"twice":       `[{"ID": "a", "Start": [{}]}, {"ID": "a", "Start": [{}]}]`,
+		"no start":    `[{"ID": "a"}]`,
+		"start link":  `[{"ID": "a", "Start": [{"Next": "x"}]}]`,
+		"node link":   `[{"ID": "a", "Start": [{"Next": "x"}], "Nodes": {"x": {"Next": "y"}}}]`,
+		"choice link": `[{"ID": "a", "Start": [{"Next": "x"}], "Nodes": {"x": {"Choices": [{"Next": "y"}]}}}]`,
+		"json":        `{`,
+	}
+	for name, data := range broken {
+		path := filepath.Join(t.TempDir(), "dialogues.json")
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	d := trader
+	if err := d.validate(); err != nil {
+		t.Fatal(err)
+	}
+	// empty links end the conversation and are fine
+	d.Start = []Choice{{}}
+	if err := d.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,154 @@
+// Package enemy decides what hostile npcs do: what they notice, who they are
+// angry at, when they chase and when they give up. It works in tiles, knows
+// the level only through Senses and takes its randomness from a seed so the
+// same inputs always give the same decisions.
+package enemy
+
+import (
+	"math"
+	"sort"
+)
+
+type State int
+
+const (
+	Guarding  State = iota //doing its normal behaviour, looking around
+	Chasing                //going after the top of the threat table
+	Returning              //gave up, walking back to the spawn point
+)
+
+// Point is a position in tiles, it doesnt have to be the middle of one
+type Point struct {
+	X, Y float64
+}
+
+// Perception is what the enemy can notice around itself
+type Perception struct {
+	SightRadius   float64 //tiles
+	SightAngle    float64 //the whole cone in degrees
+	HearingRadius float64 //tiles, for a target making full noise
+}
+
+// Target is something the enemies can get angry at
+type Target struct {
+	ID       string
+	Position Point   //tiles
+	Noise    float64 //1 when walking, less when standing still
+}
+
+// Senses is the level as seen by the enemies
+type Senses interface {
+	// LineOfSight tells if nothing blocks the view between two tiles
+	LineOfSight(from Point, to Point) bool
+}
+
+// Brain is one enemy
+type Brain struct {
+	ID         string
+	Group      string //enemies of a group alert each other
+	Spawn      Point
+	Position   Point
+	Facing     float64 //radians, 0 looks along +x
+	Perception Perception
+	Leash      float64 //tiles from the spawn before giving up a chase
+	State      State
+	Asleep     bool //not thinking at all, like when it is on another level
+	Threat     map[string]float64
+	LastKnown  map[string]Point //where the targets were last seen or heard
+}
+
+func NewBrain(id string, group string, spawn Point, perception Perception, leash float64) *Brain {
+	return &Brain{
+		ID:         id,
+		Group:      group,
+		Spawn:      spawn,
+		Position:   spawn,
+		Perception: perception,
+		Leash:      leash,
+		Threat:     map[string]float64{},
+		LastKnown:  map[string]Point{},
+	}
+}
+
+// Sees tells if the target is inside of the sight cone with nothing in the way,
+// anything right next to the enemy is always noticed
+func (b *Brain) Sees(target Target, senses Senses) bool {
+	dx, dy := target.Position.X-b.Position.X, target.Position.Y-b.Position.Y
+	dist := math.Hypot(dx, dy)
+	if dist <= closeRange {
+		return true
+	}
+	if dist > b.Perception.SightRadius {
+		return false
+	}
+	angle := math.Abs(angleBetween(b.Facing, math.Atan2(dy, dx)))
+	if angle > b.Perception.SightAngle*math.Pi/180/2 {
+		return false
+	}
+	return senses.LineOfSight(b.Position, target.Position)
+}
+
+// Hears tells if the target is loud enough for its distance, walls dont stop
+// sound
+func (b *Brain) Hears(target Target) bool {
+	dist := math.Hypot(target.Position.X-b.Position.X, target.Position.Y-b.Position.Y)
+	return dist <= b.Perception.HearingRadius*target.Noise
+}
+
+func (b *Brain) AddThreat(id string, amount float64) {
+	b.Threat[id] += amount
+}
+
+// Top is the target with the most threat, ties go to the smaller id
+func (b *Brain) Top() (string, bool) {
+	best := ""
+	bestThreat := 0.0
+	for _, id := range b.sortedThreat() {
+		if b.Threat[id] > bestThreat {
+			best, bestThreat = id, b.Threat[id]
+		}
+	}
+	return best, best != ""
+}
+
+// decay lowers every threat and forgets the small ones
+func (b *Brain) decay() {
+	for _, id := range b.sortedThreat() {
+		b.Threat[id] *= threatDecay
+		if b.Threat[id] < forgetThreat {
+			delete(b.Threat, id)
+			delete(b.LastKnown, id)
+		}
+	}
+}
+
+// calm forgets every target
+func (b *Brain) calm() {
+	b.Threat = map[string]float64{}
+	b.LastKnown = map[string]Point{}
+}
+
+func (b *Brain) sortedThreat() []string {
+	ids := []string{}
+	for id := range b.Threat {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (b *Brain) distance(point Point) float64 {
+	return math.Hypot(point.X-b.Position.X, point.Y-b.Position.Y)
+}
+
+// angleBetween is the signed difference of two angles in -pi..pi
+func angleBetween(a, b float64) float64 {
+	d := math.Mod(b-a, 2*math.Pi)
+	if d > math.Pi {
+		d -= 2 * math.Pi
+	}
+	if d < -math.Pi {
+		d += 2 * math.Pi
+	}
+	return d
+}
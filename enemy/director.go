@@ -0,0 +1,201 @@
+package enemy
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	closeRange   = 1.5 //tiles, noticed without looking
+	sightThreat  = 10.0
+	hearThreat   = 4.0
+	alertThreat  = 5.0 //given to the group when one of them starts a chase
+	threatDecay  = 0.8 //per think
+	forgetThreat = 1.0
+	// an enemy back this close to its spawn is guarding again
+	homeRadius = 1.0
+	// guarding enemies turn to look somewhere else about every this many thinks
+	lookAroundThinks = 4
+)
+
+type OrderKind int
+
+const (
+	Hold   OrderKind = iota //keep doing the normal behaviour
+	Chase                   //walk to Goal, the target is there
+	Return                  //walk back to Goal, the spawn point
+)
+
+// Order is what a think decided for one enemy
+type Order struct {
+	ID     string
+	Kind   OrderKind
+	Target string
+	Goal   Point
+}
+
+// Director thinks for every enemy together every ThinkTicks ticks
+type Director struct {
+	Brains      map[string]*Brain
+	ThinkTicks  int
+	AlertRadius float64 //tiles, group members further away dont hear the alarm
+	ticks       int
+	rand        *rand.Rand
+}
+
+func NewDirector(seed int64, thinkTicks int, alertRadius float64) *Director {
+	return &Director{
+		Brains:      map[string]*Brain{},
+		ThinkTicks:  max(thinkTicks, 1),
+		AlertRadius: alertRadius,
+		rand:        rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (d *Director) Add(brain *Brain) {
+	d.Brains[brain.ID] = brain
+}
+
+// Remove forgets the enemy, call it when its npc goes away
+func (d *Director) Remove(id string) {
+	delete(d.Brains, id)
+}
+
+// Reset makes every enemy forget its targets and go back to its spawn
+func (d *Director) Reset() {
+	for _, brain := range d.Brains {
+		brain.calm()
+		brain.State = Returning
+	}
+}
+
+// Tick counts one game tick, it returns the orders on the ticks the
+// enemies think and nil on the others
+func (d *Director) Tick(targets []Target, senses Senses) []Order {
+	d.ticks++
+	if d.ticks < d.ThinkTicks {
+		return nil
+	}
+	d.ticks = 0
+	return d.Think(targets, senses)
+}
+
+// Think updates what every enemy noticed and decides what they do. The
+// positions of the brains have to be up to date.
+func (d *Director) Think(targets []Target, senses Senses) []Order {
+	ids := d.SortedIDs()
+
+	// perception first so alerts from anyone reach everyone in this think
+	started := []*Brain{}
+	for _, id := range ids {
+		brain := d.Brains[id]
+		if brain.Asleep || brain.State == Returning {
+			continue
+		}
+		brain.decay()
+		for _, target := range targets {
+			if brain.Sees(target, senses) {
+				brain.AddThreat(target.ID, sightThreat)
+				brain.LastKnown[target.ID] = target.Position
+			} else if brain.Hears(target) {
+				brain.AddThreat(target.ID, hearThreat)
+				brain.LastKnown[target.ID] = target.Position
+			}
+		}
+		if _, ok := brain.Top(); ok && brain.State == Guarding {
+			brain.State = Chasing
+			started = append(started, brain)
+		}
+	}
+	for _, brain := range started {
+		d.alert(brain)
+	}
+
+	byID := map[string]Target{}
+	for _, target := range targets {
+		byID[target.ID] = target
+	}
+	orders := []Order{}
+	for _, id := range ids {
+		if !d.Brains[id].Asleep {
+			orders = append(orders, d.decide(d.Brains[id], byID))
+		}
+	}
+	return orders
+}
+
+// alert gives the group members near the brain the same target
+func (d *Director) alert(brain *Brain) {
+	target, _ := brain.Top()
+	if brain.Group == "" {
+		return
+	}
+	for _, id := range d.SortedIDs() {
+		other := d.Brains[id]
+		if other == brain || other.Group != brain.Group || other.Asleep || other.State == Returning {
+			continue
+		}
+		if other.distance(brain.Position) > d.AlertRadius {
+			continue
+		}
+		other.AddThreat(target, alertThreat)
+		other.LastKnown[target] = brain.LastKnown[target]
+		other.State = Chasing
+	}
+}
+
+func (d *Director) decide(brain *Brain, targets map[string]Target) Order {
+	order := Order{ID: brain.ID, Kind: Hold}
+
+	if brain.State == Chasing && math.Hypot(brain.Position.X-brain.Spawn.X, brain.Position.Y-brain.Spawn.Y) > brain.Leash {
+		// too far from home, forget everything and go back
+		brain.calm()
+		brain.State = Returning
+	}
+	if brain.State == Returning {
+		if brain.distance(brain.Spawn) <= homeRadius {
+			brain.State = Guarding
+			return order
+		}
+		order.Kind = Return
+		order.Goal = brain.Spawn
+		return order
+	}
+
+	if brain.State == Chasing {
+		id, ok := brain.Top()
+		goal, known := brain.LastKnown[id]
+		if _, present := targets[id]; !ok || !known || !present {
+			// lost everyone, go back to where it was guarding
+			brain.calm()
+			brain.State = Returning
+			order.Kind = Return
+			order.Goal = brain.Spawn
+			return order
+		}
+		if brain.distance(goal) > 0 {
+			brain.Facing = math.Atan2(goal.Y-brain.Position.Y, goal.X-brain.Position.X)
+		}
+		order.Kind = Chase
+		order.Target = id
+		order.Goal = goal
+		return order
+	}
+
+	// guarding, look around now and then
+	if d.rand.Intn(lookAroundThinks) == 0 {
+		brain.Facing = d.rand.Float64()*2*math.Pi - math.Pi
+	}
+	return order
+}
+
+// SortedIDs lists the enemies in a fixed order, maps dont have one
+func (d *Director) SortedIDs() []string {
+	ids := []string{}
+	for id := range d.Brains {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,213 @@
+package enemy
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// wallSenses blocks every view that crosses the vertical wall at WallX
+type wallSenses struct {
+	WallX float64
+	Wall  bool
+}
+
+func (s wallSenses) LineOfSight(from Point, to Point) bool {
+	if !s.Wall {
+		return true
+	}
+	return (from.X < s.WallX) == (to.X < s.WallX)
+}
+
+var perception = Perception{SightRadius: 7, SightAngle: 90, HearingRadius: 4}
+
+func guard(id string, group string, x, y float64) *Brain {
+	return NewBrain(id, group, Point{X: x, Y: y}, perception, 10)
+}
+
+func TestSightCone(t *testing.T) {
+	b := guard("g", "", 0, 0) // facing +x
+	senses := wallSenses{}
+	cases := []struct {
+		at   Point
+		sees bool
+	}{
+		{Point{X: 5, Y: 0}, true},
+		{Point{X: 5, Y: 4}, true},    //inside of the 45 degrees
+		{Point{X: 3, Y: 4}, false},   //outside of the cone
+		{Point{X: -5, Y: 0}, false},  //behind
+		{Point{X: -1, Y: 0}, true},   //right behind but close
+		{Point{X: 8, Y: 0}, false},   //too far
+		{Point{X: 6.9, Y: 0}, true},  //at the edge
+		{Point{X: 0.5, Y: -1}, true}, //close on the side
+	}
+	for _, c := range cases {
+		if got := b.Sees(Target{ID: "t", Position: c.at}, senses); got != c.sees {
+			t.Fatalf("Sees %v: %v, want %v", c.at, got, c.sees)
+		}
+	}
+
+	b.Facing = math.Pi
+	if !b.Sees(Target{Position: Point{X: -5, Y: 0}}, senses) {
+		t.Fatal("Turned around and still doesnt see")
+	}
+}
+
+func TestSightOccluded(t *testing.T) {
+	b := guard("g", "", 0, 0)
+	wall := wallSenses{WallX: 2.5, Wall: true}
+	if b.Sees(Target{Position: Point{X: 5, Y: 0}}, wall) {
+		t.Fatal("Sees through the wall")
+	}
+	if !b.Sees(Target{Position: Point{X: 2, Y: 0}}, wall) {
+		t.Fatal("Doesnt see in front of the wall")
+	}
+	// close range ignores walls, it would bump into them
+	b.Position = Point{X: 2, Y: 0}
+	if !b.Sees(Target{Position: Point{X: 3, Y: 0}}, wall) {
+		t.Fatal("Doesnt notice right next to it")
+	}
+}
+
+func TestHearing(t *testing.T) {
+	b := guard("g", "", 0, 0)
+	walking := Target{Position: Point{X: -3.5, Y: 0}, Noise: 1}
+	if !b.Hears(walking) {
+		t.Fatal("Doesnt hear walking")
+	}
+	standing := walking
+	standing.Noise = 0.5
+	if b.Hears(standing) {
+		t.Fatal("Hears standing still from as far")
+	}
+	standing.Position.X = -1.9
+	if !b.Hears(standing) {
+		t.Fatal("Doesnt hear standing close")
+	}
+}
+
+func TestHeardTargetIsChased(t *testing.T) {
+	d := NewDirector(1, 1, 5)
+	d.Add(guard("g", "", 0, 0))
+	targets := []Target{{ID: "t", Position: Point{X: -3, Y: 0}, Noise: 1}}
+	orders := d.Think(targets, wallSenses{})
+	if orders[0].Kind != Chase || orders[0].Target != "t" {
+		t.Fatalf("Order %+v", orders[0])
+	}
+	targets[0].Noise = 0.1
+	d2 := NewDirector(1, 1, 5)
+	d2.Add(guard("g", "", 0, 0))
+	if orders := d2.Think(targets, wallSenses{}); orders[0].Kind != Hold {
+		t.Fatalf("Quiet target chased %+v", orders[0])
+	}
+}
+
+func TestLeashReturn(t *testing.T) {
+	d := NewDirector(1, 1, 5)
+	b := guard("g", "", 0, 0)
+	b.Leash = 5
+	d.Add(b)
+	target := Target{ID: "t", Position: Point{X: 3, Y: 0}, Noise: 1}
+	if orders := d.Think([]Target{target}, wallSenses{}); orders[0].Kind != Chase {
+		t.Fatalf("Order %+v", orders[0])
+	}
+
+	// it follows until it is too far from the spawn
+	b.Position = Point{X: 6, Y: 0}
+	target.Position = Point{X: 7, Y: 0}
+	orders := d.Think([]Target{target}, wallSenses{})
+	if orders[0].Kind != Return || orders[0].Goal != b.Spawn {
+		t.Fatalf("Order %+v, want return", orders[0])
+	}
+	if len(b.Threat) != 0 {
+		t.Fatal("Still angry after the leash")
+	}
+	// returning enemies dont notice anything
+	if orders := d.Think([]Target{target}, wallSenses{}); orders[0].Kind != Return {
+		t.Fatalf("Order %+v while returning", orders[0])
+	}
+
+	b.Position = Point{X: 0.5, Y: 0}
+	if orders := d.Think(nil, wallSenses{}); orders[0].Kind != Hold || b.State != Guarding {
+		t.Fatalf("Order %+v state %d at home", orders[0], b.State)
+	}
+}
+
+func TestGroupAlertRadius(t *testing.T) {
+	d := NewDirector(1, 1, 5)
+	seer := guard("a", "pack", 0, 0)
+	near := guard("b", "pack", -4, 0)
+	far := guard("c", "pack", -20, 0)
+	stranger := guard("d", "", -3, 0)
+	for _, b := range []*Brain{seer, near, far, stranger} {
+		b.Facing = 0
+		d.Add(b)
+	}
+	// only the seer can see it, the others look away from it and are deaf
+	for _, b := range []*Brain{near, far, stranger} {
+		b.Perception.HearingRadius = 0
+		b.Facing = math.Pi
+	}
+	orders := d.Think([]Target{{ID: "t", Position: Point{X: 5, Y: 0}}}, wallSenses{})
+
+	kinds := map[string]OrderKind{}
+	for _, order := range orders {
+		kinds[order.ID] = order.Kind
+	}
+	want := map[string]OrderKind{"a": Chase, "b": Chase, "c": Hold, "d": Hold}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("Orders %v, want %v", kinds, want)
+	}
+	if orders[1].Goal != (Point{X: 5, Y: 0}) {
+		t.Fatalf("Alerted member goes to %v", orders[1].Goal)
+	}
+}
+
+func TestAsleepDoesntThink(t *testing.T) {
+	d := NewDirector(1, 1, 5)
+	b := guard("g", "", 0, 0)
+	b.Asleep = true
+	d.Add(b)
+	if orders := d.Think([]Target{{ID: "t", Position: Point{X: 1, Y: 0}, Noise: 1}}, wallSenses{}); len(orders) != 0 {
+		t.Fatalf("Asleep enemy ordered %v", orders)
+	}
+}
+
+func TestThinkSeeded(t *testing.T) {
+	run := func(seed int64) [][]Order {
+		d := NewDirector(seed, 3, 6)
+		for i, id := range []string{"a", "b", "c", "d"} {
+			d.Add(guard(id, "pack", float64(i*3), float64(i%2)))
+		}
+		all := [][]Order{}
+		target := Target{ID: "t", Position: Point{X: -20, Y: 0}, Noise: 1}
+		for tick := 0; tick < 300; tick++ {
+			target.Position.X += 0.1
+			for _, brain := range d.Brains {
+				// walk a bit towards the goal of the last order
+				brain.Position.X += 0.05 * math.Copysign(1, target.Position.X-brain.Position.X)
+			}
+			if orders := d.Tick([]Target{target}, wallSenses{}); orders != nil {
+				all = append(all, orders)
+			}
+		}
+		return all
+	}
+	first := run(7)
+	if !reflect.DeepEqual(first, run(7)) {
+		t.Fatal("Same seed thought differently")
+	}
+	if len(first) != 100 {
+		t.Fatalf("Thought %d times in 300 ticks, want every 3", len(first))
+	}
+}
+
+func TestRemove(t *testing.T) {
+	d := NewDirector(1, 1, 5)
+	d.Add(guard("g", "", 0, 0))
+	d.Remove("g")
+	d.Remove("missing")
+	if len(d.Brains) != 0 {
+		t.Fatal("Brain still there")
+	}
+}
@@ -0,0 +1,171 @@
+package entities
+
+import (
+	"bilydaniel/rpg/animation"
+	"image"
+	"math"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+const (
+	ClipIdle   = "idle"
+	ClipWalk   = "walk"
+	ClipDead   = "dead"
+	ClipAttack = "attack"
+	// EventHit comes from the frame of the attack clip where the blow lands
+	EventHit = "hit"
+	// ticks without moving before walking turns to idle, in battle the
+	// characters move a tile at a time with pauses
+	walkGraceTicks = 8
+	// moving further in one tick is a teleport (portal, loading), not walking
+	teleportDistance = 2
+)
+
+type frameKey struct {
+	sheet string
+	cell  animation.Cell
+}
+
+// Animations are the clips of a character together with their sheets
+type Animations struct {
+	Set    *animation.Set
+	Sheets map[string]*ebiten.Image //path of the sheet => image
+	frames map[frameKey]*ebiten.Image
+}
+
+func LoadAnimations(path string) (*Animations, error) {
+	set, err := animation.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	animations := &Animations{
+		Set:    set,
+		Sheets: map[string]*ebiten.Image{},
+		frames: map[frameKey]*ebiten.Image{},
+	}
+	for _, clip := range set.Clips {
+		if _, ok := animations.Sheets[clip.Sheet]; ok {
+			continue
+		}
+		sheet, _, err := ebitenutil.NewImageFromFile(clip.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		animations.Sheets[clip.Sheet] = sheet
+	}
+	return animations, nil
+}
+
+// Frame is the part of the sheet with the cell, cut once and kept
+func (a *Animations) Frame(sheet string, cell animation.Cell) *ebiten.Image {
+	key := frameKey{sheet: sheet, cell: cell}
+	if frame, ok := a.frames[key]; ok {
+		return frame
+	}
+	w, h := a.Set.FrameWidth, a.Set.FrameHeight
+	rect := image.Rect(cell.Column*w, cell.Row*h, (cell.Column+1)*w, (cell.Row+1)*h)
+	frame := a.Sheets[sheet].SubImage(rect).(*ebiten.Image)
+	a.frames[key] = frame
+	return frame
+}
+
+// AnimatedSprite is a CircleSprite drawn from clips. It faces the way it
+// moves, walks or stands idle, lies dead, or plays an action like an attack
+// once over all of that.
+type AnimatedSprite struct {
+	CircleSprite
+	Animations *Animations
+	Player     animation.Player
+	Action     string   //one shot clip that is playing, empty for none
+	Events     []string //fired in the last update
+	lastX      float64
+	lastY      float64
+	still      int //ticks without moving
+}
+
+func NewAnimatedSprite(animations *Animations, x float64, y float64, r float64) *AnimatedSprite {
+	sprite := &AnimatedSprite{
+		CircleSprite: CircleSprite{X: x, Y: y, R: r, R_2: r * r},
+		Animations:   animations,
+		lastX:        x,
+		lastY:        y,
+		still:        walkGraceTicks,
+	}
+	sprite.Player.Direction = animation.Down
+	sprite.play(ClipIdle)
+	return sprite
+}
+
+// PlayAction plays the clip once from the start, false when there is no such
+// clip
+func (a *AnimatedSprite) PlayAction(name string) bool {
+	clip, ok := a.Animations.Set.Clips[name]
+	if !ok {
+		return false
+	}
+	a.Action = name
+	a.Player.Restart(clip)
+	return true
+}
+
+// Acting is true while an action plays
+func (a *AnimatedSprite) Acting() bool {
+	return a.Action != ""
+}
+
+// Face turns the sprite towards the tile
+func (a *AnimatedSprite) Face(x float64, y float64) {
+	a.Player.Direction = animation.DirectionOf(x-a.X, y-a.Y, a.Player.Direction)
+}
+
+// Fired tells if the event came in the last update
+func (a *AnimatedSprite) Fired(event string) bool {
+	return slices.Contains(a.Events, event)
+}
+
+// Update picks the clip from the movement since the last update and the state
+// of the character and advances it by a tick
+func (a *AnimatedSprite) Update(dead bool) []string {
+	dx, dy := a.X-a.lastX, a.Y-a.lastY
+	a.lastX, a.lastY = a.X, a.Y
+	if (dx != 0 || dy != 0) && math.Hypot(dx, dy) < teleportDistance {
+		a.still = 0
+		a.Player.Direction = animation.DirectionOf(dx, dy, a.Player.Direction)
+	} else {
+		a.still++
+	}
+
+	switch {
+	case dead:
+		a.Action = ""
+		a.play(ClipDead)
+	case a.Action != "":
+		// the action goes on until its last frame
+	case a.still < walkGraceTicks:
+		a.play(ClipWalk)
+	default:
+		a.play(ClipIdle)
+	}
+	a.Events = a.Player.Update()
+	if a.Action != "" && a.Player.Done() {
+		a.Action = ""
+	}
+	return a.Events
+}
+
+// play switches to the clip, sets without it keep the current one
+func (a *AnimatedSprite) play(name string) {
+	if clip, ok := a.Animations.Set.Clips[name]; ok {
+		a.Player.Play(clip)
+	}
+}
+
+func (a *AnimatedSprite) Image() *ebiten.Image {
+	if a.Player.Clip == nil {
+		return a.Img
+	}
+	return a.Animations.Frame(a.Player.Clip.Sheet, a.Player.Cell())
+}
@@ -0,0 +1,62 @@
+package entities
+
+import (
+	"bilydaniel/rpg/utils"
+	"sort"
+)
+
+type BehaviourKind string
+
+const (
+	Idle   BehaviourKind = "idle"
+	Wander BehaviourKind = "wander"
+	Patrol BehaviourKind = "patrol"
+	Follow BehaviourKind = "follow"
+	Flee   BehaviourKind = "flee"
+)
+
+// Behaviour is what an npc does on its own, read from the properties of its
+// Tiled object
+type Behaviour struct {
+	Kind     BehaviourKind
+	Area     string       //wander inside of this area, around home without one
+	Route    []utils.Node //patrol goes through these tiles in a loop
+	Target   string       //follow or flee from, "party" or an npc id
+	Distance float64      //tiles to keep from the target
+}
+
+// ScheduleEntry switches to the behaviour at the hour of the day
+type ScheduleEntry struct {
+	Hour      float64
+	Behaviour Behaviour
+}
+
+// BehaviourState is where the npc is in its behaviour, it starts over when
+// the behaviour changes
+type BehaviourState struct {
+	Active     int //index into the schedule, -1 is the default behaviour
+	Wait       int //ticks until the next decision
+	RouteIndex int
+}
+
+// SortSchedule orders the entries by hour, BehaviourAt expects it
+func SortSchedule(schedule []ScheduleEntry) {
+	sort.SliceStable(schedule, func(i, j int) bool {
+		return schedule[i].Hour < schedule[j].Hour
+	})
+}
+
+// BehaviourAt returns the behaviour for the hour and its schedule index, the
+// last entry of the day goes on past midnight until the first one
+func (npc *Npc) BehaviourAt(hour float64) (Behaviour, int) {
+	if len(npc.Schedule) == 0 {
+		return npc.Behaviour, -1
+	}
+	active := len(npc.Schedule) - 1
+	for i, entry := range npc.Schedule {
+		if entry.Hour <= hour {
+			active = i
+		}
+	}
+	return npc.Schedule[active].Behaviour, active
+}
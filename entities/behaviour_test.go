@@ -0,0 +1,38 @@
+package entities
+
+import "testing"
+
+func TestBehaviourAt(t *testing.T) {
+	npc := &Npc{Behaviour: Behaviour{Kind: Idle}}
+	if behaviour, active := npc.BehaviourAt(12); behaviour.Kind != Idle || active != -1 {
+		t.Fatalf("Without a schedule %s %d", behaviour.Kind, active)
+	}
+
+	npc.Schedule = []ScheduleEntry{
+		{Hour: 22, Behaviour: Behaviour{Kind: Idle}},
+		{Hour: 6, Behaviour: Behaviour{Kind: Wander}},
+		{Hour: 18.5, Behaviour: Behaviour{Kind: Patrol}},
+	}
+	SortSchedule(npc.Schedule)
+	cases := []struct {
+		hour   float64
+		kind   BehaviourKind
+		active int
+	}{
+		{6, Wander, 0},
+		{12, Wander, 0},
+		{18.4, Wander, 0},
+		{18.5, Patrol, 1},
+		{22, Idle, 2},
+		{23.9, Idle, 2},
+		// the night goes on past midnight
+		{0, Idle, 2},
+		{5.9, Idle, 2},
+	}
+	for _, c := range cases {
+		behaviour, active := npc.BehaviourAt(c.hour)
+		if behaviour.Kind != c.kind || active != c.active {
+			t.Fatalf("At %v %s %d, want %s %d", c.hour, behaviour.Kind, active, c.kind, c.active)
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package entities
 
+import "bilydaniel/rpg/stats"
+
 type Character struct {
 	Id       string
-	Class    string
-	Speed    float64
 	Movement float64
+	Stats    *stats.Stats
+}
+
+// Speed is how many tiles the character walks per tick
+func (c *Character) Speed() float64 {
+	return c.Stats.Get(stats.Speed)
 }
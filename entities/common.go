@@ -4,6 +4,8 @@ import "bilydaniel/rpg/utils"
 
 type Level interface {
 	OccupiedTile(node *utils.Node) bool
+	TileOccupant(node *utils.Node) Sprite
 	WalkableTile(node *utils.Node) bool
+	MovementCost(node *utils.Node) float64
 	SetTileOccupied(sprite Sprite, x, y int)
 }
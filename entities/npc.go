@@ -2,6 +2,8 @@ package entities
 
 import (
 	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/utils"
+	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
@@ -9,27 +11,109 @@ import (
 type Npc struct {
 	Sprite
 	Character
-	LevelName string
+	LevelName    string
+	Dialogue     string //id of the conversation, empty when it has nothing to say
+	Hostile      bool   //attacks the party, the world gives it an enemy brain
+	Path         []utils.Node
+	PathProgress int
+	BlockedTicks int
+	Home         utils.Node //where it spawned or arrived through a portal
+	Behaviour    Behaviour  //used when there is no schedule
+	Schedule     []ScheduleEntry
+	State        BehaviourState
+	occupied     *utils.Node
 }
 
+func (npc *Npc) SetPath(path []utils.Node) {
+	npc.Path = path
+	npc.PathProgress = 0
+	npc.BlockedTicks = 0
+}
+
+// Update walks the npc along its path, the behaviour decides where to. It
+// waits when the next tile is taken and gives the path up after a while.
 func (npc *Npc) Update(level Level) {
-	x := npc.GetX()
+	npc.updateOccupancy(level)
+	if npc.PathProgress >= len(npc.Path) {
+		npc.SetPath(nil)
+		return
+	}
 
-	if x < 0 {
-		npc.Movement = npc.Speed
-	} else if x > 100 {
-		npc.Movement = -npc.Speed
+	target := npc.Path[npc.PathProgress]
+	dx := float64(target.X) - npc.GetX()
+	dy := float64(target.Y) - npc.GetY()
+	dist := math.Hypot(dx, dy)
+	if dist == 0 {
+		npc.PathProgress++
+		return
 	}
-	if int(x+npc.Movement) > int(x) {
-		level.SetTileOccupied(npc, int(x+npc.Movement), int(npc.GetY()))
-		level.SetTileOccupied(nil, int(x), int(npc.GetY()))
+	dxnorm := dx / dist
+	dynorm := dy / dist
+
+	ahead := utils.Node{X: int(math.Round(npc.GetX() + dxnorm)), Y: int(math.Round(npc.GetY() + dynorm))}
+	if dist < 1 {
+		ahead = target
+	}
+	occupant := level.TileOccupant(&ahead)
+	if !level.WalkableTile(&ahead) || (occupant != nil && occupant != npc) {
+		npc.BlockedTicks++
+		if npc.BlockedTicks >= config.BlockedRepathTicks {
+			npc.SetPath(nil)
+		}
+		return
+	}
+	npc.BlockedTicks = 0
+
+	current := utils.Node{X: int(math.Round(npc.GetX())), Y: int(math.Round(npc.GetY()))}
+	speed := npc.Speed() / math.Max(level.MovementCost(&current), 0.1)
+	npc.SetPosition(npc.GetX()+dxnorm*speed, npc.GetY()+dynorm*speed)
+
+	if math.Abs(npc.GetX()-float64(target.X)) <= speed && math.Abs(npc.GetY()-float64(target.Y)) <= speed {
+		npc.SetPosition(float64(target.X), float64(target.Y))
+		npc.PathProgress++
+	}
+}
+
+// updateOccupancy keeps the tile under the npc claimed in the level
+func (npc *Npc) updateOccupancy(level Level) {
+	current := utils.Node{X: int(math.Round(npc.GetX())), Y: int(math.Round(npc.GetY()))}
+	if npc.occupied != nil && *npc.occupied == current {
+		return
+	}
+	npc.LeaveLevel(level)
+	if level.TileOccupant(&current) == nil {
+		level.SetTileOccupied(npc, current.X, current.Y)
+	}
+	npc.occupied = &current
+}
+
+// LeaveLevel frees the tile the npc claimed
+func (npc *Npc) LeaveLevel(level Level) {
+	if npc.occupied != nil && level.TileOccupant(npc.occupied) == npc {
+		level.SetTileOccupied(nil, npc.occupied.X, npc.occupied.Y)
+	}
+	npc.occupied = nil
+}
+
+// Animate advances the animation of the npc when its sprite has one
+func (npc *Npc) Animate() {
+	if sprite, ok := npc.Sprite.(*AnimatedSprite); ok {
+		sprite.Update(!npc.Stats.Alive())
 	}
-	//npc.SetX(x + npc.Movement)
 }
 
 func (npc *Npc) Draw(screen *ebiten.Image, camera config.Camera) {
 	opts := ebiten.DrawImageOptions{}
 
 	camera.WorldToScreenGeom(&opts, int(npc.GetX()*config.TileSize), int(npc.GetY()*config.TileSize))
+	if npc.Hostile {
+		opts.ColorScale.Scale(1, 0.5, 0.5, 1)
+	}
 	screen.DrawImage(npc.Image(), &opts)
 }
+
+// Baseline is the depth used for sorting, the bottom of the tile the
+// character stands on, in world pixels
+func (npc *Npc) Baseline() float64 {
+	return (npc.GetY() + 1) * config.TileSize
+}
@@ -2,16 +2,24 @@ package entities
 
 import (
 	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/items"
+	"bilydaniel/rpg/stats"
 	"bilydaniel/rpg/utils"
 	"fmt"
 	"image/color"
 	"math"
+	"slices"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
+// PCharacterClipsPath are the clips of the playable characters for now
+const PCharacterClipsPath = "assets/images/Shaman/SeparateAnim/clips.json"
+
+var pcharacterAnimations *Animations
+
 type PCharacter struct {
 	Name            string
 	Selected        bool
@@ -21,33 +29,42 @@ type PCharacter struct {
 	DestinationDist *float64
 	Path            []utils.Node
 	PathProgress    int
+	BlockedTicks    int
+	NeedsRepath     bool
+	Repaths         int
+	occupied        *utils.Node
+	Inventory       *items.Inventory
+	Equipment       items.Equipment
 	Sprite
 	Character
 }
 
-func InitPCharacter(name string) (*PCharacter, error) {
+func InitPCharacter(name string, class stats.Class) (*PCharacter, error) {
 	r := 8.0
-	r_2 := r * r
 
 	image, _, err := ebitenutil.NewImageFromFile("assets/images/cavegirl.png")
 
 	if err != nil {
 		return nil, err
 	}
+	if pcharacterAnimations == nil {
+		pcharacterAnimations, err = LoadAnimations(PCharacterClipsPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sprite := NewAnimatedSprite(pcharacterAnimations, 0, 0, r)
+	sprite.Img = image
 	pcharacter := PCharacter{
 		Name:     name,
 		Selected: false,
-		Sprite: &CircleSprite{
-			X:   0,
-			Y:   0,
-			R:   r,
-			R_2: r_2,
-			Img: image,
-		},
+		Sprite:   sprite,
 		Character: Character{
-			Speed: 1 / 30.0,
+			Stats: stats.New(class),
 		},
-		Path: []utils.Node{},
+		Path:      []utils.Node{},
+		Inventory: items.NewInventory(config.InventorySlots, config.InventoryWeight),
+		Equipment: items.Equipment{},
 	}
 	if name == "red" {
 		pcharacter.SetPosition(0, 0)
@@ -61,10 +78,15 @@ func InitPCharacter(name string) (*PCharacter, error) {
 	return &pcharacter, nil
 }
 
-func InitPCharacters() ([]*PCharacter, error) {
+func InitPCharacters(classes map[string]stats.Class) ([]*PCharacter, error) {
 	characters := []*PCharacter{}
 	for i := 0; i < 3; i++ {
-		character, err := InitPCharacter(config.PlayableCharacters[i])
+		name := config.PlayableCharacters[i]
+		class, ok := classes[config.PlayableClasses[name]]
+		if !ok {
+			return nil, fmt.Errorf("Character %s has unknown class %s", name, config.PlayableClasses[name])
+		}
+		character, err := InitPCharacter(name, class)
 		if err != nil {
 			return nil, err
 		}
@@ -74,6 +96,9 @@ func InitPCharacters() ([]*PCharacter, error) {
 }
 
 func (p *PCharacter) Update(level Level) {
+	p.updateOccupancy(level)
+	p.Stats.Tick(1)
+
 	if len(p.Path) > 0 {
 		if p.PathProgress > len(p.Path)-1 {
 			p.Path = []utils.Node{}
@@ -82,25 +107,38 @@ func (p *PCharacter) Update(level Level) {
 		}
 
 		target := p.Path[p.PathProgress]
-		if level.OccupiedTile(&target) {
-			p.ResetWalking()
-			return
-		}
 
 		dx := float64(target.X) - p.GetX()
 		dy := float64(target.Y) - p.GetY()
 		dist := math.Hypot(dx, dy)
 
 		if dist == 0 {
+			p.PathProgress++
 			return
 		}
 
 		dxnorm := dx / dist
 		dynorm := dy / dist
 
-		p.SetPosition(p.GetX()+dxnorm*p.Speed, p.GetY()+dynorm*p.Speed)
+		// the tile the character is about to step into, paths can have long
+		// straight segments so the target itself is not enough
+		ahead := utils.Node{X: int(math.Round(p.GetX() + dxnorm)), Y: int(math.Round(p.GetY() + dynorm))}
+		if dist < 1 {
+			ahead = target
+		}
+		if p.blockedBy(level, &ahead) {
+			p.onBlocked(level, ahead, target)
+			return
+		}
+		p.BlockedTicks = 0
+
+		// slower in mud, faster on roads
+		current := utils.Node{X: int(math.Round(p.GetX())), Y: int(math.Round(p.GetY()))}
+		speed := p.Speed() / math.Max(level.MovementCost(&current), 0.1)
 
-		if math.Abs(p.GetX()-float64(target.X)) <= p.Speed && math.Abs(p.GetY()-float64(target.Y)) <= p.Speed {
+		p.SetPosition(p.GetX()+dxnorm*speed, p.GetY()+dynorm*speed)
+
+		if math.Abs(p.GetX()-float64(target.X)) <= speed && math.Abs(p.GetY()-float64(target.Y)) <= speed {
 			p.SetX(float64(target.X))
 			p.SetY(float64(target.Y))
 			p.PathProgress++
@@ -108,6 +146,92 @@ func (p *PCharacter) Update(level Level) {
 	}
 }
 
+// updateOccupancy keeps the tile under the character claimed in the level
+func (p *PCharacter) updateOccupancy(level Level) {
+	current := utils.Node{X: int(math.Round(p.GetX())), Y: int(math.Round(p.GetY()))}
+	if p.occupied != nil && *p.occupied == current {
+		return
+	}
+	if p.occupied != nil && level.TileOccupant(p.occupied) == p {
+		level.SetTileOccupied(nil, p.occupied.X, p.occupied.Y)
+	}
+	if level.TileOccupant(&current) == nil {
+		level.SetTileOccupied(p, current.X, current.Y)
+	}
+	p.occupied = &current
+}
+
+func (p *PCharacter) blockedBy(level Level, node *utils.Node) bool {
+	occupant := level.TileOccupant(node)
+	return occupant != nil && occupant != p
+}
+
+// onBlocked waits for a bit, then tries to step around the blocked tile, then
+// asks for a new path
+func (p *PCharacter) onBlocked(level Level, blocked utils.Node, target utils.Node) {
+	p.BlockedTicks++
+
+	if p.BlockedTicks == config.BlockedWaitTicks {
+		if blocked != target {
+			// blocked in the middle of a straight segment, go past it to the target
+			if side, ok := p.sidestep(level, blocked, target); ok {
+				p.Path = slices.Insert(p.Path, p.PathProgress, side)
+				return
+			}
+		} else if p.PathProgress < len(p.Path)-1 {
+			// the node itself is taken, go around it to the one after
+			if side, ok := p.sidestep(level, blocked, p.Path[p.PathProgress+1]); ok {
+				p.Path[p.PathProgress] = side
+				return
+			}
+		}
+	}
+
+	if p.BlockedTicks >= config.BlockedRepathTicks {
+		p.BlockedTicks = 0
+		if p.Repaths >= config.MaxRepaths {
+			p.ResetWalking()
+			return
+		}
+		p.NeedsRepath = true
+	}
+}
+
+// sidestep finds a free neighbor tile that doesnt take the character further
+// away from where it rejoins the path
+func (p *PCharacter) sidestep(level Level, blocked utils.Node, rejoin utils.Node) (utils.Node, bool) {
+	current := utils.Node{X: int(math.Round(p.GetX())), Y: int(math.Round(p.GetY()))}
+	currentDist := math.Hypot(float64(rejoin.X-current.X), float64(rejoin.Y-current.Y))
+
+	best := utils.Node{}
+	bestDist := math.MaxFloat64
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			candidate := utils.Node{X: current.X + dx, Y: current.Y + dy}
+			if candidate == blocked || !level.WalkableTile(&candidate) || level.TileOccupant(&candidate) != nil {
+				continue
+			}
+			dist := math.Hypot(float64(rejoin.X-candidate.X), float64(rejoin.Y-candidate.Y))
+			if dist <= currentDist && dist < bestDist {
+				best = candidate
+				bestDist = dist
+			}
+		}
+	}
+	return best, bestDist != math.MaxFloat64
+}
+
+// PathGoal is the last node of the current path
+func (p *PCharacter) PathGoal() (utils.Node, bool) {
+	if len(p.Path) == 0 {
+		return utils.Node{}, false
+	}
+	return p.Path[len(p.Path)-1], true
+}
+
 func (p *PCharacter) Draw(screen *ebiten.Image, camera config.Camera) {
 
 	//pcolor := color.RGBA{0, 255, 0, 255}
@@ -139,9 +263,7 @@ func (p *PCharacter) Draw(screen *ebiten.Image, camera config.Camera) {
 
 	//path
 	if len(p.Path) != 0 {
-		opt := ebiten.GeoM{}
-		opt.Translate(-camera.X*camera.Speed, -camera.Y*camera.Speed)
-		opt.Scale(camera.Scale, camera.Scale)
+		opt := camera.GeoM()
 		if p.PathProgress < 1 {
 
 			//TODO add walkable=false
@@ -169,6 +291,13 @@ func (p *PCharacter) Draw(screen *ebiten.Image, camera config.Camera) {
 	}
 }
 
+// Animate advances the animation of the character when its sprite has one
+func (p *PCharacter) Animate() {
+	if sprite, ok := p.Sprite.(*AnimatedSprite); ok {
+		sprite.Update(!p.Stats.Alive())
+	}
+}
+
 func (p *PCharacter) OnClick() {
 	if p.Selected {
 		p.Selected = false
@@ -180,14 +309,15 @@ func (p *PCharacter) OnClick() {
 func (p *PCharacter) ClickCollision(x int, y int, camera config.Camera) bool {
 
 	switch value := p.Sprite.(type) {
-	case *CircleSprite:
+	case *CircleSprite, *AnimatedSprite:
+		circle, _ := collisionCircle(value)
 
 		worldx, worldy := camera.ScreenToWorld(float64(x), float64(y))
 		dx := worldx - p.GetX()*config.TileSize - config.TileSize/2
 		dy := worldy - p.GetY()*config.TileSize - config.TileSize/2
 
 		distance := math.Pow(dx, 2) + math.Pow(dy, 2)
-		if distance <= value.R_2 {
+		if distance <= circle.R_2 {
 			return true
 		}
 	case *SquareSprite:
@@ -199,29 +329,23 @@ func (p *PCharacter) ClickCollision(x int, y int, camera config.Camera) bool {
 	return false
 }
 
-func (p *PCharacter) RectCollision(startx int, starty int, endx int, endy int, camera config.Camera) bool {
+// RectCollision checks the character against a rectangle in world pixels
+func (p *PCharacter) RectCollision(startx float64, starty float64, endx float64, endy float64) bool {
 	//TODO try to understand this algorithm a bit more, draw it
 
-	circleCollision, ok := p.Sprite.(*CircleSprite)
+	circleCollision, ok := collisionCircle(p.Sprite)
 	if !ok {
 		fmt.Errorf("Unknown collision type")
 		return false
 	}
-	worldx, worldy := camera.ScreenToWorld(float64(startx), float64(starty))
-	startx = int(worldx)
-	starty = int(worldy)
-
-	worldx, worldy = camera.ScreenToWorld(float64(endx), float64(endy))
-	endx = int(worldx)
-	endy = int(worldy)
 
 	charx := p.GetX()*config.TileSize + config.TileSize/2
 	chary := p.GetY()*config.TileSize + config.TileSize/2
 
-	rectLeft := math.Min(float64(startx), float64(endx))
-	rectRight := math.Max(float64(startx), float64(endx))
-	rectTop := math.Min(float64(starty), float64(endy))
-	rectBottom := math.Max(float64(starty), float64(endy))
+	rectLeft := math.Min(startx, endx)
+	rectRight := math.Max(startx, endx)
+	rectTop := math.Min(starty, endy)
+	rectBottom := math.Max(starty, endy)
 
 	closestx := math.Max(rectLeft, math.Min(charx, rectRight))
 	closesty := math.Max(rectTop, math.Min(chary, rectBottom))
@@ -234,7 +358,32 @@ func (p *PCharacter) RectCollision(startx int, starty int, endx int, endy int, c
 	return distance <= circleCollision.R
 }
 
+func (p *PCharacter) SetPath(path []utils.Node) {
+	p.Path = path
+	p.PathProgress = 0
+	p.BlockedTicks = 0
+}
+
 func (p *PCharacter) ResetWalking() {
 	p.Path = []utils.Node{}
 	p.PathProgress = 0
+	p.BlockedTicks = 0
+	p.NeedsRepath = false
+	p.Repaths = 0
+}
+
+// LeaveLevel frees the tile the character stood on and stops walking, the
+// path belongs to the old level
+func (p *PCharacter) LeaveLevel(level Level) {
+	if p.occupied != nil && level.TileOccupant(p.occupied) == p {
+		level.SetTileOccupied(nil, p.occupied.X, p.occupied.Y)
+	}
+	p.occupied = nil
+	p.ResetWalking()
+}
+
+// Baseline is the depth used for sorting, the bottom of the tile the
+// character stands on, in world pixels
+func (p *PCharacter) Baseline() float64 {
+	return (p.GetY() + 1) * config.TileSize
 }
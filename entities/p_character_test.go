@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"bilydaniel/rpg/animation"
+	"bilydaniel/rpg/config"
+	"testing"
+)
+
+func animatedPCharacter(x, y float64) *PCharacter {
+	animations := &Animations{Set: &animation.Set{Clips: map[string]*animation.Clip{}}}
+	return &PCharacter{Sprite: NewAnimatedSprite(animations, x, y, 8)}
+}
+
+func TestAnimatedClickCollision(t *testing.T) {
+	camera := config.NewCamera()
+	for _, pchar := range []*PCharacter{animatedPCharacter(2, 3), {Sprite: &CircleSprite{X: 2, Y: 3, R: 8, R_2: 64}}} {
+		sx, sy := camera.WorldToScreen(2*config.TileSize+config.TileSize/2, 3*config.TileSize+config.TileSize/2)
+		if !pchar.ClickCollision(int(sx)+3, int(sy)-3, *camera) {
+			t.Fatalf("%T: click on the character missed", pchar.Sprite)
+		}
+		if pchar.ClickCollision(int(sx)+9, int(sy), *camera) {
+			t.Fatalf("%T: click next to the character hit", pchar.Sprite)
+		}
+	}
+}
+
+func TestAnimatedRectCollision(t *testing.T) {
+	pchar := animatedPCharacter(2, 3)
+	x, y := 2*config.TileSize+config.TileSize/2, 3*config.TileSize+config.TileSize/2
+	if !pchar.RectCollision(float64(x)-20, float64(y)-20, float64(x)+5, float64(y)-6) {
+		t.Fatal("Rectangle over the character missed")
+	}
+	if pchar.RectCollision(float64(x)+9, float64(y)-20, float64(x)+40, float64(y)+20) {
+		t.Fatal("Rectangle next to the character hit")
+	}
+}
@@ -125,3 +125,15 @@ func (cs *CircleSprite) SetY(y float64) {
 func (cs *CircleSprite) Center() (x float64, y float64) {
 	return cs.X, cs.Y
 }
+
+// collisionCircle is the circle of the sprite, animated sprites collide like
+// the circle they are drawn on
+func collisionCircle(sprite Sprite) (*CircleSprite, bool) {
+	switch value := sprite.(type) {
+	case *CircleSprite:
+		return value, true
+	case *AnimatedSprite:
+		return &value.CircleSprite, true
+	}
+	return nil, false
+}
@@ -0,0 +1,71 @@
+package items
+
+import (
+	"bilydaniel/rpg/stats"
+	"fmt"
+)
+
+// Equipment is what a character wears, slot => item ID
+type Equipment map[Slot]string
+
+func modifierName(slot Slot) string {
+	return "equipment:" + string(slot)
+}
+
+// Equip moves the item from the inventory into its slot and applies its
+// modifiers, whatever was in the slot goes back to the inventory
+func (eq Equipment) Equip(catalog Catalog, s *stats.Stats, inv *Inventory, item string) error {
+	definition, ok := catalog[item]
+	if !ok {
+		return fmt.Errorf("Unknown item %s", item)
+	}
+	if definition.Slot == NoSlot {
+		return fmt.Errorf("%s cant be equipped", definition.Name)
+	}
+	if inv.Count(item) == 0 {
+		return fmt.Errorf("%s is not in the inventory", definition.Name)
+	}
+
+	inv.Remove(item, 1)
+	if _, ok := eq[definition.Slot]; ok {
+		err := eq.Unequip(catalog, s, inv, definition.Slot)
+		if err != nil {
+			// put it back, nothing changed
+			inv.Add(catalog, item, 1)
+			return err
+		}
+	}
+	eq[definition.Slot] = item
+	for _, modifier := range definition.Modifiers {
+		modifier.Name = modifierName(definition.Slot)
+		modifier.Duration = 0
+		s.AddModifier(modifier)
+	}
+	return nil
+}
+
+// Unequip puts the item of the slot back into the inventory
+func (eq Equipment) Unequip(catalog Catalog, s *stats.Stats, inv *Inventory, slot Slot) error {
+	item, ok := eq[slot]
+	if !ok {
+		return nil
+	}
+	added, err := inv.Add(catalog, item, 1)
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return fmt.Errorf("No room for %s", catalog[item].Name)
+	}
+	delete(eq, slot)
+	s.RemoveModifier(modifierName(slot))
+	return nil
+}
+
+func (eq Equipment) Clone() Equipment {
+	clone := Equipment{}
+	for slot, item := range eq {
+		clone[slot] = item
+	}
+	return clone
+}
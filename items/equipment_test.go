@@ -0,0 +1,120 @@
+package items
+
+import (
+	"bilydaniel/rpg/stats"
+	"math"
+	"testing"
+)
+
+var gear = Catalog{
+	"potion":     {ID: "potion", Name: "Potion", Weight: 0.5, MaxStack: 20},
+	"dagger":     {ID: "dagger", Name: "Dagger", Weight: 1, Slot: Weapon, Modifiers: []stats.Modifier{{Stat: stats.Agility, Add: 1}}},
+	"greatsword": {ID: "greatsword", Name: "Greatsword", Weight: 8, Slot: Weapon, Modifiers: []stats.Modifier{{Stat: stats.Strength, Add: 4}}},
+	"boots":      {ID: "boots", Name: "Boots", Weight: 1, Slot: Feet, Modifiers: []stats.Modifier{{Stat: stats.Agility, Add: 2, Multiply: 0.1}}},
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func character() *stats.Stats {
+	return stats.New(stats.Class{Attributes: map[stats.Stat]float64{stats.Strength: 10, stats.Agility: 10}})
+}
+
+func TestEquipSwaps(t *testing.T) {
+	s := character()
+	inv := NewInventory(5, 0)
+	inv.Add(gear, "greatsword", 1)
+	inv.Add(gear, "dagger", 1)
+	eq := Equipment{}
+
+	if err := eq.Equip(gear, s, inv, "greatsword"); err != nil {
+		t.Fatal(err)
+	}
+	if eq[Weapon] != "greatsword" || inv.Count("greatsword") != 0 || s.Get(stats.Strength) != 14 {
+		t.Fatalf("Equipment %v, strength %v", eq, s.Get(stats.Strength))
+	}
+
+	if err := eq.Equip(gear, s, inv, "dagger"); err != nil {
+		t.Fatal(err)
+	}
+	if eq[Weapon] != "dagger" || inv.Count("greatsword") != 1 || inv.Count("dagger") != 0 {
+		t.Fatalf("Equipment %v, inventory %v", eq, inv.Stacks)
+	}
+	if s.Get(stats.Strength) != 10 || s.Get(stats.Agility) != 11 {
+		t.Fatalf("Strength %v agility %v after the swap", s.Get(stats.Strength), s.Get(stats.Agility))
+	}
+
+	// other slots stack their modifiers
+	inv.Add(gear, "boots", 1)
+	eq.Equip(gear, s, inv, "boots")
+	if got := s.Get(stats.Agility); !near(got, 14.3) {
+		t.Fatalf("Agility %v, want (10+1+2)*1.1", got)
+	}
+}
+
+func TestEquipRollback(t *testing.T) {
+	s := character()
+	inv := NewInventory(5, 10)
+	inv.Add(gear, "greatsword", 1)
+	eq := Equipment{}
+	eq.Equip(gear, s, inv, "greatsword")
+
+	// the greatsword wont fit back once the dagger is out
+	inv.Add(gear, "dagger", 1)
+	inv.Add(gear, "potion", 18)
+	err := eq.Equip(gear, s, inv, "dagger")
+	if err == nil {
+		t.Fatal("Swapped without room for the greatsword")
+	}
+	if eq[Weapon] != "greatsword" || inv.Count("dagger") != 1 || inv.Count("potion") != 18 {
+		t.Fatalf("Equipment %v, inventory %v after a failed swap", eq, inv.Stacks)
+	}
+	if s.Get(stats.Strength) != 14 || s.Get(stats.Agility) != 10 {
+		t.Fatalf("Modifiers changed %+v", s.Modifiers)
+	}
+}
+
+func TestEquipRejects(t *testing.T) {
+	s := character()
+	inv := NewInventory(5, 0)
+	inv.Add(gear, "potion", 1)
+	eq := Equipment{}
+	for _, item := range []string{"potion", "dagger", "nothing"} {
+		if err := eq.Equip(gear, s, inv, item); err == nil {
+			t.Fatalf("Equipped %s", item)
+		}
+	}
+	if len(eq) != 0 || inv.Count("potion") != 1 {
+		t.Fatalf("Equipment %v, inventory %v", eq, inv.Stacks)
+	}
+}
+
+func TestUnequip(t *testing.T) {
+	s := character()
+	inv := NewInventory(1, 0)
+	inv.Add(gear, "boots", 1)
+	eq := Equipment{}
+	eq.Equip(gear, s, inv, "boots")
+
+	// no slot free
+	inv.Add(gear, "potion", 1)
+	if err := eq.Unequip(gear, s, inv, Feet); err == nil {
+		t.Fatal("Unequipped into a full inventory")
+	}
+	if eq[Feet] != "boots" || !near(s.Get(stats.Agility), 13.2) {
+		t.Fatalf("Equipment %v, agility %v", eq, s.Get(stats.Agility))
+	}
+
+	inv.Remove("potion", 1)
+	if err := eq.Unequip(gear, s, inv, Feet); err != nil {
+		t.Fatal(err)
+	}
+	if len(eq) != 0 || inv.Count("boots") != 1 || len(s.Modifiers) != 0 || s.Get(stats.Agility) != 10 {
+		t.Fatalf("Equipment %v, modifiers %+v", eq, s.Modifiers)
+	}
+	// an empty slot is fine
+	if err := eq.Unequip(gear, s, inv, Head); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,113 @@
+package items
+
+import "fmt"
+
+// Stack is some amount of one item in one inventory slot
+type Stack struct {
+	Item  string
+	Count int
+}
+
+// Inventory holds stacks, it is full when it runs out of slots or when the
+// weight would go over MaxWeight (0 means no weight limit)
+type Inventory struct {
+	Stacks    []Stack
+	MaxSlots  int
+	MaxWeight float64
+}
+
+func NewInventory(maxSlots int, maxWeight float64) *Inventory {
+	return &Inventory{
+		Stacks:    []Stack{},
+		MaxSlots:  maxSlots,
+		MaxWeight: maxWeight,
+	}
+}
+
+func (inv *Inventory) Weight(catalog Catalog) float64 {
+	weight := 0.0
+	for _, stack := range inv.Stacks {
+		weight += catalog[stack.Item].Weight * float64(stack.Count)
+	}
+	return weight
+}
+
+func (inv *Inventory) Count(item string) int {
+	count := 0
+	for _, stack := range inv.Stacks {
+		if stack.Item == item {
+			count += stack.Count
+		}
+	}
+	return count
+}
+
+// Add puts as many of the items in as fit, topping up existing stacks first,
+// and returns how many got in
+func (inv *Inventory) Add(catalog Catalog, item string, count int) (int, error) {
+	definition, ok := catalog[item]
+	if !ok {
+		return 0, fmt.Errorf("Unknown item %s", item)
+	}
+	if inv.MaxWeight > 0 && definition.Weight > 0 {
+		fits := int((inv.MaxWeight - inv.Weight(catalog)) / definition.Weight)
+		count = min(count, max(fits, 0))
+	}
+
+	added := 0
+	for i := range inv.Stacks {
+		if added == count {
+			break
+		}
+		if inv.Stacks[i].Item != item {
+			continue
+		}
+		room := min(definition.StackSize()-inv.Stacks[i].Count, count-added)
+		if room > 0 {
+			inv.Stacks[i].Count += room
+			added += room
+		}
+	}
+	for added < count && len(inv.Stacks) < inv.MaxSlots {
+		amount := min(definition.StackSize(), count-added)
+		inv.Stacks = append(inv.Stacks, Stack{Item: item, Count: amount})
+		added += amount
+	}
+	return added, nil
+}
+
+// Remove takes up to count of the item out, from the last stacks first, and
+// returns how many were taken
+func (inv *Inventory) Remove(item string, count int) int {
+	removed := 0
+	for i := len(inv.Stacks) - 1; i >= 0 && removed < count; i-- {
+		if inv.Stacks[i].Item != item {
+			continue
+		}
+		amount := min(inv.Stacks[i].Count, count-removed)
+		inv.Stacks[i].Count -= amount
+		removed += amount
+		if inv.Stacks[i].Count == 0 {
+			inv.Stacks = append(inv.Stacks[:i], inv.Stacks[i+1:]...)
+		}
+	}
+	return removed
+}
+
+// Transfer moves up to count of the item between two inventories, what doesnt
+// fit stays where it was
+func Transfer(catalog Catalog, from *Inventory, to *Inventory, item string, count int) (int, error) {
+	count = min(count, from.Count(item))
+	added, err := to.Add(catalog, item, count)
+	if err != nil {
+		return 0, err
+	}
+	from.Remove(item, added)
+	return added, nil
+}
+
+func (inv *Inventory) Clone() *Inventory {
+	clone := *inv
+	clone.Stacks = append([]Stack{}, inv.Stacks...)
+	return &clone
+}
@@ -0,0 +1,100 @@
+package items
+
+import (
+	"reflect"
+	"testing"
+)
+
+var catalog = Catalog{
+	"potion":      {ID: "potion", Name: "Potion", Weight: 0.5, MaxStack: 10},
+	"arrow":       {ID: "arrow", Name: "Arrow", MaxStack: 50},
+	"short_sword": {ID: "short_sword", Name: "Short sword", Weight: 3, Slot: Weapon},
+}
+
+func TestAddSlots(t *testing.T) {
+	inv := NewInventory(2, 0)
+	added, err := inv.Add(catalog, "potion", 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 20 || inv.Count("potion") != 20 || len(inv.Stacks) != 2 {
+		t.Fatalf("Added %d, stacks %v", added, inv.Stacks)
+	}
+	if added, _ := inv.Add(catalog, "arrow", 1); added != 0 {
+		t.Fatalf("Added %d into a full inventory", added)
+	}
+	if _, err := inv.Add(catalog, "nothing", 1); err == nil {
+		t.Fatal("Unknown item added")
+	}
+}
+
+func TestAddWeight(t *testing.T) {
+	inv := NewInventory(10, 10)
+	added, _ := inv.Add(catalog, "short_sword", 5)
+	if added != 3 || len(inv.Stacks) != 3 {
+		t.Fatalf("Added %d swords, stacks %v", added, inv.Stacks)
+	}
+	// 1 left, two potions fit
+	added, _ = inv.Add(catalog, "potion", 5)
+	if added != 2 || inv.Weight(catalog) != 10 {
+		t.Fatalf("Added %d potions, weight %v", added, inv.Weight(catalog))
+	}
+	// weightless items dont care
+	if added, _ := inv.Add(catalog, "arrow", 100); added != 100 {
+		t.Fatalf("Added %d arrows", added)
+	}
+}
+
+func TestAddTopsUp(t *testing.T) {
+	inv := NewInventory(4, 0)
+	inv.Stacks = []Stack{{"potion", 4}, {"arrow", 10}, {"potion", 9}}
+	added, _ := inv.Add(catalog, "potion", 8)
+	want := []Stack{{"potion", 10}, {"arrow", 10}, {"potion", 10}, {"potion", 1}}
+	if added != 8 || !reflect.DeepEqual(inv.Stacks, want) {
+		t.Fatalf("Added %d, stacks %v, want %v", added, inv.Stacks, want)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	inv := NewInventory(4, 0)
+	inv.Stacks = []Stack{{"potion", 10}, {"arrow", 5}, {"potion", 3}}
+	if removed := inv.Remove("potion", 5); removed != 5 {
+		t.Fatalf("Removed %d", removed)
+	}
+	want := []Stack{{"potion", 8}, {"arrow", 5}}
+	if !reflect.DeepEqual(inv.Stacks, want) {
+		t.Fatalf("Stacks %v, want %v", inv.Stacks, want)
+	}
+	if removed := inv.Remove("potion", 100); removed != 8 || inv.Count("potion") != 0 {
+		t.Fatalf("Removed %d of 8", removed)
+	}
+	if removed := inv.Remove("short_sword", 1); removed != 0 {
+		t.Fatalf("Removed %d missing items", removed)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	from := NewInventory(4, 0)
+	from.Add(catalog, "potion", 20)
+	to := NewInventory(1, 0)
+	moved, err := Transfer(catalog, from, to, "potion", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 10 || to.Count("potion") != 10 || from.Count("potion") != 10 {
+		t.Fatalf("Moved %d, %d there and %d left", moved, to.Count("potion"), from.Count("potion"))
+	}
+	if _, err := Transfer(catalog, from, to, "nothing", 1); err == nil {
+		t.Fatal("Unknown item transferred")
+	}
+}
+
+func TestClone(t *testing.T) {
+	inv := NewInventory(4, 0)
+	inv.Add(catalog, "potion", 3)
+	clone := inv.Clone()
+	clone.Add(catalog, "potion", 3)
+	if inv.Count("potion") != 3 {
+		t.Fatal("Clone shares the stacks")
+	}
+}
@@ -0,0 +1,82 @@
+// Package items has the item definitions, inventories and equipment, no
+// drawing in here
+package items
+
+import (
+	"bilydaniel/rpg/stats"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ItemsPath is the data file with the item definitions
+const ItemsPath = "assets/data/items.json"
+
+type Slot string
+
+const (
+	NoSlot    Slot = ""
+	Head      Slot = "head"
+	Body      Slot = "body"
+	Weapon    Slot = "weapon"
+	Offhand   Slot = "offhand"
+	Feet      Slot = "feet"
+	Accessory Slot = "accessory"
+)
+
+var Slots = []Slot{Head, Body, Weapon, Offhand, Feet, Accessory}
+
+// Definition is one kind of item, inventories only keep the ID
+type Definition struct {
+	ID          string
+	Name        string
+	Description string
+	Weight      float64
+	MaxStack    int //0 and 1 dont stack
+	Slot        Slot
+	Modifiers   []stats.Modifier //while equipped, Name and Duration are ignored
+	Color       string           //#rrggbb, what it looks like on the ground
+}
+
+func (d Definition) StackSize() int {
+	return max(d.MaxStack, 1)
+}
+
+// Catalog is every item definition by ID
+type Catalog map[string]Definition
+
+func LoadCatalog(path string) (Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list := []Definition{}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("Reading items %s: %w", path, err)
+	}
+
+	catalog := Catalog{}
+	for _, definition := range list {
+		if _, ok := catalog[definition.ID]; ok {
+			return nil, fmt.Errorf("Item %s is defined twice", definition.ID)
+		}
+		if definition.Slot != NoSlot && !validSlot(definition.Slot) {
+			return nil, fmt.Errorf("Item %s has unknown slot %s", definition.ID, definition.Slot)
+		}
+		if definition.Slot != NoSlot && definition.MaxStack > 1 {
+			return nil, fmt.Errorf("Item %s can be equipped so it cant stack", definition.ID)
+		}
+		catalog[definition.ID] = definition
+	}
+	return catalog, nil
+}
+
+func validSlot(slot Slot) bool {
+	for _, s := range Slots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
@@ -4,10 +4,15 @@ import (
 	"bilydaniel/rpg/assets"
 	"bilydaniel/rpg/config"
 	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/quest"
+	"bilydaniel/rpg/save"
+	"bilydaniel/rpg/ui"
 	"bilydaniel/rpg/utils"
 	"bilydaniel/rpg/world"
 	"log"
+	"math"
 	"strconv"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -20,11 +25,18 @@ type Game struct {
 	World       *world.World
 	Drag        *utils.Drag
 	Assets      *assets.Assets
-	PathFinder  *world.PathFinder
+	PathSystem  *world.PathSystem
+	Formation   world.Formation
+	RenderQueue *world.RenderQueue
+	Battle      *world.Battle //nil while exploring
+	Inventory   *ui.InventoryScreen
+	Dialogue    *ui.DialogueBox
+	Journal     *ui.JournalScreen
+	Talk        *world.TalkRequest //party member on the way to talk to an npc
 }
 
 func initGame() (*Game, error) {
-	worldInstance, err := world.InitWorld()
+	worldInstance, err := world.InitWorld(time.Now().UnixNano())
 	if err != nil {
 		return nil, err
 	}
@@ -34,43 +46,121 @@ func initGame() (*Game, error) {
 		return nil, err
 	}
 
-	pcharacters, err := entities.InitPCharacters()
+	pcharacters, err := entities.InitPCharacters(worldInstance.Classes)
 	if err != nil {
 		return nil, err
 	}
+
+	camera := config.NewCamera()
+	level := worldInstance.CurrentLevel
+	for _, pchar := range pcharacters {
+		level.LightingSystem.Attach(pchar, world.Torch())
+	}
+	camera.SetBounds(float64(level.Width*config.TileSize), float64(level.Height*config.TileSize))
+
 	return &Game{
 		PCharacters: pcharacters,
 		World:       worldInstance,
-		Camera:      &config.Camera{X: 0, Y: 0, Scale: 1.0, Speed: 2.0}, //TODO make an init function
+		Camera:      camera,
 		Drag:        &utils.Drag{},
 		Assets:      assets,
-		PathFinder:  &world.PathFinder{},
+		PathSystem:  world.NewPathSystem(config.PathWorkers),
+		RenderQueue: &world.RenderQueue{},
+		Inventory:   &ui.InventoryScreen{},
+		Dialogue:    &ui.DialogueBox{},
+		Journal:     &ui.JournalScreen{},
 	}, nil
 }
 
 func (g *Game) Update() error {
+	g.Journal.Notify(g.World.Notices)
+	g.World.Notices = nil
+	g.World.AnimateNpcs()
+	for _, pchar := range g.PCharacters {
+		pchar.Animate()
+	}
+
+	if g.World.Transition != nil {
+		arrived, err := g.World.UpdateTransition(g.PCharacters)
+		if err != nil {
+			return err
+		}
+		if arrived {
+			level := g.World.CurrentLevel
+			g.Camera.SetBounds(float64(level.Width*config.TileSize), float64(level.Height*config.TileSize))
+			leader := g.PCharacters[0]
+			g.Camera.CenterOn(leader.GetX()*config.TileSize+config.TileSize/2, leader.GetY()*config.TileSize+config.TileSize/2)
+			g.Camera.X, g.Camera.Y = g.Camera.TargetX, g.Camera.TargetY
+		}
+		g.World.Clock.Update()
+		return nil
+	}
+
+	// CAMERA
 	if ebiten.IsKeyPressed(ebiten.KeyA) {
-		g.Camera.X -= 2
+		g.Camera.Move(-g.Camera.Speed, 0)
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyD) {
-		g.Camera.X += 2
+		g.Camera.Move(g.Camera.Speed, 0)
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyW) {
-		g.Camera.Y -= 2
+		g.Camera.Move(0, -g.Camera.Speed)
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyS) {
-		g.Camera.Y += 2
+		g.Camera.Move(0, g.Camera.Speed)
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyR) {
-		if g.Camera.Scale > 0.8 {
-			g.Camera.Scale -= 0.01
-		}
+		g.Camera.ZoomAt(0.98, g.Camera.ViewW/2, g.Camera.ViewH/2)
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyF) {
-		if g.Camera.Scale < 2 {
-			g.Camera.Scale += 0.01
+		g.Camera.ZoomAt(1.02, g.Camera.ViewW/2, g.Camera.ViewH/2)
+	}
+	if _, wheely := ebiten.Wheel(); wheely != 0 {
+		mx, my := ebiten.CursorPosition()
+		g.Camera.ZoomAt(math.Pow(1.1, wheely), float64(mx), float64(my))
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		g.Camera.Following = !g.Camera.Following
+	}
+	if ebiten.IsFocused() {
+		g.Camera.EdgeScroll(ebiten.CursorPosition())
+	}
+	if g.Camera.Following {
+		for _, pchar := range g.PCharacters {
+			if pchar.Selected {
+				g.Camera.CenterOn(pchar.GetX()*config.TileSize+config.TileSize/2, pchar.GetY()*config.TileSize+config.TileSize/2)
+				break
+			}
 		}
 	}
+	g.Camera.Update()
+
+	if g.Battle != nil {
+		return g.updateBattle()
+	}
+
+	if g.Dialogue.Active() {
+		return g.Dialogue.Update()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyI) {
+		g.Inventory.Open = !g.Inventory.Open
+		g.Journal.Open = false
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyJ) {
+		g.Journal.Open = !g.Journal.Open
+		g.Inventory.Open = false
+	}
+	if g.Inventory.Open {
+		g.Inventory.Update(g.PCharacters, g.World.Items)
+		return nil
+	}
+	if g.Journal.Open {
+		g.Journal.Update(g.World.Journal)
+		return nil
+	}
+
+	g.World.Clock.Update()
 
 	//TODO gonna need to change clicking, think it through
 	//Probably should split it up and not generalize
@@ -78,7 +168,7 @@ func (g *Game) Update() error {
 	// probably gonna need some soft of ID system
 
 	// SELECT
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && !g.startTalk() {
 		for _, pchar := range g.PCharacters {
 			pchar.Selected = false
 		}
@@ -96,68 +186,245 @@ func (g *Game) Update() error {
 	// TODO combine with selecting
 	if inpututil.MouseButtonPressDuration(ebiten.MouseButtonLeft) > 3 && !g.Drag.Dragging {
 		g.Drag.Dragging = true
-		g.Drag.Startx, g.Drag.Starty = ebiten.CursorPosition()
+		mx, my := ebiten.CursorPosition()
+		g.Drag.Startx, g.Drag.Starty = g.Camera.ScreenToWorld(float64(mx), float64(my))
 	}
 
 	if g.Drag.Dragging {
-		g.Drag.Endx, g.Drag.Endy = ebiten.CursorPosition()
+		mx, my := ebiten.CursorPosition()
+		g.Drag.Endx, g.Drag.Endy = g.Camera.ScreenToWorld(float64(mx), float64(my))
 	}
 
 	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && g.Drag.Dragging {
 		g.Drag.Dragging = false
 		for _, pchar := range g.PCharacters {
-			if pchar.RectCollision(g.Drag.Startx, g.Drag.Starty, g.Drag.Endx, g.Drag.Endy, *g.Camera) {
+			if pchar.RectCollision(g.Drag.Startx, g.Drag.Starty, g.Drag.Endx, g.Drag.Endy) {
 				pchar.Selected = true
 			}
 		}
 
 	}
+	// SAVING
+	if inpututil.IsKeyJustPressed(ebiten.KeyF5) {
+		g.Save(save.QuickSlot)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF9) {
+		g.Load(save.QuickSlot)
+	}
+
+	// COMBAT
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		err := g.startBattle()
+		if err != nil || g.Battle != nil {
+			return err
+		}
+	}
+
+	// FORMATION
+	if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		g.Formation = g.Formation.Next()
+	}
+
 	// MOVEMENT
 	//TODO MOVE THIS SOMEWHERE ELSE
 	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		selected := []*entities.PCharacter{}
+		positions := []utils.Point{}
 		for _, pchar := range g.PCharacters {
 			if pchar.Selected {
+				selected = append(selected, pchar)
+				positions = append(positions, utils.Point{X: pchar.GetX(), Y: pchar.GetY()})
+			}
+		}
 
-				mx, my := ebiten.CursorPosition()
-				/*
-					pchar.SetDestination(mx, my, *g.Camera)
-				*/
+		if len(selected) > 0 {
+			g.Talk = nil
+			mx, my := ebiten.CursorPosition()
+			worldx, worldy := g.Camera.ScreenToWorld(float64(mx), float64(my))
+			destNode := g.World.CurrentLevel.NodeFromPoint(utils.Point{X: worldx, Y: worldy})
 
-				//startNode := g.World.CurrentLevel.NodeFromPoint(utils.Point{X: pchar.GetX(), Y: pchar.GetY()})
-				startNode := &utils.Node{X: int(pchar.GetX()), Y: int(pchar.GetY())}
+			goals := g.World.CurrentLevel.FormationGoals(g.Formation, *destNode, positions)
+			for i, pchar := range selected {
+				pchar.Repaths = 0
+				startNode := utils.Node{X: int(math.Round(pchar.GetX())), Y: int(math.Round(pchar.GetY()))}
+				g.PathSystem.RequestPath(pchar, g.World.CurrentLevel, startNode, goals[i], world.PathThetaStar)
+			}
+		}
+	}
 
-				worldx, worldy := g.Camera.ScreenToWorld(float64(mx), float64(my))
-				destNode := g.World.CurrentLevel.NodeFromPoint(utils.Point{X: worldx, Y: worldy})
+	g.PathSystem.Update()
 
-				//TODO add SmoothPath, test it out
-				g.World.CurrentLevel.ResetValues()
-				reversedpath := g.PathFinder.AlfaStar(*g.World.CurrentLevel, *startNode, *destNode)
+	for _, pchar := range g.PCharacters {
+		pchar.Update(g.World.CurrentLevel)
+		for _, stack := range g.World.CurrentLevel.PickUpItems(pchar, g.World.Items) {
+			g.World.Fire(quest.Event{Kind: quest.Collect, Target: stack.Item, Count: stack.Count})
+		}
+	}
 
-				path := []utils.Node{}
-				if len(reversedpath) > 0 {
-					// starts at 1, dont need the 0th element (my own location)
-					for i := len(reversedpath) - 2; i >= 0; i-- {
-						path = append(path, reversedpath[i])
-					}
-				}
+	if g.Talk != nil && g.Talk.Arrived() {
+		pchar := g.Talk.PCharacter
+		conversation, err := g.World.Talk(pchar, g.Talk.Npc)
+		g.Talk = nil
+		if err != nil {
+			return err
+		}
+		g.PathSystem.CancelOwner(pchar)
+		pchar.ResetWalking()
+		g.Dialogue.Open(conversation)
+	}
 
-				pchar.Path = path
-				pchar.PathProgress = 0
+	for _, pchar := range g.PCharacters {
+		if pchar.NeedsRepath {
+			pchar.NeedsRepath = false
+			pchar.Repaths++
+			goal, ok := pchar.PathGoal()
+			if ok {
+				startNode := utils.Node{X: int(math.Round(pchar.GetX())), Y: int(math.Round(pchar.GetY()))}
+				g.PathSystem.RequestPathAround(pchar, pchar, g.World.CurrentLevel, startNode, goal, world.PathThetaStar)
 			}
 		}
 	}
 
+	err := g.World.UpdateNpcs(g.PathSystem, g.PCharacters)
+	if err != nil {
+		return err
+	}
+	if g.World.UpdateEnemies(g.PathSystem, g.PCharacters) {
+		err := g.startBattle()
+		if err != nil || g.Battle != nil {
+			return err
+		}
+	}
+
+	g.World.CheckPortals(g.PCharacters)
+	g.World.CheckAreas(g.PCharacters)
+	if g.World.Transition != nil {
+		for _, pchar := range g.PCharacters {
+			g.PathSystem.CancelOwner(pchar)
+		}
+		g.Talk = nil
+	}
+
+	g.World.CurrentLevel.UpdateFog(g.PCharacters)
+
+	return nil
+}
+
+// startTalk sends the first selected party member to the clicked npc, false
+// when no npc with something to say was clicked
+func (g *Game) startTalk() bool {
+	var pchar *entities.PCharacter
+	for _, p := range g.PCharacters {
+		if p.Selected {
+			pchar = p
+			break
+		}
+	}
+	if pchar == nil {
+		return false
+	}
+	mx, my := ebiten.CursorPosition()
+	worldx, worldy := g.Camera.ScreenToWorld(float64(mx), float64(my))
+	npc, ok := g.World.NpcAt(utils.Point{X: worldx, Y: worldy})
+	if !ok || npc.Dialogue == "" {
+		return false
+	}
+
+	g.Talk = &world.TalkRequest{PCharacter: pchar, Npc: npc}
+	if !g.Talk.Arrived() {
+		goal, ok := g.World.TalkGoal(npc)
+		if !ok {
+			g.Talk = nil
+			return true
+		}
+		pchar.Repaths = 0
+		startNode := utils.Node{X: int(math.Round(pchar.GetX())), Y: int(math.Round(pchar.GetY()))}
+		g.PathSystem.RequestPath(pchar, g.World.CurrentLevel, startNode, goal, world.PathThetaStar)
+	}
+	return true
+}
+
+// startBattle switches to the turn based mode when there is anyone to fight
+func (g *Game) startBattle() error {
+	battle, err := g.World.StartBattle(g.PCharacters, time.Now().UnixNano())
+	if err != nil || battle == nil {
+		return err
+	}
 	for _, pchar := range g.PCharacters {
-		pchar.Update(g.World.CurrentLevel)
+		g.PathSystem.CancelOwner(pchar)
+	}
+	for _, id := range g.World.SortedNpcIDs() {
+		npc := g.World.Npcs[id]
+		g.PathSystem.CancelOwner(npc)
+		npc.SetPath(nil)
+	}
+	g.Talk = nil
+	g.Battle = battle
+	return nil
+}
+
+// updateBattle runs the turn based mode instead of the real time one
+func (g *Game) updateBattle() error {
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		mx, my := ebiten.CursorPosition()
+		g.Battle.Click(world.HoveredTile(g.Camera, mx, my))
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		g.Battle.EndTurn()
 	}
 
-	for _, npc := range g.World.Npcs {
-		npc.Update(g.World.CurrentLevel)
+	for _, pchar := range g.PCharacters {
+		pchar.Update(g.World.CurrentLevel)
 	}
+	g.Battle.Update()
+	g.World.CurrentLevel.UpdateFog(g.PCharacters)
 
+	if g.Battle.Over() {
+		g.Battle.Finish(g.World)
+		g.Battle = nil
+		g.World.CalmEnemies()
+	}
 	return nil
 }
 
+// Save writes the game into the slot, a failed save doesnt stop the game
+func (g *Game) Save(slot string) {
+	err := save.Write(slot, g.World.Snapshot(g.PCharacters))
+	if err != nil {
+		log.Printf("Saving %s: %v", slot, err)
+		g.World.Notices = append(g.World.Notices, "Saving failed")
+		return
+	}
+	g.World.Notices = append(g.World.Notices, "Game saved")
+}
+
+// Load replaces the game with the slot, nothing changes when it cant be read
+func (g *Game) Load(slot string) {
+	file, err := save.Read(slot)
+	if err != nil {
+		log.Printf("Loading %s: %v", slot, err)
+		g.World.Notices = append(g.World.Notices, "Loading failed")
+		return
+	}
+	npcs := g.World.Npcs
+	err = g.World.Restore(file, g.PCharacters)
+	if err != nil {
+		log.Printf("Loading %s: %v", slot, err)
+		g.World.Notices = append(g.World.Notices, "Loading failed")
+		return
+	}
+	// paths asked for before loading dont fit the loaded game
+	for _, pchar := range g.PCharacters {
+		g.PathSystem.CancelOwner(pchar)
+	}
+	for _, npc := range npcs {
+		g.PathSystem.CancelOwner(npc)
+	}
+	g.Talk = nil
+	level := g.World.CurrentLevel
+	g.Camera.SetBounds(float64(level.Width*config.TileSize), float64(level.Height*config.TileSize))
+}
+
 // TODO CHECK ALL THE NILLS
 
 func (g *Game) Draw(screen *ebiten.Image) {
@@ -169,25 +436,46 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	if debug == "fps" {
 		ebitenutil.DebugPrint(screen, strconv.Itoa(int(ebiten.ActualFPS())))
 	}
-	if g.World != nil && g.World.CurrentLevel != nil {
-		g.World.CurrentLevel.Draw(screen, g.Camera, *g.Assets, g.PCharacters)
-	}
-
 	for _, character := range g.PCharacters {
 		if character != nil {
-			character.Draw(screen, *g.Camera)
+			g.RenderQueue.Submit(character.Baseline(), func(target *ebiten.Image) {
+				character.Draw(target, *g.Camera)
+			})
 		}
 	}
 
-	for _, npc := range g.World.Npcs {
-		if npc != nil {
-			//if npc.LevelName == g.World.CurrentLevel.Name {
-			npc.Draw(screen, *g.Camera)
-			//}
+	for _, id := range g.World.SortedNpcIDs() {
+		if npc := g.World.Npcs[id]; npc != nil {
+			// the fallen lie on the battlefield until the fight is over
+			if npc.LevelName != g.World.CurrentLevel.Name || (!npc.Stats.Alive() && g.Battle == nil) {
+				continue
+			}
+			if !g.World.CurrentLevel.Fog.IsVisible(int(math.Round(npc.GetX())), int(math.Round(npc.GetY()))) {
+				continue
+			}
+			g.RenderQueue.Submit(npc.Baseline(), func(target *ebiten.Image) {
+				npc.Draw(target, *g.Camera)
+			})
+		}
+	}
+
+	if g.World != nil && g.World.CurrentLevel != nil {
+		g.World.CurrentLevel.SubmitGroundItems(g.RenderQueue, g.Camera, g.World.Items)
+		g.World.CurrentLevel.Draw(screen, g.Camera, *g.Assets, g.RenderQueue, g.World.Clock)
+		if g.Battle != nil {
+			g.Battle.Draw(screen, g.Camera)
 		}
+		g.World.DrawTransition(screen)
 	}
+	g.Journal.DrawNotice(screen)
 
 	g.Drag.Draw(screen, g.Camera)
+	ebitenutil.DebugPrintAt(screen, "formation: "+g.Formation.String(), 0, 16)
+	g.Dialogue.Draw(screen)
+	if g.Inventory.Open {
+		g.Inventory.Draw(screen, g.PCharacters, g.World.Items)
+	}
+	g.Journal.Draw(screen, g.World.Journal)
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
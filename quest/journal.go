@@ -0,0 +1,186 @@
+package quest
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Flags is the global store of story variables, missing ones are 0
+type Flags map[string]int
+
+// Status of a quest, it is also the value of the "quest:<id>" flag so
+// dialogues can check on quests
+type Status int
+
+const (
+	NotStarted Status = iota
+	Active
+	Done
+)
+
+// FlagName is the flag mirroring the status of the quest
+func FlagName(id string) string {
+	return "quest:" + id
+}
+
+// Event is something that happened in the game
+type Event struct {
+	Kind   Kind
+	Target string
+	Count  int //0 means 1
+}
+
+// Progress is the saved state of a started quest, Counts go with the
+// objectives of the current stage
+type Progress struct {
+	ID     string
+	Stage  int
+	Counts []int
+	Status Status
+}
+
+// Journal is every started quest and the flags
+type Journal struct {
+	Definitions map[string]Definition
+	Quests      map[string]*Progress
+	Flags       Flags
+	Started     []string //ids in the order the quests started
+}
+
+func NewJournal(definitions map[string]Definition) *Journal {
+	return &Journal{
+		Definitions: definitions,
+		Quests:      map[string]*Progress{},
+		Flags:       Flags{},
+	}
+}
+
+// StartAuto starts the quests every new game begins with
+func (j *Journal) StartAuto() ([]string, error) {
+	ids := []string{}
+	for id, definition := range j.Definitions {
+		if definition.AutoStart {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	notices := []string{}
+	for _, id := range ids {
+		started, err := j.Start(id)
+		if err != nil {
+			return nil, err
+		}
+		notices = append(notices, started...)
+	}
+	return notices, nil
+}
+
+// Start begins the quest, starting one twice does nothing. It returns what
+// should be told to the player.
+func (j *Journal) Start(id string) ([]string, error) {
+	definition, ok := j.Definitions[id]
+	if !ok {
+		return nil, fmt.Errorf("Unknown quest %s", id)
+	}
+	if _, ok := j.Quests[id]; ok {
+		return nil, nil
+	}
+	progress := &Progress{ID: id, Status: Active, Counts: make([]int, len(definition.Stages[0].Objectives))}
+	j.Quests[id] = progress
+	j.Started = append(j.Started, id)
+	j.Flags[FlagName(id)] = int(Active)
+
+	notices := []string{"New quest: " + definition.Title}
+	// a stage without objectives is done right away
+	return append(notices, j.advance(progress)...), nil
+}
+
+// Fire moves the objectives of the current stages the event matches
+func (j *Journal) Fire(event Event) []string {
+	notices := []string{}
+	for _, id := range j.Started {
+		progress := j.Quests[id]
+		if progress.Status != Active {
+			continue
+		}
+		stage := j.Definitions[id].Stages[progress.Stage]
+		changed := false
+		for i, objective := range stage.Objectives {
+			if objective.Kind != event.Kind || objective.Target != event.Target || progress.Counts[i] >= objective.Needed() {
+				continue
+			}
+			progress.Counts[i] = min(progress.Counts[i]+max(event.Count, 1), objective.Needed())
+			changed = true
+		}
+		if changed {
+			notices = append(notices, j.advance(progress)...)
+		}
+	}
+	return notices
+}
+
+// advance finishes the stages whose objectives are all done
+func (j *Journal) advance(progress *Progress) []string {
+	definition := j.Definitions[progress.ID]
+	notices := []string{}
+	for progress.Status == Active {
+		stage := definition.Stages[progress.Stage]
+		for i, objective := range stage.Objectives {
+			if progress.Counts[i] < objective.Needed() {
+				return notices
+			}
+		}
+		for name, value := range stage.Flags {
+			j.Flags[name] = value
+		}
+
+		progress.Stage++
+		if progress.Stage >= len(definition.Stages) {
+			progress.Stage = len(definition.Stages) - 1
+			progress.Status = Done
+			j.Flags[FlagName(progress.ID)] = int(Done)
+			notices = append(notices, "Quest done: "+definition.Title)
+			return notices
+		}
+		progress.Counts = make([]int, len(definition.Stages[progress.Stage].Objectives))
+		notices = append(notices, "Quest updated: "+definition.Title)
+	}
+	return notices
+}
+
+// Snapshot is the progress of the started quests in starting order
+func (j *Journal) Snapshot() []Progress {
+	list := []Progress{}
+	for _, id := range j.Started {
+		progress := *j.Quests[id]
+		progress.Counts = append([]int{}, progress.Counts...)
+		list = append(list, progress)
+	}
+	return list
+}
+
+// Restore replaces the quests and the flags with saved ones
+func (j *Journal) Restore(list []Progress, flags Flags) error {
+	j.Quests = map[string]*Progress{}
+	j.Started = []string{}
+	j.Flags = Flags{}
+	for name, value := range flags {
+		j.Flags[name] = value
+	}
+	for _, progress := range list {
+		definition, ok := j.Definitions[progress.ID]
+		if !ok {
+			return fmt.Errorf("Save has unknown quest %s", progress.ID)
+		}
+		if progress.Stage < 0 || progress.Stage >= len(definition.Stages) {
+			return fmt.Errorf("Save has quest %s at missing stage %d", progress.ID, progress.Stage)
+		}
+		// the definition could have changed since saving
+		counts := make([]int, len(definition.Stages[progress.Stage].Objectives))
+		copy(counts, progress.Counts)
+		progress.Counts = counts
+		j.Quests[progress.ID] = &progress
+		j.Started = append(j.Started, progress.ID)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package quest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func definitions() map[string]Definition {
+	return map[string]Definition{
+		"rats": {
+			ID:    "rats",
+			Title: "Rats",
+			Stages: []Stage{
+				{Objectives: []Objective{{Kind: Defeat, Target: "rat", Count: 3}, {Kind: Reach, Target: "cellar"}}, Flags: map[string]int{"cellar_clear": 1}},
+				{Objectives: []Objective{{Kind: Talk, Target: "innkeeper"}}},
+			},
+		},
+		"intro": {
+			ID:        "intro",
+			Title:     "Intro",
+			AutoStart: true,
+			Stages:    []Stage{{Objectives: []Objective{{Kind: Reach, Target: "square"}}}},
+		},
+		"instant": {
+			ID:     "instant",
+			Title:  "Instant",
+			Stages: []Stage{{Flags: map[string]int{"knows": 1}}},
+		},
+	}
+}
+
+func TestStartAuto(t *testing.T) {
+	j := NewJournal(definitions())
+	notices, err := j.StartAuto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(j.Started, []string{"intro"}) || len(notices) != 1 {
+		t.Fatalf("Started %v, notices %v", j.Started, notices)
+	}
+	if j.Flags[FlagName("intro")] != int(Active) {
+		t.Fatal("Quest flag not set")
+	}
+	if notices, _ := j.Start("intro"); notices != nil {
+		t.Fatal("Started twice")
+	}
+	if _, err := j.Start("missing"); err == nil {
+		t.Fatal("Started a missing quest")
+	}
+}
+
+func TestFireAdvancesStages(t *testing.T) {
+	j := NewJournal(definitions())
+	j.Start("rats")
+	progress := j.Quests["rats"]
+
+	if notices := j.Fire(Event{Kind: Defeat, Target: "bat"}); len(notices) != 0 || progress.Counts[0] != 0 {
+		t.Fatal("Wrong target counted")
+	}
+	j.Fire(Event{Kind: Defeat, Target: "rat", Count: 2})
+	j.Fire(Event{Kind: Reach, Target: "cellar"})
+	if progress.Stage != 0 || !reflect.DeepEqual(progress.Counts, []int{2, 1}) {
+		t.Fatalf("Stage %d counts %v", progress.Stage, progress.Counts)
+	}
+
+	// more than needed stops at the count
+	notices := j.Fire(Event{Kind: Defeat, Target: "rat", Count: 5})
+	if progress.Stage != 1 || len(notices) != 1 || j.Flags["cellar_clear"] != 1 {
+		t.Fatalf("Stage %d notices %v flags %v", progress.Stage, notices, j.Flags)
+	}
+	if !reflect.DeepEqual(progress.Counts, []int{0}) {
+		t.Fatalf("Counts of the new stage %v", progress.Counts)
+	}
+
+	j.Fire(Event{Kind: Talk, Target: "innkeeper"})
+	if progress.Status != Done || j.Flags[FlagName("rats")] != int(Done) {
+		t.Fatalf("Status %d", progress.Status)
+	}
+	if notices := j.Fire(Event{Kind: Talk, Target: "innkeeper"}); len(notices) != 0 {
+		t.Fatal("Done quest moved")
+	}
+}
+
+func TestStageWithoutObjectives(t *testing.T) {
+	j := NewJournal(definitions())
+	notices, err := j.Start("instant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Quests["instant"].Status != Done || j.Flags["knows"] != 1 || len(notices) != 2 {
+		t.Fatalf("Status %d flags %v notices %v", j.Quests["instant"].Status, j.Flags, notices)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	j := NewJournal(definitions())
+	j.StartAuto()
+	j.Start("rats")
+	j.Fire(Event{Kind: Defeat, Target: "rat"})
+	j.Flags["custom"] = 4
+
+	list := j.Snapshot()
+	flags := Flags{}
+	for name, value := range j.Flags {
+		flags[name] = value
+	}
+	// the snapshot doesnt change with the journal
+	j.Fire(Event{Kind: Defeat, Target: "rat"})
+	if list[1].Counts[0] != 1 {
+		t.Fatal("Snapshot shares the counts")
+	}
+
+	restored := NewJournal(definitions())
+	err := restored.Restore(list, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored.Snapshot(), list) || !reflect.DeepEqual(restored.Flags, flags) {
+		t.Fatalf("Restored %v %v", restored.Snapshot(), restored.Flags)
+	}
+	if !reflect.DeepEqual(restored.Started, []string{"intro", "rats"}) {
+		t.Fatalf("Order %v", restored.Started)
+	}
+}
+
+func TestRestoreChangedDefinitions(t *testing.T) {
+	list := []Progress{{ID: "rats", Stage: 0, Counts: []int{2, 1}, Status: Active}}
+
+	// an objective was added to the stage since saving
+	changed := definitions()
+	rats := changed["rats"]
+	rats.Stages = append([]Stage{}, rats.Stages...)
+	rats.Stages[0].Objectives = append(append([]Objective{}, rats.Stages[0].Objectives...), Objective{Kind: Collect, Target: "cheese"})
+	changed["rats"] = rats
+	j := NewJournal(changed)
+	if err := j.Restore(list, Flags{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(j.Quests["rats"].Counts, []int{2, 1, 0}) {
+		t.Fatalf("Counts %v", j.Quests["rats"].Counts)
+	}
+	j.Fire(Event{Kind: Defeat, Target: "rat"})
+	j.Fire(Event{Kind: Collect, Target: "cheese"})
+	if j.Quests["rats"].Stage != 1 {
+		t.Fatal("Stage with the new objective didnt finish")
+	}
+
+	// and removed again, the extra count is dropped
+	j = NewJournal(definitions())
+	if err := j.Restore([]Progress{{ID: "rats", Counts: []int{2, 1, 1}, Status: Active}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Quests["rats"].Counts) != 2 {
+		t.Fatalf("Counts %v", j.Quests["rats"].Counts)
+	}
+
+	// quests and stages that are gone cant be restored
+	if err := NewJournal(definitions()).Restore([]Progress{{ID: "gone"}}, nil); err == nil {
+		t.Fatal("Restored a removed quest")
+	}
+	if err := NewJournal(definitions()).Restore([]Progress{{ID: "rats", Stage: 5}}, nil); err == nil {
+		t.Fatal("Restored a removed stage")
+	}
+}
+
+func TestLoadQuests(t *testing.T) {
+	definitions, err := Load("../" + QuestsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, definition := range definitions {
+		if id != definition.ID || len(definition.Stages) == 0 {
+			t.Fatalf("Quest %s is broken", id)
+		}
+	}
+}
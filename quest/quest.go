@@ -0,0 +1,83 @@
+// Package quest keeps track of the quests and the story flags, the game feeds
+// it events and it never draws anything
+package quest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const QuestsPath = "assets/data/quests.json"
+
+// Kind of an objective and of the event that moves it
+type Kind string
+
+const (
+	Reach   Kind = "reach"   //Target is the name of an area
+	Talk    Kind = "talk"    //Target is the dialogue of the npc
+	Collect Kind = "collect" //Target is an item
+	Defeat  Kind = "defeat"  //Target is the class of the enemy
+)
+
+// Objective is done after Count matching events, 0 means 1
+type Objective struct {
+	Kind   Kind
+	Target string
+	Count  int
+	Text   string
+}
+
+func (o Objective) Needed() int {
+	return max(o.Count, 1)
+}
+
+// Stage is done when all of its objectives are, then its flags are set and
+// the quest moves on to the next stage
+type Stage struct {
+	Text       string
+	Objectives []Objective
+	Flags      map[string]int
+}
+
+type Definition struct {
+	ID          string
+	Title       string
+	Description string
+	AutoStart   bool //starts with a new game
+	Stages      []Stage
+}
+
+// Load reads every quest definition from the file by ID
+func Load(path string) (map[string]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list := []Definition{}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("Reading quests %s: %w", path, err)
+	}
+
+	definitions := map[string]Definition{}
+	for _, definition := range list {
+		if _, ok := definitions[definition.ID]; ok {
+			return nil, fmt.Errorf("Quest %s is defined twice", definition.ID)
+		}
+		if len(definition.Stages) == 0 {
+			return nil, fmt.Errorf("Quest %s has no stages", definition.ID)
+		}
+		for _, stage := range definition.Stages {
+			for _, objective := range stage.Objectives {
+				switch objective.Kind {
+				case Reach, Talk, Collect, Defeat:
+				default:
+					return nil, fmt.Errorf("Quest %s has unknown objective %s", definition.ID, objective.Kind)
+				}
+			}
+		}
+		definitions[definition.ID] = definition
+	}
+	return definitions, nil
+}
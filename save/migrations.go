@@ -0,0 +1,40 @@
+package save
+
+func init() {
+	// version 2 replaced the hardcoded speed with stats, old saves keep the
+	// stats the characters start with
+	RegisterMigration(1, func(raw map[string]interface{}) error {
+		for _, key := range []string{"PCharacters", "Npcs"} {
+			list, _ := raw[key].([]interface{})
+			for _, item := range list {
+				if character, ok := item.(map[string]interface{}); ok {
+					delete(character, "Speed")
+				}
+			}
+		}
+		return nil
+	})
+
+	// version 3 spawns the npcs from the maps with new ids, the old ones are
+	// dropped and the maps bring theirs back
+	RegisterMigration(2, func(raw map[string]interface{}) error {
+		raw["Npcs"] = []interface{}{}
+		levels, _ := raw["Levels"].([]interface{})
+		for _, item := range levels {
+			level, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			occupancy, _ := level["Occupancy"].([]interface{})
+			kept := []interface{}{}
+			for _, occupant := range occupancy {
+				if o, ok := occupant.(map[string]interface{}); ok && o["Kind"] == "npc" {
+					continue
+				}
+				kept = append(kept, occupant)
+			}
+			level["Occupancy"] = kept
+		}
+		return nil
+	})
+}
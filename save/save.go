@@ -0,0 +1,197 @@
+// Package save reads and writes save files, it only knows plain data so it
+// can be used without a window
+package save
+
+import (
+	"bilydaniel/rpg/items"
+	"bilydaniel/rpg/quest"
+	"bilydaniel/rpg/stats"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version of the files this build writes, bump it and register a migration
+// when the format changes
+const Version = 3
+
+const (
+	Dir       = "saves"
+	QuickSlot = "quick"
+)
+
+type Tile struct {
+	X, Y int
+}
+
+// TileChange is a tile of a tile layer that differs from the map file
+type TileChange struct {
+	Layer int
+	X, Y  int
+	GID   uint32 //with the flip flags
+}
+
+// Occupant is what stands on a tile, Kind is "pcharacter" or "npc" and ID is
+// the name of the character or the id of the npc
+type Occupant struct {
+	X, Y int
+	Kind string
+	ID   string
+}
+
+// GroundItem is an item stack lying on the level, nil GroundItems in an old
+// save means the ones from the map file
+type GroundItem struct {
+	ID    int
+	Item  string
+	Count int
+	X, Y  int
+}
+
+type Level struct {
+	Name        string
+	Changed     []TileChange
+	Occupancy   []Occupant
+	Explored    []byte //packed bits, row by row
+	GroundItems []GroundItem
+}
+
+type PCharacter struct {
+	Name         string
+	X, Y         float64
+	Selected     bool
+	Path         []Tile
+	PathProgress int
+	Stats        *stats.Stats
+	Inventory    *items.Inventory
+	Equipment    items.Equipment
+}
+
+type Npc struct {
+	ID           string
+	LevelName    string
+	X, Y         float64
+	Path         []Tile
+	PathProgress int
+	Movement     float64
+	Home         Tile
+	Stats        *stats.Stats
+}
+
+type File struct {
+	Version      int
+	SavedAt      time.Time
+	CurrentLevel string
+	ClockMinutes float64
+	Flags        quest.Flags //missing in old saves, nothing was set yet
+	Quests       []quest.Progress
+	Levels       []Level
+	PCharacters  []PCharacter
+	Npcs         []Npc
+}
+
+// Migration upgrades a raw file of one version to the next one
+type Migration func(raw map[string]interface{}) error
+
+// from version => migration to from+1
+var migrations = map[int]Migration{}
+
+func RegisterMigration(from int, migration Migration) {
+	migrations[from] = migration
+}
+
+func SlotPath(slot string) string {
+	return filepath.Join(Dir, slot+".json")
+}
+
+// Slots returns the names of the saved slots
+func Slots() ([]string, error) {
+	entries, err := os.ReadDir(Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	slots := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			slots = append(slots, strings.TrimSuffix(entry.Name(), ".json"))
+		}
+	}
+	sort.Strings(slots)
+	return slots, nil
+}
+
+func Write(slot string, file File) error {
+	file.Version = Version
+	data, err := Marshal(file)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(Dir, 0755)
+	if err != nil {
+		return err
+	}
+	// write next to the old save first so a crash doesnt leave half a file
+	path := SlotPath(slot)
+	err = os.WriteFile(path+".tmp", data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func Read(slot string) (File, error) {
+	data, err := os.ReadFile(SlotPath(slot))
+	if err != nil {
+		return File{}, err
+	}
+	return Unmarshal(data)
+}
+
+func Marshal(file File) ([]byte, error) {
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// Unmarshal reads a save of any known version, older ones are migrated
+func Unmarshal(data []byte) (File, error) {
+	raw := map[string]interface{}{}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return File{}, err
+	}
+	version, ok := raw["Version"].(float64)
+	if !ok {
+		return File{}, fmt.Errorf("Save has no version")
+	}
+	if int(version) > Version {
+		return File{}, fmt.Errorf("Save version %d is newer than %d", int(version), Version)
+	}
+
+	if int(version) < Version {
+		for v := int(version); v < Version; v++ {
+			migration, ok := migrations[v]
+			if !ok {
+				return File{}, fmt.Errorf("No migration from save version %d", v)
+			}
+			err = migration(raw)
+			if err != nil {
+				return File{}, fmt.Errorf("Migrating save from version %d: %w", v, err)
+			}
+			raw["Version"] = float64(v + 1)
+		}
+		data, err = json.Marshal(raw)
+		if err != nil {
+			return File{}, err
+		}
+	}
+
+	file := File{}
+	err = json.Unmarshal(data, &file)
+	return file, err
+}
@@ -0,0 +1,122 @@
+package save
+
+import (
+	"bilydaniel/rpg/items"
+	"bilydaniel/rpg/quest"
+	"bilydaniel/rpg/stats"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testFile() File {
+	class := stats.Class{Attributes: map[stats.Stat]float64{stats.Agility: 12}}
+	inventory := items.NewInventory(10, 50)
+	inventory.Stacks = append(inventory.Stacks, items.Stack{Item: "apple", Count: 3})
+	return File{
+		Version:      Version,
+		SavedAt:      time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		CurrentLevel: "level_1",
+		ClockMinutes: 1234.5,
+		Flags:        quest.Flags{"met_guard": 1},
+		Quests:       []quest.Progress{{ID: "rats", Stage: 1, Counts: []int{2}, Status: quest.Active}},
+		Levels: []Level{{
+			Name:        "level_1",
+			Changed:     []TileChange{{Layer: 1, X: 3, Y: 4, GID: 0x80000018}},
+			Occupancy:   []Occupant{{X: 1, Y: 2, Kind: "pcharacter", ID: "red"}},
+			Explored:    []byte{0xff, 0x01},
+			GroundItems: []GroundItem{{ID: 7, Item: "apple", Count: 2, X: 5, Y: 5}},
+		}},
+		PCharacters: []PCharacter{{
+			Name:         "red",
+			X:            1,
+			Y:            2,
+			Selected:     true,
+			Path:         []Tile{{X: 2, Y: 2}, {X: 3, Y: 2}},
+			PathProgress: 1,
+			Stats:        stats.New(class),
+			Inventory:    inventory,
+			Equipment:    items.Equipment{items.Slot("weapon"): "sword"},
+		}},
+		Npcs: []Npc{{ID: "level_1:12", LevelName: "level_1", X: 9, Y: 9, Path: []Tile{}, Movement: 0.5, Home: Tile{X: 9, Y: 8}}},
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	file := testFile()
+	data, err := Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(file, loaded) {
+		t.Fatalf("Got back\n%+v\nwant\n%+v", loaded, file)
+	}
+}
+
+// version 1 had a speed on the characters and npcs with the old ids
+const version1 = `{
+  "Version": 1,
+  "CurrentLevel": "level_1",
+  "ClockMinutes": 600,
+  "Levels": [{
+    "Name": "level_1",
+    "Occupancy": [
+      {"X": 1, "Y": 2, "Kind": "pcharacter", "ID": "red"},
+      {"X": 4, "Y": 4, "Kind": "npc", "ID": "npc0"}
+    ]
+  }],
+  "PCharacters": [{"Name": "red", "X": 1, "Y": 2, "Speed": 0.1}],
+  "Npcs": [{"ID": "npc0", "LevelName": "level_1", "X": 4, "Y": 4, "Speed": 0.05}]
+}`
+
+func TestMigrateFromVersion1(t *testing.T) {
+	file, err := Unmarshal([]byte(version1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Version != Version {
+		t.Fatalf("Version %d, want %d", file.Version, Version)
+	}
+	if len(file.Npcs) != 0 {
+		t.Fatalf("Old npcs kept %v", file.Npcs)
+	}
+	want := []Occupant{{X: 1, Y: 2, Kind: "pcharacter", ID: "red"}}
+	if !reflect.DeepEqual(file.Levels[0].Occupancy, want) {
+		t.Fatalf("Occupancy %v, want %v", file.Levels[0].Occupancy, want)
+	}
+	if len(file.PCharacters) != 1 || file.PCharacters[0].X != 1 || file.PCharacters[0].Stats != nil {
+		t.Fatalf("Characters %+v", file.PCharacters)
+	}
+	if file.Quests != nil || file.Flags != nil {
+		t.Fatal("Version 1 had no quests")
+	}
+}
+
+func TestMigrateEveryVersion(t *testing.T) {
+	for v := 1; v < Version; v++ {
+		if _, ok := migrations[v]; !ok {
+			t.Fatalf("No migration from version %d", v)
+		}
+		data := []byte(fmt.Sprintf(`{"Version": %d, "CurrentLevel": "level_1"}`, v))
+		file, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Version %d: %v", v, err)
+		}
+		if file.Version != Version || file.CurrentLevel != "level_1" {
+			t.Fatalf("Version %d came out as %+v", v, file)
+		}
+	}
+}
+
+func TestUnmarshalRejects(t *testing.T) {
+	for _, data := range []string{`{}`, `{"Version": 999}`, `not json`} {
+		if _, err := Unmarshal([]byte(data)); err == nil {
+			t.Fatalf("%s was accepted", data)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ClassesPath is the data file with the class definitions
+const ClassesPath = "assets/data/classes.json"
+
+// Class is the starting attributes and how they grow with every level
+type Class struct {
+	Name        string
+	Description string
+	Attributes  map[Stat]float64
+	Growth      map[Stat]float64
+	Attacks     []string //combat attack names
+}
+
+func LoadClasses(path string) (map[string]Class, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list := []Class{}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("Reading classes %s: %w", path, err)
+	}
+
+	classes := map[string]Class{}
+	for _, class := range list {
+		if _, ok := classes[class.Name]; ok {
+			return nil, fmt.Errorf("Class %s is defined twice", class.Name)
+		}
+		for stat := range class.Attributes {
+			if !isAttribute(stat) {
+				return nil, fmt.Errorf("Class %s has unknown attribute %s", class.Name, stat)
+			}
+		}
+		for stat := range class.Growth {
+			if !isAttribute(stat) {
+				return nil, fmt.Errorf("Class %s grows unknown attribute %s", class.Name, stat)
+			}
+		}
+		classes[class.Name] = class
+	}
+	return classes, nil
+}
+
+func isAttribute(stat Stat) bool {
+	for _, attribute := range Attributes {
+		if attribute == stat {
+			return true
+		}
+	}
+	return false
+}
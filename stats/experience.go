@@ -0,0 +1,34 @@
+package stats
+
+// ExperienceForLevel is the total experience needed to reach the level
+func ExperienceForLevel(level int) int {
+	return 50 * level * (level - 1)
+}
+
+// ExperienceReward is what beating someone of the level is worth
+func ExperienceReward(level int) int {
+	return 25 * level
+}
+
+// AddExperience adds the experience and levels up as many times as it is
+// enough for, returns how many levels were gained
+func (s *Stats) AddExperience(amount int, class Class) int {
+	s.Experience += amount
+	gained := 0
+	for s.Experience >= ExperienceForLevel(s.Level+1) {
+		s.levelUp(class)
+		gained++
+	}
+	return gained
+}
+
+// levelUp grows the attributes by the class growth and restores health and
+// mana
+func (s *Stats) levelUp(class Class) {
+	s.Level++
+	for stat, growth := range class.Growth {
+		s.Base[stat] += growth
+	}
+	s.HP = s.MaxHP()
+	s.Mana = int(s.Get(MaxMana))
+}
@@ -0,0 +1,64 @@
+package stats
+
+// Modifier changes a stat for a while, Add is added to the value and Multiply
+// to the multiplier (0.2 is +20%). Zero Duration lasts until removed.
+type Modifier struct {
+	Name      string
+	Stat      Stat
+	Add       float64
+	Multiply  float64
+	Duration  int //ticks
+	Remaining int
+}
+
+// AddModifier applies the modifier, one with the same name and stat gets
+// refreshed instead of stacking
+func (s *Stats) AddModifier(modifier Modifier) {
+	modifier.Remaining = modifier.Duration
+	for i, existing := range s.Modifiers {
+		if existing.Name == modifier.Name && existing.Stat == modifier.Stat {
+			s.Modifiers[i] = modifier
+			s.clamp()
+			return
+		}
+	}
+	s.Modifiers = append(s.Modifiers, modifier)
+	s.clamp()
+}
+
+func (s *Stats) RemoveModifier(name string) {
+	modifiers := s.Modifiers[:0]
+	for _, modifier := range s.Modifiers {
+		if modifier.Name != name {
+			modifiers = append(modifiers, modifier)
+		}
+	}
+	s.Modifiers = modifiers
+	s.clamp()
+}
+
+// Tick counts the durations down and drops the modifiers that ran out
+func (s *Stats) Tick(ticks int) {
+	modifiers := s.Modifiers[:0]
+	expired := false
+	for _, modifier := range s.Modifiers {
+		if modifier.Duration > 0 {
+			modifier.Remaining -= ticks
+			if modifier.Remaining <= 0 {
+				expired = true
+				continue
+			}
+		}
+		modifiers = append(modifiers, modifier)
+	}
+	s.Modifiers = modifiers
+	if expired {
+		s.clamp()
+	}
+}
+
+// clamp keeps health and mana under the maximums after they changed
+func (s *Stats) clamp() {
+	s.HP = min(s.HP, s.MaxHP())
+	s.Mana = min(s.Mana, int(s.Get(MaxMana)))
+}
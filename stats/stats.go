@@ -0,0 +1,126 @@
+// Package stats has the numbers behind the characters, attributes, the values
+// derived from them, experience and modifiers
+package stats
+
+import "math"
+
+type Stat string
+
+// attributes, they come from the class and grow with levels
+const (
+	Strength     Stat = "strength"
+	Agility      Stat = "agility"
+	Vitality     Stat = "vitality"
+	Intelligence Stat = "intelligence"
+)
+
+// derived values, computed from the attributes
+const (
+	MaxHP        Stat = "max_hp"
+	MaxMana      Stat = "max_mana"
+	Speed        Stat = "speed" //tiles per tick
+	Armour       Stat = "armour"
+	ActionPoints Stat = "action_points"
+	Initiative   Stat = "initiative"
+)
+
+var Attributes = []Stat{Strength, Agility, Vitality, Intelligence}
+
+// BaseSpeed is the walking speed of a character with average agility
+const BaseSpeed = 1 / 30.0
+
+// averageAttribute is what the derived values are balanced around
+const averageAttribute = 10
+
+// derived values are computed from other stats, filled in init because they
+// call Get
+var derived map[Stat]func(s *Stats) float64
+
+func init() {
+	derived = map[Stat]func(s *Stats) float64{
+		MaxHP: func(s *Stats) float64 {
+			return 10 + 2*s.Get(Vitality)
+		},
+		MaxMana: func(s *Stats) float64 {
+			return 5 + 2*s.Get(Intelligence)
+		},
+		Speed: func(s *Stats) float64 {
+			return BaseSpeed * math.Max(0.5, 1+0.03*(s.Get(Agility)-averageAttribute))
+		},
+		Armour: func(s *Stats) float64 {
+			return math.Floor((s.Get(Vitality) + s.Get(Strength)) / 8)
+		},
+		ActionPoints: func(s *Stats) float64 {
+			return 4 + math.Floor(s.Get(Agility)/5)
+		},
+		Initiative: func(s *Stats) float64 {
+			return math.Floor(s.Get(Agility) / 2)
+		},
+	}
+}
+
+// Stats belong to one character, Base only has the attributes
+type Stats struct {
+	Class      string
+	Level      int
+	Experience int //total
+	Base       map[Stat]float64
+	HP         int
+	Mana       int
+	Modifiers  []Modifier
+}
+
+// New makes level one stats of the class with full health and mana
+func New(class Class) *Stats {
+	s := &Stats{
+		Class: class.Name,
+		Level: 1,
+		Base:  map[Stat]float64{},
+	}
+	for stat, value := range class.Attributes {
+		s.Base[stat] = value
+	}
+	s.HP = s.MaxHP()
+	s.Mana = int(s.Get(MaxMana))
+	return s
+}
+
+// Get returns the value with all the modifiers applied, flat ones first
+func (s *Stats) Get(stat Stat) float64 {
+	value := s.Base[stat]
+	if derive, ok := derived[stat]; ok {
+		value = derive(s)
+	}
+	multiplier := 1.0
+	for _, modifier := range s.Modifiers {
+		if modifier.Stat == stat {
+			value += modifier.Add
+			multiplier += modifier.Multiply
+		}
+	}
+	return value * math.Max(multiplier, 0)
+}
+
+func (s *Stats) MaxHP() int {
+	return int(s.Get(MaxHP))
+}
+
+func (s *Stats) Alive() bool {
+	return s.HP > 0
+}
+
+// Heal adds hit points up to the maximum, negative amounts hurt
+func (s *Stats) Heal(amount int) {
+	s.HP = max(0, min(s.HP+amount, s.MaxHP()))
+}
+
+// Clone copies the stats so the copy can change on its own
+func (s *Stats) Clone() *Stats {
+	clone := *s
+	clone.Base = map[Stat]float64{}
+	for stat, value := range s.Base {
+		clone.Base[stat] = value
+	}
+	clone.Modifiers = append([]Modifier{}, s.Modifiers...)
+	return &clone
+}
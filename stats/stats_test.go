@@ -0,0 +1,196 @@
+package stats
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var fighter = Class{
+	Name:       "fighter",
+	Attributes: map[Stat]float64{Strength: 12, Agility: 10, Vitality: 11, Intelligence: 6},
+	Growth:     map[Stat]float64{Strength: 2, Vitality: 1.5},
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestAddExperience(t *testing.T) {
+	s := New(fighter)
+	if gained := s.AddExperience(ExperienceForLevel(2)-1, fighter); gained != 0 || s.Level != 1 {
+		t.Fatalf("Gained %d levels short of level 2, level %d", gained, s.Level)
+	}
+
+	s.HP = 1
+	// straight to level 4
+	gained := s.AddExperience(ExperienceForLevel(4)-s.Experience+10, fighter)
+	if gained != 3 || s.Level != 4 {
+		t.Fatalf("Gained %d, level %d, want 3 and 4", gained, s.Level)
+	}
+	if s.Experience != ExperienceForLevel(4)+10 {
+		t.Fatalf("Experience %d", s.Experience)
+	}
+	if s.Base[Strength] != 18 || s.Base[Vitality] != 15.5 || s.Base[Agility] != 10 {
+		t.Fatalf("Attributes %v after 3 levels", s.Base)
+	}
+	if s.HP != s.MaxHP() || s.MaxHP() != 41 {
+		t.Fatalf("HP %d of %d after leveling", s.HP, s.MaxHP())
+	}
+	if s.Mana != int(s.Get(MaxMana)) {
+		t.Fatalf("Mana %d not refilled", s.Mana)
+	}
+}
+
+func TestDerived(t *testing.T) {
+	s := New(fighter)
+	if s.MaxHP() != 32 || s.HP != 32 {
+		t.Fatalf("Max HP %d, HP %d", s.MaxHP(), s.HP)
+	}
+	if got := s.Get(MaxMana); got != 17 {
+		t.Fatalf("Max mana %v", got)
+	}
+	if got := s.Get(Armour); got != 2 {
+		t.Fatalf("Armour %v, want floor(23/8)", got)
+	}
+	if got := s.Get(Speed); !near(got, BaseSpeed) {
+		t.Fatalf("Average agility speed %v", got)
+	}
+	if got := s.Get(ActionPoints); got != 6 {
+		t.Fatalf("Action points %v", got)
+	}
+
+	s.Base[Agility] = 20
+	if got := s.Get(Speed); !near(got, BaseSpeed*1.3) {
+		t.Fatalf("Quick speed %v", got)
+	}
+	// slow characters still walk at half speed
+	s.Base[Agility] = -50
+	if got := s.Get(Speed); !near(got, BaseSpeed*0.5) {
+		t.Fatalf("Speed %v under the floor", got)
+	}
+
+	// derived values follow the modified attributes
+	s.Base[Agility] = 10
+	s.AddModifier(Modifier{Name: "shield", Stat: Strength, Add: 9})
+	if got := s.Get(Armour); got != 4 {
+		t.Fatalf("Armour %v with more strength", got)
+	}
+}
+
+func TestModifiers(t *testing.T) {
+	s := New(fighter)
+	s.AddModifier(Modifier{Name: "haste", Stat: Agility, Add: 2, Multiply: 0.5, Duration: 10})
+	if got := s.Get(Agility); got != 18 {
+		t.Fatalf("Agility %v, want (10+2)*1.5", got)
+	}
+
+	// the same one refreshes
+	s.Tick(6)
+	s.AddModifier(Modifier{Name: "haste", Stat: Agility, Add: 2, Multiply: 0.5, Duration: 10})
+	if len(s.Modifiers) != 1 || s.Modifiers[0].Remaining != 10 || s.Get(Agility) != 18 {
+		t.Fatalf("Refreshed haste %+v, agility %v", s.Modifiers, s.Get(Agility))
+	}
+
+	// other names or stats stack
+	s.AddModifier(Modifier{Name: "boots", Stat: Agility, Add: 1})
+	s.AddModifier(Modifier{Name: "haste", Stat: Strength, Add: 3, Duration: 5})
+	if got := s.Get(Agility); got != 19.5 {
+		t.Fatalf("Agility %v, want (10+2+1)*1.5", got)
+	}
+	if got := s.Get(Strength); got != 15 {
+		t.Fatalf("Strength %v", got)
+	}
+
+	// a negative multiplier doesnt go below zero
+	s.AddModifier(Modifier{Name: "curse", Stat: Intelligence, Multiply: -3})
+	if got := s.Get(Intelligence); got != 0 {
+		t.Fatalf("Intelligence %v", got)
+	}
+
+	s.RemoveModifier("haste")
+	if got := s.Get(Agility); got != 11 || s.Get(Strength) != 12 {
+		t.Fatalf("Agility %v strength %v after removing haste", got, s.Get(Strength))
+	}
+}
+
+func TestTickExpiry(t *testing.T) {
+	s := New(fighter)
+	s.AddModifier(Modifier{Name: "short", Stat: Strength, Add: 1, Duration: 3})
+	s.AddModifier(Modifier{Name: "forever", Stat: Strength, Add: 1})
+	s.Tick(2)
+	if got := s.Get(Strength); got != 14 {
+		t.Fatalf("Strength %v before expiring", got)
+	}
+	s.Tick(1)
+	if len(s.Modifiers) != 1 || s.Modifiers[0].Name != "forever" {
+		t.Fatalf("Modifiers %+v", s.Modifiers)
+	}
+	s.Tick(1000)
+	if got := s.Get(Strength); got != 13 {
+		t.Fatalf("Strength %v, the lasting one went away", got)
+	}
+}
+
+func TestExpiryClamps(t *testing.T) {
+	s := New(fighter)
+	s.AddModifier(Modifier{Name: "vigour", Stat: Vitality, Add: 10, Duration: 5})
+	s.AddModifier(Modifier{Name: "wisdom", Stat: Intelligence, Add: 5, Duration: 5})
+	s.Heal(100)
+	s.Mana = int(s.Get(MaxMana))
+	if s.HP != 52 || s.Mana != 27 {
+		t.Fatalf("HP %d mana %d with the modifiers", s.HP, s.Mana)
+	}
+
+	s.Tick(5)
+	if s.HP != 32 || s.Mana != 17 {
+		t.Fatalf("HP %d mana %d after they expired, want the maximums", s.HP, s.Mana)
+	}
+
+	// below the maximum nothing changes
+	s.AddModifier(Modifier{Name: "vigour", Stat: Vitality, Add: 10, Duration: 5})
+	s.HP = 20
+	s.Tick(5)
+	if s.HP != 20 {
+		t.Fatalf("HP %d, wounded characters keep their HP", s.HP)
+	}
+}
+
+func TestHeal(t *testing.T) {
+	s := New(fighter)
+	s.Heal(-40)
+	if s.HP != 0 || s.Alive() {
+		t.Fatalf("HP %d", s.HP)
+	}
+	s.Heal(100)
+	if s.HP != s.MaxHP() {
+		t.Fatalf("Healed to %d of %d", s.HP, s.MaxHP())
+	}
+}
+
+func TestLoadClasses(t *testing.T) {
+	classes, err := LoadClasses(filepath.Join("..", ClassesPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(classes) == 0 {
+		t.Fatal("No classes")
+	}
+
+	broken := map[string]string{
+		"twice":     `[{"Name": "a"}, {"Name": "a"}]`,
+		"attribute": `[{"Name": "a", "Attributes": {"max_hp": 3}}]`,
+		"growth":    `[{"Name": "a", "Growth": {"luck": 1}}]`,
+		"json":      `{`,
+	}
+	for name, data := range broken {
+		path := filepath.Join(t.TempDir(), "classes.json")
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadClasses(path); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+	}
+}
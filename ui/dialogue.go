@@ -0,0 +1,149 @@
+package ui
+
+import (
+	"bilydaniel/rpg/dialogue"
+	"fmt"
+	"image/color"
+	"log"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	dialogueHeight = 110
+	facesetScale   = 2
+	charWidth      = 6 //of the debug font
+)
+
+// DialogueBox shows the running conversation at the bottom of the screen.
+// Up/Down and Enter pick a choice, the number keys pick one right away,
+// Enter or a click goes on when there is nothing to choose.
+type DialogueBox struct {
+	Conversation *dialogue.Conversation
+	Selected     int
+	facesets     map[string]*ebiten.Image
+}
+
+func (d *DialogueBox) Open(conversation *dialogue.Conversation) {
+	d.Conversation = conversation
+	d.Selected = 0
+}
+
+// Active tells if a conversation is on screen
+func (d *DialogueBox) Active() bool {
+	return d.Conversation != nil && !d.Conversation.Ended
+}
+
+func (d *DialogueBox) Update() error {
+	if !d.Active() {
+		return nil
+	}
+	c := d.Conversation
+	confirm := inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeySpace)
+
+	if len(c.Choices) == 0 {
+		if confirm || inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			return c.Continue()
+		}
+		return nil
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) {
+		d.Selected = (d.Selected + 1) % len(c.Choices)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) {
+		d.Selected = (d.Selected + len(c.Choices) - 1) % len(c.Choices)
+	}
+	for i := range min(len(c.Choices), 9) {
+		if inpututil.IsKeyJustPressed(ebiten.Key1 + ebiten.Key(i)) {
+			d.Selected = i
+			confirm = true
+		}
+	}
+	if confirm {
+		choice := d.Selected
+		d.Selected = 0
+		return c.Choose(choice)
+	}
+	return nil
+}
+
+func (d *DialogueBox) Draw(screen *ebiten.Image) {
+	if !d.Active() {
+		return
+	}
+	c := d.Conversation
+	bounds := screen.Bounds()
+	top := bounds.Dy() - dialogueHeight
+	vector.DrawFilledRect(screen, 0, float32(top), float32(bounds.Dx()), dialogueHeight, color.RGBA{20, 20, 30, 230}, false)
+
+	x := 8
+	if face := d.faceset(c.Faceset()); face != nil {
+		opts := ebiten.DrawImageOptions{}
+		opts.GeoM.Scale(facesetScale, facesetScale)
+		opts.GeoM.Translate(8, float64(top+8))
+		screen.DrawImage(face, &opts)
+		x += face.Bounds().Dx()*facesetScale + 8
+	}
+
+	y := top + 8
+	ebitenutil.DebugPrintAt(screen, c.Speaker(), x, y)
+	y += lineHeight
+	for _, line := range wrap(c.Node.Text, (bounds.Dx()-x-8)/charWidth) {
+		ebitenutil.DebugPrintAt(screen, line, x, y)
+		y += lineHeight
+	}
+	y += 4
+	for i, choice := range c.Choices {
+		text := fmt.Sprintf("  %d. %s", i+1, choice.Text)
+		if i == d.Selected {
+			text = "> " + text[2:]
+		}
+		ebitenutil.DebugPrintAt(screen, text, x, y)
+		y += lineHeight
+	}
+}
+
+// faceset loads the portrait once, nil when there is none
+func (d *DialogueBox) faceset(path string) *ebiten.Image {
+	if path == "" {
+		return nil
+	}
+	if d.facesets == nil {
+		d.facesets = map[string]*ebiten.Image{}
+	}
+	image, ok := d.facesets[path]
+	if !ok {
+		var err error
+		image, _, err = ebitenutil.NewImageFromFile(path)
+		if err != nil {
+			log.Printf("Faceset %s: %v", path, err)
+		}
+		d.facesets[path] = image
+	}
+	return image
+}
+
+// wrap splits the text into lines of at most width characters
+func wrap(text string, width int) []string {
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
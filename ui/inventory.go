@@ -0,0 +1,123 @@
+// Package ui has the screens drawn over the game
+package ui
+
+import (
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/items"
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const lineHeight = 16
+
+// InventoryScreen shows the inventory and equipment of one party member at a
+// time. Left/Right switch the member, Up/Down pick a line, E equips or takes
+// off, X gives the stack to the next member.
+type InventoryScreen struct {
+	Open      bool
+	Character int
+	Line      int
+	Message   string
+}
+
+type inventoryLine struct {
+	item string
+	slot items.Slot //set for equipped lines
+}
+
+func lines(pchar *entities.PCharacter) []inventoryLine {
+	result := []inventoryLine{}
+	for _, slot := range items.Slots {
+		if item, ok := pchar.Equipment[slot]; ok {
+			result = append(result, inventoryLine{item: item, slot: slot})
+		}
+	}
+	for _, stack := range pchar.Inventory.Stacks {
+		result = append(result, inventoryLine{item: stack.Item})
+	}
+	return result
+}
+
+func (s *InventoryScreen) Update(party []*entities.PCharacter, catalog items.Catalog) {
+	if len(party) == 0 {
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+		s.Character = (s.Character + 1) % len(party)
+		s.Line = 0
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) {
+		s.Character = (s.Character + len(party) - 1) % len(party)
+		s.Line = 0
+	}
+	pchar := party[s.Character]
+	all := lines(pchar)
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) {
+		s.Line++
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) {
+		s.Line--
+	}
+	s.Line = max(0, min(s.Line, len(all)-1))
+	if len(all) == 0 {
+		return
+	}
+	line := all[s.Line]
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyE) {
+		var err error
+		if line.slot != items.NoSlot {
+			err = pchar.Equipment.Unequip(catalog, pchar.Stats, pchar.Inventory, line.slot)
+		} else {
+			err = pchar.Equipment.Equip(catalog, pchar.Stats, pchar.Inventory, line.item)
+		}
+		s.Message = ""
+		if err != nil {
+			s.Message = err.Error()
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyX) && line.slot == items.NoSlot {
+		other := party[(s.Character+1)%len(party)]
+		moved, err := items.Transfer(catalog, pchar.Inventory, other.Inventory, line.item, pchar.Inventory.Count(line.item))
+		s.Message = fmt.Sprintf("gave %d to %s", moved, other.Name)
+		if err != nil {
+			s.Message = err.Error()
+		}
+	}
+}
+
+func (s *InventoryScreen) Draw(screen *ebiten.Image, party []*entities.PCharacter, catalog items.Catalog) {
+	if len(party) == 0 {
+		return
+	}
+	pchar := party[s.Character%len(party)]
+	bounds := screen.Bounds()
+	x, y := 40, 24
+	vector.DrawFilledRect(screen, float32(x-8), float32(y-8), float32(bounds.Dx()-2*(x-8)), float32(bounds.Dy()-2*(y-8)), color.RGBA{20, 20, 30, 220}, false)
+
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("< %s >  level %d  weight %.1f/%.0f", pchar.Name, pchar.Stats.Level, pchar.Inventory.Weight(catalog), pchar.Inventory.MaxWeight), x, y)
+	y += lineHeight * 2
+	for i, line := range lines(pchar) {
+		definition := catalog[line.item]
+		text := definition.Name
+		if line.slot != items.NoSlot {
+			text = fmt.Sprintf("[%s] %s", line.slot, definition.Name)
+		} else if count := pchar.Inventory.Count(line.item); definition.StackSize() > 1 {
+			text = fmt.Sprintf("%s x%d", definition.Name, count)
+		}
+		if i == s.Line {
+			text = "> " + text
+		}
+		ebitenutil.DebugPrintAt(screen, text, x, y)
+		y += lineHeight
+	}
+	ebitenutil.DebugPrintAt(screen, "E equip/unequip  X give to next  I close", x, bounds.Dy()-40)
+	if s.Message != "" {
+		ebitenutil.DebugPrintAt(screen, s.Message, x, bounds.Dy()-56)
+	}
+}
@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"bilydaniel/rpg/quest"
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// how long one quest notice stays on screen
+const noticeTicks = 180
+
+// JournalScreen lists the started quests, Up/Down pick one. It also shows
+// quest news at the top of the screen while playing.
+type JournalScreen struct {
+	Open     bool
+	Selected int
+	notices  []string
+	ticks    int
+}
+
+// Notify queues the news, call it every tick
+func (s *JournalScreen) Notify(notices []string) {
+	s.notices = append(s.notices, notices...)
+	if len(s.notices) == 0 {
+		return
+	}
+	s.ticks++
+	if s.ticks >= noticeTicks {
+		s.ticks = 0
+		s.notices = s.notices[1:]
+	}
+}
+
+func (s *JournalScreen) Update(journal *quest.Journal) {
+	if len(journal.Started) == 0 {
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) {
+		s.Selected++
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) {
+		s.Selected--
+	}
+	s.Selected = max(0, min(s.Selected, len(journal.Started)-1))
+}
+
+func (s *JournalScreen) Draw(screen *ebiten.Image, journal *quest.Journal) {
+	if !s.Open {
+		return
+	}
+	bounds := screen.Bounds()
+	x, y := 40, 24
+	vector.DrawFilledRect(screen, float32(x-8), float32(y-8), float32(bounds.Dx()-2*(x-8)), float32(bounds.Dy()-2*(y-8)), color.RGBA{30, 25, 20, 220}, false)
+	ebitenutil.DebugPrintAt(screen, "Journal", x, y)
+	y += lineHeight * 2
+	if len(journal.Started) == 0 {
+		ebitenutil.DebugPrintAt(screen, "Nothing to do yet", x, y)
+		return
+	}
+
+	// quest titles on the left, the selected one on the right
+	detailsX := x + bounds.Dx()/3
+	for i, id := range journal.Started {
+		title := journal.Definitions[id].Title
+		if journal.Quests[id].Status == quest.Done {
+			title += " (done)"
+		}
+		if i == s.Selected {
+			title = "> " + title
+		}
+		ebitenutil.DebugPrintAt(screen, title, x, y+i*lineHeight)
+	}
+
+	id := journal.Started[min(s.Selected, len(journal.Started)-1)]
+	definition := journal.Definitions[id]
+	progress := journal.Quests[id]
+	width := (bounds.Dx() - detailsX - 40) / charWidth
+	for _, line := range wrap(definition.Description, width) {
+		ebitenutil.DebugPrintAt(screen, line, detailsX, y)
+		y += lineHeight
+	}
+	y += lineHeight
+	if progress.Status == quest.Done {
+		ebitenutil.DebugPrintAt(screen, "Completed", detailsX, y)
+		return
+	}
+	stage := definition.Stages[progress.Stage]
+	for _, line := range wrap(stage.Text, width) {
+		ebitenutil.DebugPrintAt(screen, line, detailsX, y)
+		y += lineHeight
+	}
+	for i, objective := range stage.Objectives {
+		text := fmt.Sprintf("- %s %d/%d", objective.Text, progress.Counts[i], objective.Needed())
+		ebitenutil.DebugPrintAt(screen, text, detailsX, y)
+		y += lineHeight
+	}
+}
+
+// DrawNotice shows the oldest news not shown long enough yet
+func (s *JournalScreen) DrawNotice(screen *ebiten.Image) {
+	if len(s.notices) == 0 {
+		return
+	}
+	text := s.notices[0]
+	x := (screen.Bounds().Dx() - len(text)*charWidth) / 2
+	vector.DrawFilledRect(screen, float32(x-4), 44, float32(len(text)*charWidth+8), lineHeight+4, color.RGBA{0, 0, 0, 160}, false)
+	ebitenutil.DebugPrintAt(screen, text, x, 46)
+}
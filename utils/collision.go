@@ -0,0 +1,180 @@
+package utils
+
+import "math"
+
+// small margin so shapes that only touch each other dont count as overlapping
+const collisionEpsilon = 0.001
+
+func (r *RectangleCollision) Intersects(p Point) bool {
+	return p.X >= r.Minx && p.X <= r.Maxx && p.Y >= r.Miny && p.Y <= r.Maxy
+}
+
+func (r *RectangleCollision) IntersectsLine(a Point, b Point) bool {
+	if r.Intersects(a) || r.Intersects(b) {
+		return true
+	}
+	corners := r.Corners()
+	for i := range corners {
+		if SegmentsIntersect(a, b, corners[i], corners[(i+1)%len(corners)]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RectangleCollision) Bounds() RectangleCollision {
+	return *r
+}
+
+func (r *RectangleCollision) Corners() []Point {
+	return []Point{{r.Minx, r.Miny}, {r.Maxx, r.Miny}, {r.Maxx, r.Maxy}, {r.Minx, r.Maxy}}
+}
+
+// PolygonCollision is any closed polygon, rotated rectangles end up here too
+type PolygonCollision struct {
+	Points []Point
+}
+
+// Intersects uses the even-odd rule
+func (pc *PolygonCollision) Intersects(p Point) bool {
+	inside := false
+	n := len(pc.Points)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		a := pc.Points[i]
+		b := pc.Points[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) {
+			x := (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y) + a.X
+			if p.X < x {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+func (pc *PolygonCollision) IntersectsLine(a Point, b Point) bool {
+	if pc.Intersects(a) || pc.Intersects(b) {
+		return true
+	}
+	n := len(pc.Points)
+	for i := 0; i < n; i++ {
+		if SegmentsIntersect(a, b, pc.Points[i], pc.Points[(i+1)%n]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pc *PolygonCollision) Bounds() RectangleCollision {
+	bounds := RectangleCollision{Minx: math.Inf(1), Miny: math.Inf(1), Maxx: math.Inf(-1), Maxy: math.Inf(-1)}
+	for _, p := range pc.Points {
+		bounds.Minx = math.Min(bounds.Minx, p.X)
+		bounds.Miny = math.Min(bounds.Miny, p.Y)
+		bounds.Maxx = math.Max(bounds.Maxx, p.X)
+		bounds.Maxy = math.Max(bounds.Maxy, p.Y)
+	}
+	return bounds
+}
+
+// EllipseCollision is axis aligned, rotated ellipses are turned into polygons
+type EllipseCollision struct {
+	Cx, Cy float64
+	Rx, Ry float64
+}
+
+func (e *EllipseCollision) Intersects(p Point) bool {
+	if e.Rx <= 0 || e.Ry <= 0 {
+		return false
+	}
+	dx := (p.X - e.Cx) / e.Rx
+	dy := (p.Y - e.Cy) / e.Ry
+	return dx*dx+dy*dy <= 1
+}
+
+func (e *EllipseCollision) IntersectsLine(a Point, b Point) bool {
+	if e.Rx <= 0 || e.Ry <= 0 {
+		return false
+	}
+	// scale the ellipse into a unit circle and find the closest point
+	ax, ay := (a.X-e.Cx)/e.Rx, (a.Y-e.Cy)/e.Ry
+	bx, by := (b.X-e.Cx)/e.Rx, (b.Y-e.Cy)/e.Ry
+	dx, dy := bx-ax, by-ay
+	length := dx*dx + dy*dy
+	t := 0.0
+	if length > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+	}
+	cx, cy := ax+t*dx, ay+t*dy
+	return cx*cx+cy*cy <= 1
+}
+
+func (e *EllipseCollision) Bounds() RectangleCollision {
+	return RectangleCollision{Minx: e.Cx - e.Rx, Miny: e.Cy - e.Ry, Maxx: e.Cx + e.Rx, Maxy: e.Cy + e.Ry}
+}
+
+// Polygon approximates the ellipse with the given number of segments
+func (e *EllipseCollision) Polygon(segments int) []Point {
+	points := make([]Point, segments)
+	for i := 0; i < segments; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(segments)
+		points[i] = Point{X: e.Cx + e.Rx*math.Cos(angle), Y: e.Cy + e.Ry*math.Sin(angle)}
+	}
+	return points
+}
+
+func cross(o Point, a Point, b Point) float64 {
+	return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+}
+
+func onSegment(a Point, b Point, p Point) bool {
+	return math.Min(a.X, b.X)-collisionEpsilon <= p.X && p.X <= math.Max(a.X, b.X)+collisionEpsilon &&
+		math.Min(a.Y, b.Y)-collisionEpsilon <= p.Y && p.Y <= math.Max(a.Y, b.Y)+collisionEpsilon
+}
+
+func SegmentsIntersect(a1 Point, a2 Point, b1 Point, b2 Point) bool {
+	d1 := cross(b1, b2, a1)
+	d2 := cross(b1, b2, a2)
+	d3 := cross(a1, a2, b1)
+	d4 := cross(a1, a2, b2)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	if d1 == 0 && onSegment(b1, b2, a1) {
+		return true
+	}
+	if d2 == 0 && onSegment(b1, b2, a2) {
+		return true
+	}
+	if d3 == 0 && onSegment(a1, a2, b1) {
+		return true
+	}
+	if d4 == 0 && onSegment(a1, a2, b2) {
+		return true
+	}
+	return false
+}
+
+// ShapeCoversRect tells if the shape overlaps the inside of the rectangle,
+// shapes only touching the rectangle border dont count
+func ShapeCoversRect(shape CollisionShape, rect RectangleCollision) bool {
+	inner := RectangleCollision{
+		Minx: rect.Minx + collisionEpsilon,
+		Miny: rect.Miny + collisionEpsilon,
+		Maxx: rect.Maxx - collisionEpsilon,
+		Maxy: rect.Maxy - collisionEpsilon,
+	}
+	center := Point{X: (inner.Minx + inner.Maxx) / 2, Y: (inner.Miny + inner.Maxy) / 2}
+	if shape.Intersects(center) {
+		return true
+	}
+	corners := inner.Corners()
+	for i := range corners {
+		if shape.IntersectsLine(corners[i], corners[(i+1)%len(corners)]) {
+			return true
+		}
+	}
+	// shape smaller than the rectangle and completely inside of it
+	bounds := shape.Bounds()
+	return inner.Intersects(Point{X: bounds.Minx, Y: bounds.Miny}) && inner.Intersects(Point{X: bounds.Maxx, Y: bounds.Maxy})
+}
@@ -8,17 +8,21 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
+// Drag is the selection rectangle, in world pixels so it stays on the same
+// spot of the map when the camera moves while dragging
 type Drag struct {
-	Startx   int
-	Starty   int
-	Endx     int
-	Endy     int
+	Startx   float64
+	Starty   float64
+	Endx     float64
+	Endy     float64
 	Dragging bool
 }
 
 func (drag *Drag) Draw(screen *ebiten.Image, camera *config.Camera) {
 	if drag.Dragging {
-		vector.StrokeRect(screen, float32(drag.Startx), float32(drag.Starty), float32(drag.Endx-drag.Startx), float32(drag.Endy-drag.Starty), 0.5, color.RGBA{0, 255, 0, 125}, true)
+		sx, sy := camera.WorldToScreen(drag.Startx, drag.Starty)
+		ex, ey := camera.WorldToScreen(drag.Endx, drag.Endy)
+		vector.StrokeRect(screen, float32(sx), float32(sy), float32(ex-sx), float32(ey-sy), 0.5, color.RGBA{0, 255, 0, 125}, true)
 
 	}
 }
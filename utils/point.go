@@ -12,6 +12,7 @@ type Node struct {
 type CollisionShape interface {
 	Intersects(Point) bool
 	IntersectsLine(Point, Point) bool
+	Bounds() RectangleCollision
 }
 
 type RectangleCollision struct {
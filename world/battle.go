@@ -0,0 +1,393 @@
+package world
+
+import (
+	"bilydaniel/rpg/combat"
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/quest"
+	"bilydaniel/rpg/stats"
+	"bilydaniel/rpg/utils"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	partyTeam = 0
+	npcTeam   = 1
+	// ticks between the steps of computer controlled combatants
+	aiStepTicks = 20
+	// combat log lines shown on the screen
+	battleLogLines = 4
+)
+
+// Battle connects a combat encounter to the characters on the level, the
+// rules are in the combat package
+type Battle struct {
+	Encounter  *combat.Encounter
+	Level      *Level
+	sprites    map[string]entities.Sprite
+	characters map[string]*entities.Character
+	npcIDs     map[string]string //combatant id => npc id
+	walking    *entities.PCharacter
+	npcPath    []combat.Tile
+	npcWalker  *entities.Npc
+	striking   *entities.AnimatedSprite //attack that hasnt landed yet
+	wait       int
+}
+
+// battleMap lets the combat rules see the level, tiles of other combatants
+// are handled by the encounter
+type battleMap struct {
+	battle *Battle
+}
+
+func (m battleMap) Walkable(x, y int) bool {
+	level := m.battle.Level
+	if !level.InBounds(x, y) || !level.Grid[y][x].Walkable {
+		return false
+	}
+	occupant := level.Occupancy[y][x]
+	if occupant == nil {
+		return true
+	}
+	for _, sprite := range m.battle.sprites {
+		if sprite == occupant {
+			return true
+		}
+	}
+	return false
+}
+
+func (m battleMap) LineOfSight(from combat.Tile, to combat.Tile) bool {
+	pf := PathFinder{}
+	return pf.LineOfSight(m.battle.Level.NavGrid(), utils.Node{X: from.X, Y: from.Y}, utils.Node{X: to.X, Y: to.Y})
+}
+
+func spriteTile(sprite entities.Sprite) combat.Tile {
+	return combat.Tile{X: int(math.Round(sprite.GetX())), Y: int(math.Round(sprite.GetY()))}
+}
+
+// StartBattle starts a fight between the party and the npcs of the current
+// level close to it, nil when there is nobody to fight
+func (w *World) StartBattle(pcharacters []*entities.PCharacter, seed int64) (*Battle, error) {
+	level := w.CurrentLevel
+	battle := &Battle{
+		Level:      level,
+		sprites:    map[string]entities.Sprite{},
+		characters: map[string]*entities.Character{},
+		npcIDs:     map[string]string{},
+	}
+	combatants := []*combat.Combatant{}
+
+	for _, id := range w.SortedNpcIDs() {
+		npc := w.Npcs[id]
+		// villagers standing around stay out of it
+		if !npc.Hostile || npc.LevelName != level.Name || !npc.Stats.Alive() {
+			continue
+		}
+		tile := spriteTile(npc)
+		close := false
+		for _, pchar := range pcharacters {
+			close = close || combat.Distance(tile, spriteTile(pchar)) <= config.EncounterRadius
+		}
+		if !close || !level.Fog.IsVisible(tile.X, tile.Y) {
+			continue
+		}
+		combatantID := "npc " + id
+		combatants = append(combatants, w.newCombatant(combatantID, npcTeam, tile, &npc.Character))
+		battle.sprites[combatantID] = npc
+		battle.characters[combatantID] = &npc.Character
+		battle.npcIDs[combatantID] = id
+	}
+	if len(combatants) == 0 {
+		return nil, nil
+	}
+
+	for _, pchar := range pcharacters {
+		if !pchar.Stats.Alive() {
+			continue
+		}
+		// the fight happens on whole tiles
+		pchar.ResetWalking()
+		tile := spriteTile(pchar)
+		pchar.SetPosition(float64(tile.X), float64(tile.Y))
+		combatants = append(combatants, w.newCombatant(pchar.Name, partyTeam, tile, &pchar.Character))
+		battle.sprites[pchar.Name] = pchar
+		battle.characters[pchar.Name] = &pchar.Character
+	}
+
+	encounter, err := combat.NewEncounter(combatants, seed)
+	if err != nil {
+		return nil, err
+	}
+	battle.Encounter = encounter
+	return battle, nil
+}
+
+// newCombatant takes the numbers from the stats and the attacks from the class
+func (w *World) newCombatant(id string, team int, tile combat.Tile, character *entities.Character) *combat.Combatant {
+	attacks := []combat.Attack{}
+	for _, name := range w.Classes[character.Stats.Class].Attacks {
+		if attack, ok := combat.AttacksByName[name]; ok {
+			attacks = append(attacks, attack)
+		}
+	}
+	if len(attacks) == 0 {
+		attacks = append(attacks, combat.Melee)
+	}
+	s := character.Stats
+	return &combat.Combatant{
+		ID:         id,
+		Team:       team,
+		Tile:       tile,
+		HP:         s.HP,
+		MaxHP:      s.MaxHP(),
+		MaxAP:      int(s.Get(stats.ActionPoints)),
+		Initiative: int(s.Get(stats.Initiative)),
+		Armour:     int(s.Get(stats.Armour)),
+		Attacks:    attacks,
+		AI:         team != partyTeam,
+	}
+}
+
+// Busy is true while someone is still walking
+func (b *Battle) Busy() bool {
+	return (b.walking != nil && len(b.walking.Path) > 0) || len(b.npcPath) > 0 || b.striking != nil
+}
+
+// PlayerTurn is true when the player controls the current combatant
+func (b *Battle) PlayerTurn() bool {
+	current := b.Encounter.Current()
+	return current != nil && !current.AI && !b.Busy()
+}
+
+// Click acts with the current character on the clicked tile, attacking an
+// enemy on it or walking there
+func (b *Battle) Click(tile combat.Tile) {
+	if !b.PlayerTurn() {
+		return
+	}
+	m := battleMap{battle: b}
+	current := b.Encounter.Current()
+	for _, target := range b.Encounter.Combatants {
+		if target.Tile != tile || !target.Alive() || target.Team == current.Team {
+			continue
+		}
+		for _, attack := range current.Attacks {
+			if b.Encounter.CanAttack(m, current, target, attack) == nil {
+				b.Encounter.Attack(m, target, attack)
+				if !b.strike(current, target) {
+					b.sync()
+				}
+				return
+			}
+		}
+		return
+	}
+
+	path, err := b.Encounter.Move(m, tile)
+	if err != nil {
+		return
+	}
+	if pchar, ok := b.sprites[current.ID].(*entities.PCharacter); ok {
+		pchar.SetPath(nodesFromTiles(path))
+		b.walking = pchar
+	}
+}
+
+// EndTurn ends the players turn
+func (b *Battle) EndTurn() {
+	if b.PlayerTurn() {
+		b.Encounter.EndTurn()
+	}
+}
+
+// Update walks the npcs and plays the computer controlled turns
+func (b *Battle) Update() {
+	if b.walking != nil && len(b.walking.Path) == 0 {
+		b.walking = nil
+	}
+	if b.striking != nil {
+		if b.striking.Fired(entities.EventHit) {
+			b.sync()
+		}
+		if b.striking.Acting() {
+			return
+		}
+		b.sync()
+		b.striking = nil
+	}
+	if b.wait > 0 {
+		b.wait--
+		return
+	}
+	if len(b.npcPath) > 0 {
+		b.stepNpc()
+		b.wait = aiStepTicks / 4
+		return
+	}
+
+	current := b.Encounter.Current()
+	if current == nil || !current.AI || b.Encounter.Over() {
+		return
+	}
+	action, ok := b.Encounter.AIStep(battleMap{battle: b})
+	if !ok {
+		b.Encounter.EndTurn()
+		return
+	}
+	if len(action.Path) > 0 {
+		if npc, ok := b.sprites[current.ID].(*entities.Npc); ok {
+			b.npcPath = action.Path
+			b.npcWalker = npc
+		}
+	}
+	if action.Target == nil || !b.strike(current, action.Target) {
+		b.sync()
+	}
+	b.wait = aiStepTicks
+}
+
+// stepNpc moves the walking npc one tile along its combat path
+func (b *Battle) stepNpc() {
+	npc := b.npcWalker
+	from := spriteTile(npc)
+	next := b.npcPath[0]
+	b.npcPath = b.npcPath[1:]
+	if b.Level.TileOccupant(&utils.Node{X: from.X, Y: from.Y}) == npc {
+		b.Level.SetTileOccupied(nil, from.X, from.Y)
+	}
+	npc.SetPosition(float64(next.X), float64(next.Y))
+	b.Level.SetTileOccupied(npc, next.X, next.Y)
+}
+
+// strike plays the attack of the attacker facing the target, the hit points
+// change when the blow lands. False when its sprite has no attack to play.
+func (b *Battle) strike(attacker *combat.Combatant, target *combat.Combatant) bool {
+	var sprite entities.Sprite
+	switch character := b.sprites[attacker.ID].(type) {
+	case *entities.Npc:
+		sprite = character.Sprite
+	case *entities.PCharacter:
+		sprite = character.Sprite
+	}
+	animated, ok := sprite.(*entities.AnimatedSprite)
+	if !ok {
+		return false
+	}
+	animated.Face(float64(target.Tile.X), float64(target.Tile.Y))
+	if !animated.PlayAction(entities.ClipAttack) {
+		return false
+	}
+	b.striking = animated
+	return true
+}
+
+// sync copies the hit points back to the characters
+func (b *Battle) sync() {
+	for _, combatant := range b.Encounter.Combatants {
+		b.characters[combatant.ID].Stats.HP = combatant.HP
+	}
+}
+
+func (b *Battle) Over() bool {
+	return b.Encounter.Over() && !b.Busy()
+}
+
+// Finish ends the battle, fallen npcs leave the level and give experience to
+// the party, knocked out party members get back up with one hit point
+func (b *Battle) Finish(w *World) {
+	b.sync()
+	experience := 0
+	for combatantID, npcID := range b.npcIDs {
+		npc := w.Npcs[npcID]
+		if npc.Stats.Alive() {
+			continue
+		}
+		experience += stats.ExperienceReward(npc.Stats.Level)
+		w.Fire(quest.Event{Kind: quest.Defeat, Target: npc.Stats.Class})
+		// they stay in the world so saves from before the fight still work
+		tile := spriteTile(npc)
+		if b.Level.TileOccupant(&utils.Node{X: tile.X, Y: tile.Y}) == npc {
+			b.Level.SetTileOccupied(nil, tile.X, tile.Y)
+		}
+		delete(b.sprites, combatantID)
+	}
+	//TODO game over screen when the whole party is down
+	for _, sprite := range b.sprites {
+		if pchar, ok := sprite.(*entities.PCharacter); ok {
+			if pchar.Stats.Alive() {
+				pchar.Stats.AddExperience(experience, w.Classes[pchar.Stats.Class])
+			}
+			pchar.Stats.HP = max(pchar.Stats.HP, 1)
+		}
+	}
+}
+
+// HoveredTile is the tile under the cursor
+func HoveredTile(cam *config.Camera, screenx, screeny int) combat.Tile {
+	worldx, worldy := cam.ScreenToWorld(float64(screenx), float64(screeny))
+	return combat.Tile{X: int(math.Floor(worldx / config.TileSize)), Y: int(math.Floor(worldy / config.TileSize))}
+}
+
+// Draw shows the movement range and attack targets of the current character,
+// the hit points of everyone and the end of the combat log
+func (b *Battle) Draw(screen *ebiten.Image, cam *config.Camera) {
+	current := b.Encounter.Current()
+	if current == nil {
+		return
+	}
+	m := battleMap{battle: b}
+	if b.PlayerTurn() {
+		for tile, cost := range b.Encounter.MovementRange(m, current) {
+			if cost > 0 {
+				drawTileRect(screen, cam, tile, color.RGBA{0, 40, 90, 90})
+			}
+		}
+		for _, target := range b.Encounter.Combatants {
+			for _, attack := range current.Attacks {
+				if b.Encounter.CanAttack(m, current, target, attack) == nil {
+					drawTileRect(screen, cam, target.Tile, color.RGBA{120, 0, 0, 120})
+					break
+				}
+			}
+		}
+	}
+	drawTileRect(screen, cam, current.Tile, color.RGBA{100, 100, 0, 100})
+
+	for _, combatant := range b.Encounter.Combatants {
+		if !combatant.Alive() {
+			continue
+		}
+		x, y := cam.WorldToScreen(float64(combatant.Tile.X*config.TileSize), float64(combatant.Tile.Y*config.TileSize)-4)
+		width := float32(config.TileSize * cam.Scale)
+		vector.DrawFilledRect(screen, float32(x), float32(y), width, 2, color.RGBA{60, 0, 0, 255}, false)
+		vector.DrawFilledRect(screen, float32(x), float32(y), width*float32(combatant.HP)/float32(combatant.MaxHP), 2, color.RGBA{0, 200, 0, 255}, false)
+	}
+
+	bounds := screen.Bounds()
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("round %d: %s AP %d (space ends turn)", b.Encounter.Round, current.ID, current.AP), 0, bounds.Dy()-16*(battleLogLines+1))
+	log := b.Encounter.Log[max(len(b.Encounter.Log)-battleLogLines, 0):]
+	for i, line := range log {
+		ebitenutil.DebugPrintAt(screen, line, 0, bounds.Dy()-16*(battleLogLines-i))
+	}
+}
+
+func drawTileRect(screen *ebiten.Image, cam *config.Camera, tile combat.Tile, clr color.RGBA) {
+	x, y := cam.WorldToScreen(float64(tile.X*config.TileSize), float64(tile.Y*config.TileSize))
+	size := float32(config.TileSize * cam.Scale)
+	vector.DrawFilledRect(screen, float32(x), float32(y), size, size, clr, false)
+}
+
+func nodesFromTiles(tiles []combat.Tile) []utils.Node {
+	path := []utils.Node{}
+	for _, tile := range tiles {
+		path = append(path, utils.Node{X: tile.X, Y: tile.Y})
+	}
+	return path
+}
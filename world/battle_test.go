@@ -0,0 +1,49 @@
+package world
+
+import (
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/stats"
+	"testing"
+)
+
+func TestBattleLeavesVillagersOut(t *testing.T) {
+	w, party := testWorld(t)
+	level := w.CurrentLevel
+	// everybody of the level crowds around the party next to a wolf
+	villagers := 0
+	for _, id := range w.SortedNpcIDs() {
+		npc := w.Npcs[id]
+		if npc.LevelName == level.Name {
+			npc.SetPosition(float64(2+villagers%3), 3)
+			villagers++
+		}
+	}
+	if villagers == 0 {
+		t.Fatal("No villagers on the level")
+	}
+	w.Npcs["wolf"] = &entities.Npc{
+		Sprite:    &entities.CircleSprite{X: 4, Y: 2},
+		Character: entities.Character{Id: "wolf", Stats: stats.New(w.Classes["wolf"])},
+		LevelName: level.Name,
+		Hostile:   true,
+	}
+	level.UpdateFog(party)
+
+	battle, err := w.StartBattle(party, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if battle == nil {
+		t.Fatal("No battle with the wolf")
+	}
+	if len(battle.npcIDs) != 1 || battle.npcIDs["npc wolf"] != "wolf" {
+		t.Fatalf("Npcs in the battle %v, want only the wolf", battle.npcIDs)
+	}
+
+	// without the wolf there is nobody to fight
+	w.Npcs["wolf"].Stats.HP = 0
+	battle, err = w.StartBattle(party, 1)
+	if err != nil || battle != nil {
+		t.Fatalf("Battle %v with villagers only, %v", battle, err)
+	}
+}
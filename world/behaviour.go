@@ -0,0 +1,169 @@
+package world
+
+import (
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/utils"
+	"math"
+)
+
+const (
+	defaultBehaviourDistance = 3.0
+	wanderRadius             = 5
+	// ticks between wander steps are picked from this range
+	wanderMinWait = 60
+	wanderMaxWait = 240
+	// how often follow and flee look at their target again
+	behaviourThinkTicks = 30
+	// after asking for a path, wait this long before asking again
+	pathRequestWait = 20
+)
+
+// UpdateBehaviour lets the npc decide where to go, the path comes from the
+// path system and Npc.Update walks it
+func (w *World) UpdateBehaviour(npc *entities.Npc, level *Level, paths *PathSystem, pcharacters []*entities.PCharacter) {
+	if w.Chasing(npc.Id) {
+		return
+	}
+	behaviour, active := npc.BehaviourAt(w.Clock.Hour())
+	if active != npc.State.Active {
+		// the schedule moved on, start the new behaviour fresh
+		npc.State = entities.BehaviourState{Active: active}
+		paths.CancelOwner(npc)
+		npc.SetPath(nil)
+	}
+	if npc.State.Wait > 0 {
+		npc.State.Wait--
+		return
+	}
+
+	tile := spriteNode(npc)
+	walking := len(npc.Path) > 0
+	switch behaviour.Kind {
+	case entities.Wander:
+		if walking {
+			return
+		}
+		goal, ok := w.wanderGoal(npc, level, behaviour)
+		if ok {
+			paths.RequestPath(npc, level, tile, goal, PathAStar)
+		}
+		npc.State.Wait = wanderMinWait + w.Rand.Intn(wanderMaxWait-wanderMinWait)
+
+	case entities.Patrol:
+		if walking {
+			return
+		}
+		npc.State.Wait = pathRequestWait
+		route := behaviour.Route
+		if tile == route[npc.State.RouteIndex%len(route)] {
+			npc.State.RouteIndex = (npc.State.RouteIndex + 1) % len(route)
+		}
+		// buildings can cover route tiles, go on to the next one instead
+		for range route {
+			next := route[npc.State.RouteIndex%len(route)]
+			if next != tile && level.WalkableTile(&next) {
+				paths.RequestPath(npc, level, tile, next, PathAStar)
+				return
+			}
+			npc.State.RouteIndex = (npc.State.RouteIndex + 1) % len(route)
+		}
+
+	case entities.Follow:
+		target, ok := w.behaviourTarget(npc, behaviour.Target, pcharacters)
+		npc.State.Wait = behaviourThinkTicks
+		if !ok {
+			return
+		}
+		targetTile := spriteNode(target)
+		if tileDistance(tile, targetTile) <= behaviour.Distance {
+			paths.CancelOwner(npc)
+			npc.SetPath(nil)
+			return
+		}
+		goal, ok := level.freeTileNear(targetTile, map[utils.Node]bool{targetTile: true})
+		if !ok {
+			return
+		}
+		paths.RequestPath(npc, level, tile, goal, PathAStar)
+
+	case entities.Flee:
+		target, ok := w.behaviourTarget(npc, behaviour.Target, pcharacters)
+		npc.State.Wait = behaviourThinkTicks
+		if !ok || walking {
+			return
+		}
+		targetTile := spriteNode(target)
+		dist := tileDistance(tile, targetTile)
+		if dist > behaviour.Distance {
+			return
+		}
+		// run straight away from the target, far enough to be safe
+		dx, dy := float64(tile.X-targetTile.X), float64(tile.Y-targetTile.Y)
+		if dist == 0 {
+			dx, dy = 1, 0
+			dist = 1
+		}
+		away := utils.Node{
+			X: tile.X + int(math.Round(dx/dist*(behaviour.Distance+1))),
+			Y: tile.Y + int(math.Round(dy/dist*(behaviour.Distance+1))),
+		}
+		goal, ok := level.freeTileNear(away, map[utils.Node]bool{})
+		if !ok {
+			return
+		}
+		paths.RequestPath(npc, level, tile, goal, PathAStar)
+
+	default:
+		// idle npcs finish walking and then stand where they are
+	}
+}
+
+// wanderGoal picks a random free tile of the area, or around home without one
+func (w *World) wanderGoal(npc *entities.Npc, level *Level, behaviour entities.Behaviour) (utils.Node, bool) {
+	minx, miny := npc.Home.X-wanderRadius, npc.Home.Y-wanderRadius
+	maxx, maxy := npc.Home.X+wanderRadius, npc.Home.Y+wanderRadius
+	if area := level.AreaByName(behaviour.Area); area != nil {
+		first := level.NodeFromPoint(utils.Point{X: area.Rect.Minx, Y: area.Rect.Miny})
+		last := level.NodeFromPoint(utils.Point{X: area.Rect.Maxx - 1, Y: area.Rect.Maxy - 1})
+		minx, miny, maxx, maxy = first.X, first.Y, last.X, last.Y
+	}
+	// a few tries, walls and other people are in the way sometimes
+	for range 8 {
+		node := utils.Node{X: minx + w.Rand.Intn(maxx-minx+1), Y: miny + w.Rand.Intn(maxy-miny+1)}
+		if level.InBounds(node.X, node.Y) && level.WalkableTile(&node) && !level.OccupiedTile(&node) {
+			return node, true
+		}
+	}
+	return utils.Node{}, false
+}
+
+// behaviourTarget finds the npc or the closest party member on the same level
+func (w *World) behaviourTarget(npc *entities.Npc, target string, pcharacters []*entities.PCharacter) (entities.Sprite, bool) {
+	if target == "party" {
+		if npc.LevelName != w.CurrentLevel.Name {
+			return nil, false
+		}
+		var closest entities.Sprite
+		closestDist := math.MaxFloat64
+		for _, pchar := range pcharacters {
+			dist := tileDistance(spriteNode(npc), spriteNode(pchar))
+			if dist < closestDist {
+				closest, closestDist = pchar, dist
+			}
+		}
+		return closest, closest != nil
+	}
+	other, ok := w.Npcs[target]
+	if !ok || other.LevelName != npc.LevelName || !other.Stats.Alive() {
+		return nil, false
+	}
+	return other, true
+}
+
+func spriteNode(sprite entities.Sprite) utils.Node {
+	return utils.Node{X: int(math.Round(sprite.GetX())), Y: int(math.Round(sprite.GetY()))}
+}
+
+func tileDistance(a, b utils.Node) float64 {
+	return math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+}
@@ -0,0 +1,145 @@
+package world
+
+import (
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/stats"
+	"bilydaniel/rpg/utils"
+	"math"
+	"testing"
+)
+
+func testPCharacter(x, y float64) *entities.PCharacter {
+	class := stats.Class{Attributes: map[stats.Stat]float64{stats.Agility: 10}}
+	return &entities.PCharacter{
+		Name:      "red",
+		Sprite:    &entities.CircleSprite{X: x, Y: y},
+		Character: entities.Character{Stats: stats.New(class)},
+	}
+}
+
+// walkTo sends the character along a path and repaths like the game does, the character must never step on the blocker. False when it didnt
+// arrive in time.
+func walkTo(t *testing.T, level *Level, pchar *entities.PCharacter, mode PathMode, goal utils.Node, blocker utils.Node, ticks int, tick func(int)) bool {
+	t.Helper()
+	pf := &PathFinder{}
+	start := spriteNode(pchar)
+	pchar.SetPath(ForwardPath(pf.FindPath(level.NavGrid(), start, goal, mode, nil)))
+	for i := 0; i < ticks; i++ {
+		if tick != nil {
+			tick(i)
+		}
+		pchar.Update(level)
+		if pchar.NeedsRepath {
+			pchar.NeedsRepath = false
+			pchar.Repaths++
+			pathGoal, ok := pchar.PathGoal()
+			if ok {
+				reversed := pf.FindPath(level.NavGridAvoiding(pchar), spriteNode(pchar), pathGoal, PathAStar, nil)
+				pchar.SetPath(ForwardPath(reversed))
+			}
+		}
+		if occupant := level.TileOccupant(&blocker); occupant != nil && occupant != entities.Sprite(pchar) && math.Hypot(pchar.GetX()-float64(blocker.X), pchar.GetY()-float64(blocker.Y)) < 0.5 {
+			t.Fatalf("Walked into the blocker at tick %d", i)
+		}
+		if spriteNode(pchar) == goal && len(pchar.Path) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBlockedSidestep(t *testing.T) {
+	level := openLevel(10, 5)
+	pchar := testPCharacter(0, 2)
+	blocker := utils.Node{X: 4, Y: 2}
+	level.SetTileOccupied(&entities.CircleSprite{X: 4, Y: 2}, blocker.X, blocker.Y)
+
+	if !walkTo(t, level, pchar, PathAStar, utils.Node{X: 9, Y: 2}, blocker, 3000, nil) {
+		t.Fatalf("Stuck at %v %v", spriteNode(pchar), pchar.Path)
+	}
+	if pchar.Repaths != 0 {
+		t.Fatalf("Sidestep should be enough, repathed %d times", pchar.Repaths)
+	}
+}
+
+func TestBlockedSidestepStraight(t *testing.T) {
+	// theta* goes there in one straight segment, the blocker is in between
+	level := openLevel(10, 5)
+	pchar := testPCharacter(0, 2)
+	blocker := utils.Node{X: 4, Y: 2}
+	level.SetTileOccupied(&entities.CircleSprite{X: 4, Y: 2}, blocker.X, blocker.Y)
+
+	if !walkTo(t, level, pchar, PathThetaStar, utils.Node{X: 9, Y: 2}, blocker, 3000, nil) {
+		t.Fatalf("Stuck at %v %v", spriteNode(pchar), pchar.Path)
+	}
+	if pchar.Repaths != 0 {
+		t.Fatalf("Sidestep should be enough, repathed %d times", pchar.Repaths)
+	}
+}
+
+func TestBlockedRepathAround(t *testing.T) {
+	// a corridor with a loop below it, stepping aside is not possible
+	level := openLevel(10, 4)
+	for x := 0; x < 10; x++ {
+		level.Grid[0][x].Walkable = false
+		if x != 2 && x != 8 {
+			level.Grid[2][x].Walkable = false
+		}
+	}
+	pchar := testPCharacter(0, 1)
+	blocker := utils.Node{X: 5, Y: 1}
+	level.SetTileOccupied(&entities.CircleSprite{X: 5, Y: 1}, blocker.X, blocker.Y)
+
+	if !walkTo(t, level, pchar, PathAStar, utils.Node{X: 9, Y: 1}, blocker, 3000, nil) {
+		t.Fatalf("Stuck at %v", spriteNode(pchar))
+	}
+	if pchar.Repaths != 1 {
+		t.Fatalf("Repathed %d times, want 1", pchar.Repaths)
+	}
+}
+
+func TestBlockedGivesUp(t *testing.T) {
+	// the only way is blocked for good
+	level := openLevel(10, 1)
+	pchar := testPCharacter(0, 0)
+	blocker := utils.Node{X: 5, Y: 0}
+	level.SetTileOccupied(&entities.CircleSprite{X: 5, Y: 0}, blocker.X, blocker.Y)
+
+	if walkTo(t, level, pchar, PathAStar, utils.Node{X: 9, Y: 0}, blocker, 2000, nil) {
+		t.Fatal("Walked through the blocker")
+	}
+	if len(pchar.Path) != 0 {
+		t.Fatalf("Still walking %v", pchar.Path)
+	}
+	if spriteNode(pchar).X >= blocker.X {
+		t.Fatalf("Got past the blocker to %v", spriteNode(pchar))
+	}
+}
+
+func TestBlockedWaits(t *testing.T) {
+	// the blocker leaves before the character would step aside
+	level := openLevel(10, 1)
+	pchar := testPCharacter(0, 0)
+	blocker := utils.Node{X: 3, Y: 0}
+	sprite := &entities.CircleSprite{X: 3, Y: 0}
+	level.SetTileOccupied(sprite, blocker.X, blocker.Y)
+
+	blockedAt := -1
+	leave := func(tick int) {
+		if blockedAt < 0 && pchar.BlockedTicks > 0 {
+			blockedAt = tick
+		}
+		if blockedAt >= 0 && tick == blockedAt+5 {
+			level.SetTileOccupied(nil, blocker.X, blocker.Y)
+		}
+	}
+	if !walkTo(t, level, pchar, PathAStar, utils.Node{X: 9, Y: 0}, blocker, 3000, leave) {
+		t.Fatalf("Stuck at %v", spriteNode(pchar))
+	}
+	if blockedAt < 0 {
+		t.Fatal("Never waited for the blocker")
+	}
+	if pchar.Repaths != 0 {
+		t.Fatalf("Repathed %d times while waiting", pchar.Repaths)
+	}
+}
@@ -0,0 +1,222 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	// ChunkSize is the width and height of a baked chunk in tiles
+	ChunkSize = 16
+	// chunks that were not on screen for this many frames get thrown away
+	chunkEvictFrames = 180
+)
+
+type chunkKey struct {
+	Layer int //Tiled layer id
+	X, Y  int //in chunks
+}
+
+// tileKey is one tile of one tile layer
+type tileKey struct {
+	Layer int
+	X, Y  int
+}
+
+type chunk struct {
+	Image    *ebiten.Image
+	LastUsed int
+}
+
+// ChunkRange is an inclusive range of chunk coordinates
+type ChunkRange struct {
+	MinX, MinY int
+	MaxX, MaxY int
+}
+
+func (r ChunkRange) Empty() bool {
+	return r.MaxX < r.MinX || r.MaxY < r.MinY
+}
+
+// VisibleChunks returns the chunks that intersect the view rectangle given in
+// world pixels, overhang is how far tiles can stick out above their chunk.
+// Pure math so it can run without a GPU.
+func VisibleChunks(viewMinX, viewMinY, viewMaxX, viewMaxY float64, chunkPixels float64, overhang float64, chunksX, chunksY int) ChunkRange {
+	r := ChunkRange{
+		MinX: int(math.Floor(viewMinX / chunkPixels)),
+		MinY: int(math.Floor(viewMinY / chunkPixels)),
+		MaxX: int(math.Floor(viewMaxX / chunkPixels)),
+		// a chunk below the view can still draw tall tiles into it
+		MaxY: int(math.Floor((viewMaxY + overhang) / chunkPixels)),
+	}
+	r.MinX = max(r.MinX, 0)
+	r.MinY = max(r.MinY, 0)
+	r.MaxX = min(r.MaxX, chunksX-1)
+	r.MaxY = min(r.MaxY, chunksY-1)
+	return r
+}
+
+func (l *Level) chunkCounts() (int, int) {
+	return (l.Width + ChunkSize - 1) / ChunkSize, (l.Height + ChunkSize - 1) / ChunkSize
+}
+
+// tileOverhang is how many pixels the tallest tile sticks out above its cell
+func (l *Level) tileOverhang() int {
+	overhang := 0
+	for _, tileset := range l.Tilesets {
+		if tileset.Data.TilesImage && tileset.Data.TileHeight > config.TileSize {
+			overhang = max(overhang, tileset.Data.TileHeight-config.TileSize)
+		}
+	}
+	return overhang
+}
+
+// drawChunkedTileLayer draws only the chunks of the layer the camera can see,
+// baking the ones that are not cached yet
+func (l *Level) drawChunkedTileLayer(target *ebiten.Image, cam *config.Camera, gameAssets *assets.Assets, layer assets.TilemapLayer, opacity float64) {
+	if l.chunks == nil {
+		l.chunks = map[chunkKey]*chunk{}
+	}
+
+	bounds := target.Bounds()
+	minx, miny := cam.ScreenToWorld(0, 0)
+	maxx, maxy := cam.ScreenToWorld(float64(bounds.Dx()), float64(bounds.Dy()))
+	chunksX, chunksY := l.chunkCounts()
+	overhang := l.tileOverhang()
+	visible := VisibleChunks(minx, miny, maxx, maxy, ChunkSize*config.TileSize, float64(overhang), chunksX, chunksY)
+	if visible.Empty() {
+		return
+	}
+
+	opts := ebiten.DrawImageOptions{}
+	for cy := visible.MinY; cy <= visible.MaxY; cy++ {
+		for cx := visible.MinX; cx <= visible.MaxX; cx++ {
+			key := chunkKey{Layer: layer.ID, X: cx, Y: cy}
+			c, ok := l.chunks[key]
+			if !ok {
+				c = &chunk{Image: l.bakeChunk(gameAssets, layer, cx, cy, overhang)}
+				l.chunks[key] = c
+			}
+			c.LastUsed = l.frame
+			if c.Image == nil {
+				continue
+			}
+
+			opts.GeoM.Reset()
+			opts.ColorScale.Reset()
+			cam.WorldToScreenGeom(&opts, cx*ChunkSize*config.TileSize, cy*ChunkSize*config.TileSize-overhang)
+			opts.ColorScale.ScaleAlpha(float32(opacity))
+			target.DrawImage(c.Image, &opts)
+		}
+	}
+}
+
+// bakeChunk draws the tiles of one chunk into its own image, nil when the
+// chunk is empty
+func (l *Level) bakeChunk(gameAssets *assets.Assets, layer assets.TilemapLayer, cx, cy int, overhang int) *ebiten.Image {
+	var image *ebiten.Image
+	opts := ebiten.DrawImageOptions{}
+
+	for y := cy * ChunkSize; y < min((cy+1)*ChunkSize, layer.Height); y++ {
+		for x := cx * ChunkSize; x < min((cx+1)*ChunkSize, layer.Width); x++ {
+			gid, flags := assets.SplitGID(layer.Data[y*layer.Width+x])
+			if gid == 0 {
+				continue
+			}
+			tile := l.TileImage(gameAssets, gid)
+			if tile == nil {
+				continue
+			}
+			if image == nil {
+				image = ebiten.NewImage(ChunkSize*config.TileSize, ChunkSize*config.TileSize+overhang)
+			}
+
+			tileBounds := tile.Bounds()
+			opts.GeoM.Reset()
+			applyFlip(&opts.GeoM, flags, float64(tileBounds.Dx()), float64(tileBounds.Dy()))
+			// tiles bigger than the grid stick out upwards like in Tiled
+			opts.GeoM.Translate(float64((x-cx*ChunkSize)*config.TileSize), float64((y-cy*ChunkSize+1)*config.TileSize+overhang-tileBounds.Dy()))
+			image.DrawImage(tile, &opts)
+		}
+	}
+	return image
+}
+
+// evictChunks drops the chunks that were off screen for a while so big maps
+// dont keep every chunk in memory
+func (l *Level) evictChunks() {
+	for key, c := range l.chunks {
+		if l.frame-c.LastUsed > chunkEvictFrames {
+			if c.Image != nil {
+				c.Image.Deallocate()
+			}
+			delete(l.chunks, key)
+		}
+	}
+}
+
+// InvalidateTile rebakes the chunks containing the tile on the next draw
+func (l *Level) InvalidateTile(x, y int) {
+	for key, c := range l.chunks {
+		if key.X == x/ChunkSize && key.Y == y/ChunkSize {
+			if c.Image != nil {
+				c.Image.Deallocate()
+			}
+			delete(l.chunks, key)
+		}
+	}
+}
+
+// SetTileGID changes a tile of a tile layer, on the ground layer it also
+// changes the terrain and walkability of the tile
+func (l *Level) SetTileGID(layerID int, x, y int, raw uint32) {
+	if !l.InBounds(x, y) {
+		return
+	}
+	layer := findLayer(l.Layers, layerID)
+	if layer == nil || layer.Type != "tilelayer" || x >= layer.Width || y >= layer.Height {
+		return
+	}
+	layer.Data[y*layer.Width+x] = raw
+	l.changed[tileKey{Layer: layerID, X: x, Y: y}] = raw
+	l.InvalidateTile(x, y)
+
+	if layer.Name != groundLayerName(l.Layers) {
+		return
+	}
+	gid, _ := assets.SplitGID(raw)
+	terrain, ok := l.Terrains[gid]
+	if !ok {
+		terrain = DefaultTerrainInfo()
+	}
+	tile := l.Grid[y][x]
+	tile.ID = gid
+	tile.Cost = terrain.Cost
+	tile.Terrain = terrain.Terrain
+	l.navGrid = nil
+	l.markClusterDirty(x, y)
+	covered := false
+	for _, shapes := range l.Footprints {
+		for _, shape := range shapes {
+			l.shapeTiles(shape, func(sx, sy int) {
+				covered = covered || (sx == x && sy == y)
+			})
+		}
+	}
+	l.SetWalkable(x, y, terrain.Walkable && !covered)
+}
+
+func findLayer(layers []assets.TilemapLayer, id int) *assets.TilemapLayer {
+	for i := range layers {
+		if layers[i].ID == id && layers[i].Type != "group" {
+			return &layers[i]
+		}
+		if found := findLayer(layers[i].Layers, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
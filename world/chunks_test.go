@@ -0,0 +1,72 @@
+package world
+
+import (
+	"bilydaniel/rpg/config"
+	"testing"
+)
+
+// cameraViews pans and zooms over a map of the size in pixels
+func cameraViews(w, h float64) []*config.Camera {
+	cams := []*config.Camera{}
+	for _, scale := range []float64{0.5, 1, 2, 3} {
+		for y := -200.0; y < h; y += 173 {
+			for x := -200.0; x < w; x += 211 {
+				cam := config.NewCamera()
+				cam.X, cam.Y, cam.Scale = x, y, scale
+				cams = append(cams, cam)
+			}
+		}
+	}
+	return cams
+}
+
+func TestVisibleChunks(t *testing.T) {
+	chunksX, chunksY := 20, 12
+	chunkPixels := float64(ChunkSize * config.TileSize)
+	overhang := 32.0
+	for _, cam := range cameraViews(float64(chunksX)*chunkPixels, float64(chunksY)*chunkPixels) {
+		minx, miny, maxx, maxy := cam.ViewRect()
+		visible := VisibleChunks(minx, miny, maxx, maxy, chunkPixels, overhang, chunksX, chunksY)
+		for cy := 0; cy < chunksY; cy++ {
+			for cx := 0; cx < chunksX; cx++ {
+				// the chunk image starts overhang pixels above the chunk
+				left, top := float64(cx)*chunkPixels, float64(cy)*chunkPixels-overhang
+				right, bottom := left+chunkPixels, float64(cy+1)*chunkPixels
+				overlaps := left < maxx && right > minx && top < maxy && bottom > miny
+				inRange := cx >= visible.MinX && cx <= visible.MaxX && cy >= visible.MinY && cy <= visible.MaxY
+				if overlaps && !inRange {
+					t.Fatalf("Chunk %d,%d is on screen but culled, view %v %v %v %v", cx, cy, minx, miny, maxx, maxy)
+				}
+				// one chunk of slack on each side from the floor rounding
+				if inRange && (right < minx-chunkPixels || left > maxx+chunkPixels || bottom < miny-chunkPixels || top > maxy+chunkPixels) {
+					t.Fatalf("Chunk %d,%d is far off screen but drawn", cx, cy)
+				}
+			}
+		}
+	}
+}
+
+func TestVisibleChunksOffMap(t *testing.T) {
+	if !VisibleChunks(-500, -500, -10, -10, 256, 0, 4, 4).Empty() {
+		t.Fatal("View left of the map sees chunks")
+	}
+	if !VisibleChunks(2000, 0, 2400, 300, 256, 0, 4, 4).Empty() {
+		t.Fatal("View right of the map sees chunks")
+	}
+}
+
+func BenchmarkVisibleChunks(b *testing.B) {
+	chunksX, chunksY := 64, 64
+	chunkPixels := float64(ChunkSize * config.TileSize)
+	cams := cameraViews(float64(chunksX)*chunkPixels, float64(chunksY)*chunkPixels)
+	b.ResetTimer()
+	drawn := 0
+	for i := 0; i < b.N; i++ {
+		minx, miny, maxx, maxy := cams[i%len(cams)].ViewRect()
+		visible := VisibleChunks(minx, miny, maxx, maxy, chunkPixels, 32, chunksX, chunksY)
+		if !visible.Empty() {
+			drawn += (visible.MaxX - visible.MinX + 1) * (visible.MaxY - visible.MinY + 1)
+		}
+	}
+	b.ReportMetric(float64(drawn)/float64(b.N), "chunks/op")
+}
@@ -0,0 +1,62 @@
+package world
+
+import "math"
+
+const minutesPerDay = 24 * 60
+
+// GameClock is the in game time of day, it drives the ambient light
+type GameClock struct {
+	Minutes        float64 //since midnight
+	MinutesPerTick float64
+}
+
+// ambient colour at an hour of the day, the clock blends between neighbours
+type ambientKey struct {
+	Hour  float64
+	Color [3]float32
+}
+
+var ambientKeys = []ambientKey{
+	{Hour: 0, Color: [3]float32{0.08, 0.1, 0.22}},
+	{Hour: 5, Color: [3]float32{0.08, 0.1, 0.22}},
+	{Hour: 6.5, Color: [3]float32{0.75, 0.6, 0.55}},
+	{Hour: 8, Color: [3]float32{1, 1, 1}},
+	{Hour: 17.5, Color: [3]float32{1, 1, 1}},
+	{Hour: 19, Color: [3]float32{0.85, 0.5, 0.35}},
+	{Hour: 20.5, Color: [3]float32{0.08, 0.1, 0.22}},
+	{Hour: 24, Color: [3]float32{0.08, 0.1, 0.22}},
+}
+
+// NewGameClock starts at the given hour, a day lasts 24 real minutes at 60 TPS
+func NewGameClock(hour float64) *GameClock {
+	return &GameClock{
+		Minutes:        hour * 60,
+		MinutesPerTick: 1.0 / 60,
+	}
+}
+
+func (c *GameClock) Update() {
+	c.Minutes = math.Mod(c.Minutes+c.MinutesPerTick, minutesPerDay)
+}
+
+func (c *GameClock) Hour() float64 {
+	return c.Minutes / 60
+}
+
+// Ambient is the light colour everything gets without a light source nearby
+func (c *GameClock) Ambient() [3]float32 {
+	hour := c.Hour()
+	for i := 1; i < len(ambientKeys); i++ {
+		from, to := ambientKeys[i-1], ambientKeys[i]
+		if hour > to.Hour {
+			continue
+		}
+		t := float32((hour - from.Hour) / (to.Hour - from.Hour))
+		color := [3]float32{}
+		for j := range color {
+			color[j] = from.Color[j] + (to.Color[j]-from.Color[j])*t
+		}
+		return color
+	}
+	return ambientKeys[0].Color
+}
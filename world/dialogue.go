@@ -0,0 +1,117 @@
+package world
+
+import (
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/dialogue"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/quest"
+	"bilydaniel/rpg/utils"
+	"fmt"
+	"math"
+)
+
+// talkReach is how close in tiles a party member has to be to talk
+const talkReach = 1.5
+
+// TalkRequest is a party member walking over to an npc to talk to it
+type TalkRequest struct {
+	PCharacter *entities.PCharacter
+	Npc        *entities.Npc
+}
+
+// Arrived tells if the party member is close enough to start talking
+func (t *TalkRequest) Arrived() bool {
+	dx := t.PCharacter.GetX() - t.Npc.GetX()
+	dy := t.PCharacter.GetY() - t.Npc.GetY()
+	return math.Hypot(dx, dy) <= talkReach
+}
+
+// NpcAt returns the living npc of the current level standing on the world
+// point, only ones the party can see count
+func (w *World) NpcAt(point utils.Point) (*entities.Npc, bool) {
+	level := w.CurrentLevel
+	x := int(math.Floor(point.X / config.TileSize))
+	y := int(math.Floor(point.Y / config.TileSize))
+	if !level.InBounds(x, y) || !level.Fog.IsVisible(x, y) {
+		return nil, false
+	}
+	for _, npc := range w.Npcs {
+		if npc.LevelName != level.Name || !npc.Stats.Alive() {
+			continue
+		}
+		if int(math.Round(npc.GetX())) == x && int(math.Round(npc.GetY())) == y {
+			return npc, true
+		}
+	}
+	return nil, false
+}
+
+// TalkGoal is the free tile next to the npc the party member walks to, false
+// when the npc is boxed in
+func (w *World) TalkGoal(npc *entities.Npc) (utils.Node, bool) {
+	tile := utils.Node{X: int(math.Round(npc.GetX())), Y: int(math.Round(npc.GetY()))}
+	return w.CurrentLevel.freeTileNear(tile, map[utils.Node]bool{tile: true})
+}
+
+// Talk starts the conversation of the npc, the party member is the one giving
+// and receiving items
+func (w *World) Talk(pchar *entities.PCharacter, npc *entities.Npc) (*dialogue.Conversation, error) {
+	d, ok := w.Dialogues[npc.Dialogue]
+	if !ok {
+		return nil, fmt.Errorf("Npc has unknown dialogue %s", npc.Dialogue)
+	}
+	w.Fire(quest.Event{Kind: quest.Talk, Target: npc.Dialogue})
+	return dialogue.Start(d, &dialogueState{world: w, pchar: pchar})
+}
+
+// dialogueState lets conversations read and change the world
+type dialogueState struct {
+	world *World
+	pchar *entities.PCharacter
+}
+
+func (s *dialogueState) Flag(name string) int {
+	return s.world.Journal.Flags[name]
+}
+
+func (s *dialogueState) SetFlag(name string, value int) {
+	s.world.Journal.Flags[name] = value
+}
+
+func (s *dialogueState) ItemCount(item string) int {
+	return s.pchar.Inventory.Count(item)
+}
+
+// GiveItem puts what doesnt fit into the inventory on the ground under the
+// party member
+func (s *dialogueState) GiveItem(item string, count int) error {
+	if _, ok := s.world.Items[item]; !ok {
+		return fmt.Errorf("Dialogue gives unknown item %s", item)
+	}
+	added, _ := s.pchar.Inventory.Add(s.world.Items, item, count)
+	if added > 0 {
+		s.world.Fire(quest.Event{Kind: quest.Collect, Target: item, Count: added})
+	}
+	if added < count {
+		s.world.CurrentLevel.GroundItems = append(s.world.CurrentLevel.GroundItems, GroundItem{
+			Item:  item,
+			Count: count - added,
+			Tile:  utils.Node{X: int(math.Round(s.pchar.GetX())), Y: int(math.Round(s.pchar.GetY()))},
+		})
+	}
+	return nil
+}
+
+func (s *dialogueState) TakeItem(item string, count int) error {
+	if s.pchar.Inventory.Count(item) < count {
+		return fmt.Errorf("%s has less than %d %s", s.pchar.Name, count, item)
+	}
+	s.pchar.Inventory.Remove(item, count)
+	return nil
+}
+
+func (s *dialogueState) StartQuest(id string) error {
+	notices, err := s.world.Journal.Start(id)
+	s.world.Notices = append(s.world.Notices, notices...)
+	return err
+}
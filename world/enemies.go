@@ -0,0 +1,129 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/enemy"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/utils"
+	"math"
+)
+
+// standing still is quieter than walking
+const stillNoise = 0.3
+
+// addEnemy gives a hostile npc a brain, the sight, hearing and leash
+// properties override the defaults and enemies with the same group property
+// alert each other
+func (w *World) addEnemy(id string, npc *entities.Npc, object assets.Object) {
+	perception := enemy.Perception{
+		SightRadius:   config.EnemySightRadius,
+		SightAngle:    config.EnemySightAngle,
+		HearingRadius: config.EnemyHearingRadius,
+	}
+	if value, ok := assets.PropertyFloat(object.Properties, "sight"); ok {
+		perception.SightRadius = value
+	}
+	if value, ok := assets.PropertyFloat(object.Properties, "hearing"); ok {
+		perception.HearingRadius = value
+	}
+	leash := float64(config.EnemyLeash)
+	if value, ok := assets.PropertyFloat(object.Properties, "leash"); ok {
+		leash = value
+	}
+	group, _ := assets.PropertyString(object.Properties, "group")
+	spawn := enemy.Point{X: npc.GetX(), Y: npc.GetY()}
+	w.Enemies.Add(enemy.NewBrain(id, group, spawn, perception, leash))
+}
+
+// levelSenses lets enemies look through the level, buildings block the view
+type levelSenses struct {
+	level *Level
+}
+
+func (s levelSenses) LineOfSight(from enemy.Point, to enemy.Point) bool {
+	a := utils.Point{X: (from.X + 0.5) * config.TileSize, Y: (from.Y + 0.5) * config.TileSize}
+	b := utils.Point{X: (to.X + 0.5) * config.TileSize, Y: (to.Y + 0.5) * config.TileSize}
+	return !s.level.ShapesBlockLine(a, b)
+}
+
+// Chasing tells if the npc is an enemy busy with the party, its behaviour
+// waits until it is guarding again
+func (w *World) Chasing(id string) bool {
+	brain, ok := w.Enemies.Brains[id]
+	return ok && brain.State != enemy.Guarding
+}
+
+// UpdateEnemies runs the enemy brains on the current level and sends them
+// after the party or back home. It returns true when one of them caught up
+// with the party and a fight should start.
+func (w *World) UpdateEnemies(paths *PathSystem, pcharacters []*entities.PCharacter) bool {
+	level := w.CurrentLevel
+	for _, id := range w.Enemies.SortedIDs() {
+		brain := w.Enemies.Brains[id]
+		npc, ok := w.Npcs[id]
+		if !ok {
+			w.Enemies.Remove(id)
+			continue
+		}
+		brain.Asleep = npc.LevelName != level.Name || !npc.Stats.Alive()
+		position := enemy.Point{X: npc.GetX(), Y: npc.GetY()}
+		if brain.State == enemy.Guarding && position != brain.Position {
+			// looks where it walks
+			brain.Facing = math.Atan2(position.Y-brain.Position.Y, position.X-brain.Position.X)
+		}
+		brain.Position = position
+	}
+
+	targets := []enemy.Target{}
+	for _, pchar := range pcharacters {
+		if !pchar.Stats.Alive() {
+			continue
+		}
+		noise := stillNoise
+		if len(pchar.Path) > 0 {
+			noise = 1
+		}
+		targets = append(targets, enemy.Target{ID: pchar.Name, Position: enemy.Point{X: pchar.GetX(), Y: pchar.GetY()}, Noise: noise})
+	}
+
+	orders := w.Enemies.Tick(targets, levelSenses{level: level})
+	for _, order := range orders {
+		npc := w.Npcs[order.ID]
+		start := spriteNode(npc)
+		goal := utils.Node{X: int(math.Round(order.Goal.X)), Y: int(math.Round(order.Goal.Y))}
+		switch order.Kind {
+		case enemy.Chase:
+			free, ok := level.freeTileNear(goal, map[utils.Node]bool{goal: true})
+			if ok {
+				paths.RequestPath(npc, level, start, free, PathAStar)
+			}
+		case enemy.Return:
+			paths.RequestPath(npc, level, start, goal, PathAStar)
+		}
+	}
+
+	if w.engageCooldown > 0 {
+		w.engageCooldown--
+		return false
+	}
+	for _, id := range w.Enemies.SortedIDs() {
+		brain := w.Enemies.Brains[id]
+		if brain.Asleep || brain.State != enemy.Chasing {
+			continue
+		}
+		for _, pchar := range pcharacters {
+			if pchar.Stats.Alive() && tileDistance(spriteNode(w.Npcs[id]), spriteNode(pchar)) <= config.EngageRange {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CalmEnemies sends every enemy home after a fight, for a while they dont
+// start a new one
+func (w *World) CalmEnemies() {
+	w.Enemies.Reset()
+	w.engageCooldown = config.EngageCooldownTicks
+}
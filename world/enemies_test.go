@@ -0,0 +1,20 @@
+package world
+
+import (
+	"bilydaniel/rpg/enemy"
+	"testing"
+)
+
+func TestUpdateEnemiesMissingNpc(t *testing.T) {
+	w, party := testWorld(t)
+	w.Enemies.Add(enemy.NewBrain("gone", "", enemy.Point{}, enemy.Perception{}, 5))
+	paths := NewPathSystem(1)
+	defer paths.Close()
+
+	for i := 0; i < w.Enemies.ThinkTicks*2; i++ {
+		w.UpdateEnemies(paths, party)
+	}
+	if _, ok := w.Enemies.Brains["gone"]; ok {
+		t.Fatal("Brain without an npc is still thinking")
+	}
+}
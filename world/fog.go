@@ -0,0 +1,174 @@
+package world
+
+import (
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/utils"
+	"fmt"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type Visibility int
+
+const (
+	Hidden Visibility = iota
+	Explored
+	Visible
+)
+
+// how dark the fog is over explored and hidden tiles, 0-255
+const (
+	fogExploredAlpha = 150
+	fogHiddenAlpha   = 255
+)
+
+// FogOfWar remembers which tiles of a level the characters have seen and which
+// ones they see right now
+type FogOfWar struct {
+	Width, Height int
+	explored      []bool
+	visible       []bool
+	viewers       []utils.Node //tiles the visibility was computed from
+	image         *ebiten.Image
+	dirty         bool //image has to be rewritten
+}
+
+func NewFogOfWar(width, height int) *FogOfWar {
+	return &FogOfWar{
+		Width:    width,
+		Height:   height,
+		explored: make([]bool, width*height),
+		visible:  make([]bool, width*height),
+		dirty:    true,
+	}
+}
+
+func (f *FogOfWar) At(x, y int) Visibility {
+	if x < 0 || y < 0 || x >= f.Width || y >= f.Height {
+		return Hidden
+	}
+	if f.visible[y*f.Width+x] {
+		return Visible
+	}
+	if f.explored[y*f.Width+x] {
+		return Explored
+	}
+	return Hidden
+}
+
+func (f *FogOfWar) IsVisible(x, y int) bool {
+	return f.At(x, y) == Visible
+}
+
+// UpdateFog recomputes what the characters see, only when one of them moved
+// to another tile
+func (l *Level) UpdateFog(pcharacters []*entities.PCharacter) {
+	viewers := []utils.Node{}
+	for _, pchar := range pcharacters {
+		viewers = append(viewers, utils.Node{X: int(math.Round(pchar.GetX())), Y: int(math.Round(pchar.GetY()))})
+	}
+	if sameNodes(viewers, l.Fog.viewers) {
+		return
+	}
+	l.Fog.viewers = viewers
+
+	for i := range l.Fog.visible {
+		l.Fog.visible[i] = false
+	}
+	for _, viewer := range viewers {
+		l.reveal(viewer, config.SightRadius)
+	}
+	l.Fog.dirty = true
+}
+
+// reveal marks the tiles in the radius the viewer has a clear line to, the line
+// stops at the near edge of the target so the walls of buildings are seen
+func (l *Level) reveal(viewer utils.Node, radius int) {
+	from := utils.Point{X: (float64(viewer.X) + 0.5) * config.TileSize, Y: (float64(viewer.Y) + 0.5) * config.TileSize}
+	for y := max(viewer.Y-radius, 0); y <= min(viewer.Y+radius, l.Height-1); y++ {
+		for x := max(viewer.X-radius, 0); x <= min(viewer.X+radius, l.Width-1); x++ {
+			dx, dy := float64(x-viewer.X), float64(y-viewer.Y)
+			dist := math.Hypot(dx, dy)
+			if dist > float64(radius)+0.5 {
+				continue
+			}
+			if dist > 0 {
+				shorten := (dist - 0.6) / dist
+				to := utils.Point{X: from.X + dx*shorten*config.TileSize, Y: from.Y + dy*shorten*config.TileSize}
+				if l.ShapesBlockLine(from, to) {
+					continue
+				}
+			}
+			l.Fog.visible[y*l.Width+x] = true
+			l.Fog.explored[y*l.Width+x] = true
+		}
+	}
+}
+
+func sameNodes(a, b []utils.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Draw darkens the hidden and explored tiles, one pixel per tile stretched
+// over the level so the edges come out soft
+func (f *FogOfWar) Draw(screen *ebiten.Image, cam *config.Camera) {
+	if f.image == nil {
+		f.image = ebiten.NewImage(f.Width, f.Height)
+	}
+	if f.dirty {
+		pixels := make([]byte, f.Width*f.Height*4)
+		for i := range f.visible {
+			alpha := byte(0)
+			if !f.visible[i] {
+				alpha = fogHiddenAlpha
+				if f.explored[i] {
+					alpha = fogExploredAlpha
+				}
+			}
+			// premultiplied black
+			pixels[i*4+3] = alpha
+		}
+		f.image.WritePixels(pixels)
+		f.dirty = false
+	}
+
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Scale(config.TileSize, config.TileSize)
+	opts.GeoM.Concat(cam.GeoM())
+	opts.Filter = ebiten.FilterLinear
+	screen.DrawImage(f.image, opts)
+}
+
+// ExploredBits packs the explored tiles into bits for saving
+func (f *FogOfWar) ExploredBits() []byte {
+	bits := make([]byte, (len(f.explored)+7)/8)
+	for i, explored := range f.explored {
+		if explored {
+			bits[i/8] |= 1 << (i % 8)
+		}
+	}
+	return bits
+}
+
+func (f *FogOfWar) SetExploredBits(bits []byte) error {
+	if len(bits) != (len(f.explored)+7)/8 {
+		return fmt.Errorf("Explored map has %d bytes, level needs %d", len(bits), (len(f.explored)+7)/8)
+	}
+	for i := range f.explored {
+		f.explored[i] = bits[i/8]&(1<<(i%8)) != 0
+	}
+	// visibility gets recomputed on the next update
+	f.viewers = nil
+	f.dirty = true
+	return nil
+}
@@ -0,0 +1,95 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/save"
+	"testing"
+)
+
+// fogLevel has a wall at x 8 from y 10 to 20 for the characters to hide behind
+func fogLevel() *Level {
+	level := openLevel(40, 30)
+	level.Fog = NewFogOfWar(level.Width, level.Height)
+	level.AddBuilding(assets.Object{ID: 1, X: 8 * config.TileSize, Y: 10 * config.TileSize, Width: config.TileSize, Height: 11 * config.TileSize})
+	return level
+}
+
+func TestFogBlockedByBuildings(t *testing.T) {
+	level := fogLevel()
+	level.UpdateFog([]*entities.PCharacter{testPCharacter(5, 15)})
+
+	cases := []struct {
+		x, y int
+		want Visibility
+	}{
+		{5, 15, Visible},
+		{7, 15, Visible},
+		{8, 15, Visible}, //the wall itself
+		{9, 15, Hidden},
+		{12, 15, Hidden},
+		{5, 7, Visible},
+		{8, 8, Visible}, //past the end of the wall
+		{5, 24, Hidden}, //out of sight range
+	}
+	for _, c := range cases {
+		if got := level.Fog.At(c.x, c.y); got != c.want {
+			t.Fatalf("Tile %d,%d is %d, want %d", c.x, c.y, got, c.want)
+		}
+	}
+}
+
+func TestFogStaysExplored(t *testing.T) {
+	level := fogLevel()
+	pchar := testPCharacter(5, 15)
+	level.UpdateFog([]*entities.PCharacter{pchar})
+
+	pchar.SetPosition(30, 15)
+	level.UpdateFog([]*entities.PCharacter{pchar})
+	if got := level.Fog.At(5, 15); got != Explored {
+		t.Fatalf("Left tile is %d, want explored", got)
+	}
+	if got := level.Fog.At(30, 15); got != Visible {
+		t.Fatalf("Tile of the character is %d", got)
+	}
+	if got := level.Fog.At(12, 15); got != Hidden {
+		t.Fatalf("Tile behind the wall is %d, never seen", got)
+	}
+}
+
+func TestFogSaveRoundTrip(t *testing.T) {
+	level := fogLevel()
+	level.UpdateFog([]*entities.PCharacter{testPCharacter(5, 15), testPCharacter(30, 3)})
+	noRefs := func(entities.Sprite) (string, string, bool) { return "", "", false }
+
+	data, err := save.Marshal(save.File{Version: save.Version, Levels: []save.Level{level.State(noRefs)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := save.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := fogLevel()
+	err = loaded.RestoreState(file.Levels[0], func(string, string) entities.Sprite { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < level.Height; y++ {
+		for x := 0; x < level.Width; x++ {
+			// nobody looks yet, everything seen before is explored
+			want := level.Fog.At(x, y)
+			if want == Visible {
+				want = Explored
+			}
+			if got := loaded.Fog.At(x, y); got != want {
+				t.Fatalf("Tile %d,%d is %d after loading, want %d", x, y, got, want)
+			}
+		}
+	}
+
+	if err := loaded.Fog.SetExploredBits(make([]byte, 3)); err == nil {
+		t.Fatal("Explored map of another size accepted")
+	}
+}
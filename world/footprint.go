@@ -0,0 +1,291 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/utils"
+	"math"
+	"sort"
+)
+
+// segments used when a rotated ellipse has to become a polygon
+const ellipseSegments = 16
+
+// LevelTileset is a tileset used by the level with its first global tile ID
+type LevelTileset struct {
+	Firstgid int
+	Source   string
+	Data     *assets.TilesetData
+}
+
+func (l *Level) addTileset(tileset LevelTileset) {
+	l.Tilesets = append(l.Tilesets, tileset)
+	sort.Slice(l.Tilesets, func(i, j int) bool {
+		return l.Tilesets[i].Firstgid < l.Tilesets[j].Firstgid
+	})
+}
+
+// TilesetForGID returns the tileset the global tile ID belongs to and the local
+// tile ID inside of it
+func (l *Level) TilesetForGID(gid int) (*LevelTileset, int) {
+	for i := len(l.Tilesets) - 1; i >= 0; i-- {
+		if gid >= l.Tilesets[i].Firstgid {
+			return &l.Tilesets[i], gid - l.Tilesets[i].Firstgid
+		}
+	}
+	return nil, 0
+}
+
+// TileDataForGID returns the per tile data of image collection tilesets or of
+// tiles with properties, nil if the tileset has nothing about the tile
+func (l *Level) TileDataForGID(gid int) *assets.TileData {
+	tileset, id := l.TilesetForGID(gid)
+	if tileset == nil {
+		return nil
+	}
+	return tileset.Data.Tile(id)
+}
+
+// ObjectFootprint returns the collision shapes of the object in world pixels.
+// Tile objects use the collision shapes drawn in the tileset and fall back to
+// their whole image, other objects are their own shape.
+func (l *Level) ObjectFootprint(object assets.Object) []utils.CollisionShape {
+	gid, flags := assets.SplitGID(object.GID)
+	if gid == 0 {
+		// the transform already places and rotates it, the shape stays local
+		local := object
+		local.X, local.Y, local.Rotation = 0, 0, 0
+		return objectShapes(local, objectTransform(object, 1, 1, 0, 0, 0))
+	}
+
+	scalex, scaley := 1.0, 1.0
+	imagew, imageh := object.Width, object.Height
+	tile := l.TileDataForGID(gid)
+	if tile != nil && tile.ImageWidth > 0 && tile.ImageHeight > 0 {
+		imagew, imageh = float64(tile.ImageWidth), float64(tile.ImageHeight)
+		scalex = object.Width / imagew
+		scaley = object.Height / imageh
+	}
+	// flipped tiles get flipped shapes
+	flipw, fliph := 0.0, 0.0
+	if flags&assets.FlipHorizontal != 0 {
+		flipw = imagew
+	}
+	if flags&assets.FlipVertical != 0 {
+		fliph = imageh
+	}
+	// tile objects are anchored at the bottom left corner
+	transform := objectTransform(object, scalex, scaley, -object.Height, flipw, fliph)
+
+	if tile == nil || tile.ObjectGroup == nil || len(tile.ObjectGroup.Objects) == 0 {
+		whole := assets.Object{Width: object.Width / scalex, Height: object.Height / scaley}
+		return objectShapes(whole, transform)
+	}
+
+	shapes := []utils.CollisionShape{}
+	for _, shape := range tile.ObjectGroup.Objects {
+		shapes = append(shapes, objectShapes(shape, transform)...)
+	}
+	return shapes
+}
+
+type shapeTransform struct {
+	apply   func(x, y float64) utils.Point
+	rotated bool
+	scalex  float64
+	scaley  float64
+}
+
+// objectTransform maps object local pixels (mirrored when flipw or fliph is
+// the image size, scaled, moved by offsety and rotated around the object
+// anchor) to world pixels
+func objectTransform(object assets.Object, scalex, scaley, offsety, flipw, fliph float64) shapeTransform {
+	angle := object.Rotation * math.Pi / 180
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	return shapeTransform{
+		apply: func(x, y float64) utils.Point {
+			if flipw != 0 {
+				x = flipw - x
+			}
+			if fliph != 0 {
+				y = fliph - y
+			}
+			x *= scalex
+			y = y*scaley + offsety
+			return utils.Point{
+				X: object.X + x*cos - y*sin,
+				Y: object.Y + x*sin + y*cos,
+			}
+		},
+		rotated: object.Rotation != 0,
+		scalex:  scalex,
+		scaley:  scaley,
+	}
+}
+
+// objectShapes turns one Tiled shape (in its parents local pixels) into
+// collision shapes
+func objectShapes(shape assets.Object, transform shapeTransform) []utils.CollisionShape {
+	if shape.Point || len(shape.Polyline) > 0 {
+		return nil
+	}
+
+	local := []utils.Point{}
+	if len(shape.Polygon) > 0 {
+		for _, p := range shape.Polygon {
+			local = append(local, utils.Point{X: shape.X + p.X, Y: shape.Y + p.Y})
+		}
+	} else if shape.Ellipse {
+		ellipse := utils.EllipseCollision{
+			Cx: shape.X + shape.Width/2,
+			Cy: shape.Y + shape.Height/2,
+			Rx: shape.Width / 2,
+			Ry: shape.Height / 2,
+		}
+		if !transform.rotated && shape.Rotation == 0 {
+			center := transform.apply(ellipse.Cx, ellipse.Cy)
+			return []utils.CollisionShape{&utils.EllipseCollision{
+				Cx: center.X,
+				Cy: center.Y,
+				Rx: ellipse.Rx * transform.scalex,
+				Ry: ellipse.Ry * transform.scaley,
+			}}
+		}
+		local = ellipse.Polygon(ellipseSegments)
+	} else {
+		if shape.Width <= 0 || shape.Height <= 0 {
+			return nil
+		}
+		local = []utils.Point{
+			{X: shape.X, Y: shape.Y},
+			{X: shape.X + shape.Width, Y: shape.Y},
+			{X: shape.X + shape.Width, Y: shape.Y + shape.Height},
+			{X: shape.X, Y: shape.Y + shape.Height},
+		}
+	}
+
+	if shape.Rotation != 0 {
+		// shapes inside of a tile can be rotated on their own too
+		angle := shape.Rotation * math.Pi / 180
+		sin, cos := math.Sin(angle), math.Cos(angle)
+		for i, p := range local {
+			dx, dy := p.X-shape.X, p.Y-shape.Y
+			local[i] = utils.Point{X: shape.X + dx*cos - dy*sin, Y: shape.Y + dx*sin + dy*cos}
+		}
+	}
+
+	points := make([]utils.Point, len(local))
+	for i, p := range local {
+		points[i] = transform.apply(p.X, p.Y)
+	}
+
+	if len(shape.Polygon) == 0 && !shape.Ellipse && !transform.rotated && shape.Rotation == 0 {
+		rect := (&utils.PolygonCollision{Points: points}).Bounds()
+		return []utils.CollisionShape{&rect}
+	}
+	return []utils.CollisionShape{&utils.PolygonCollision{Points: points}}
+}
+
+// shapeTiles calls visit for every tile the shape covers
+func (l *Level) shapeTiles(shape utils.CollisionShape, visit func(x, y int)) {
+	bounds := shape.Bounds()
+	minx := max(int(math.Floor(bounds.Minx/config.TileSize)), 0)
+	miny := max(int(math.Floor(bounds.Miny/config.TileSize)), 0)
+	maxx := min(int(math.Floor(bounds.Maxx/config.TileSize)), l.Width-1)
+	maxy := min(int(math.Floor(bounds.Maxy/config.TileSize)), l.Height-1)
+
+	for y := miny; y <= maxy; y++ {
+		for x := minx; x <= maxx; x++ {
+			rect := utils.RectangleCollision{
+				Minx: float64(x * config.TileSize),
+				Miny: float64(y * config.TileSize),
+				Maxx: float64((x + 1) * config.TileSize),
+				Maxy: float64((y + 1) * config.TileSize),
+			}
+			if utils.ShapeCoversRect(shape, rect) {
+				visit(x, y)
+			}
+		}
+	}
+}
+
+// AddBuilding places a building object and blocks the tiles under its footprint
+func (l *Level) AddBuilding(object assets.Object) {
+	shapes := l.ObjectFootprint(object)
+	l.Obstacles["buildings"] = append(l.Obstacles["buildings"], object)
+	l.Footprints[object.ID] = shapes
+
+	for _, shape := range shapes {
+		l.shapeTiles(shape, func(x, y int) {
+			l.SetWalkable(x, y, false)
+		})
+	}
+}
+
+// RemoveBuilding takes the building away and gives the tiles back their terrain
+// walkability, unless another building still covers them
+func (l *Level) RemoveBuilding(id int) {
+	shapes, ok := l.Footprints[id]
+	if !ok {
+		return
+	}
+	delete(l.Footprints, id)
+
+	buildings := l.Obstacles["buildings"]
+	for i, building := range buildings {
+		if building.ID == id {
+			l.Obstacles["buildings"] = append(buildings[:i], buildings[i+1:]...)
+			break
+		}
+	}
+
+	covered := map[utils.Node]bool{}
+	for _, others := range l.Footprints {
+		for _, shape := range others {
+			l.shapeTiles(shape, func(x, y int) {
+				covered[utils.Node{X: x, Y: y}] = true
+			})
+		}
+	}
+
+	for _, shape := range shapes {
+		l.shapeTiles(shape, func(x, y int) {
+			if covered[utils.Node{X: x, Y: y}] {
+				return
+			}
+			terrain, ok := l.Terrains[l.Grid[y][x].ID]
+			if !ok {
+				terrain = DefaultTerrainInfo()
+			}
+			l.SetWalkable(x, y, terrain.Walkable)
+		})
+	}
+}
+
+// CollisionShapes returns the footprints of all buildings
+func (l *Level) CollisionShapes() []utils.CollisionShape {
+	ids := []int{}
+	for id := range l.Footprints {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	shapes := []utils.CollisionShape{}
+	for _, id := range ids {
+		shapes = append(shapes, l.Footprints[id]...)
+	}
+	return shapes
+}
+
+// ShapesBlockLine tells if any building footprint is in the way between two
+// points in world pixels
+func (l *Level) ShapesBlockLine(a utils.Point, b utils.Point) bool {
+	for _, shapes := range l.Footprints {
+		for _, shape := range shapes {
+			if shape.IntersectsLine(a, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
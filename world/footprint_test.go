@@ -0,0 +1,121 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/utils"
+	"math"
+	"testing"
+)
+
+// footprintBounds is the box around all shapes of the object
+func footprintBounds(t *testing.T, shapes []utils.CollisionShape) utils.RectangleCollision {
+	t.Helper()
+	if len(shapes) == 0 {
+		t.Fatal("No shapes")
+	}
+	bounds := shapes[0].Bounds()
+	for _, shape := range shapes[1:] {
+		b := shape.Bounds()
+		bounds.Minx, bounds.Miny = min(bounds.Minx, b.Minx), min(bounds.Miny, b.Miny)
+		bounds.Maxx, bounds.Maxy = max(bounds.Maxx, b.Maxx), max(bounds.Maxy, b.Maxy)
+	}
+	return bounds
+}
+
+func sameBounds(a, b utils.RectangleCollision) bool {
+	near := func(x, y float64) bool { return math.Abs(x-y) < 0.001 }
+	return near(a.Minx, b.Minx) && near(a.Miny, b.Miny) && near(a.Maxx, b.Maxx) && near(a.Maxy, b.Maxy)
+}
+
+func TestPlainObjectFootprint(t *testing.T) {
+	level := openLevel(10, 10)
+	cases := []struct {
+		name   string
+		object assets.Object
+		want   utils.RectangleCollision
+	}{
+		{
+			name:   "rectangle",
+			object: assets.Object{X: 32, Y: 48, Width: 16, Height: 16},
+			want:   utils.RectangleCollision{Minx: 32, Miny: 48, Maxx: 48, Maxy: 64},
+		},
+		{
+			name:   "polygon",
+			object: assets.Object{X: 32, Y: 48, Polygon: []assets.ObjectPoint{{X: 0, Y: 0}, {X: 32, Y: 0}, {X: 0, Y: 32}}},
+			want:   utils.RectangleCollision{Minx: 32, Miny: 48, Maxx: 64, Maxy: 80},
+		},
+		{
+			name:   "ellipse",
+			object: assets.Object{X: 32, Y: 48, Width: 32, Height: 16, Ellipse: true},
+			want:   utils.RectangleCollision{Minx: 32, Miny: 48, Maxx: 64, Maxy: 64},
+		},
+		{
+			// rotates clockwise around its top left corner
+			name:   "rotated rectangle",
+			object: assets.Object{X: 32, Y: 32, Width: 32, Height: 16, Rotation: 90},
+			want:   utils.RectangleCollision{Minx: 16, Miny: 32, Maxx: 32, Maxy: 64},
+		},
+		{
+			name:   "rotated polygon",
+			object: assets.Object{X: 32, Y: 32, Rotation: 180, Polygon: []assets.ObjectPoint{{X: 0, Y: 0}, {X: 16, Y: 0}, {X: 16, Y: 8}}},
+			want:   utils.RectangleCollision{Minx: 16, Miny: 24, Maxx: 32, Maxy: 32},
+		},
+	}
+	for _, c := range cases {
+		got := footprintBounds(t, level.ObjectFootprint(c.object))
+		if !sameBounds(got, c.want) {
+			t.Fatalf("%s: %+v, want %+v", c.name, got, c.want)
+		}
+	}
+
+	// the rectangle blocks exactly its tile
+	level.AddBuilding(assets.Object{ID: 1, X: 32, Y: 48, Width: 16, Height: 16})
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			if blocked := !level.Grid[y][x].Walkable; blocked != (x == 2 && y == 3) {
+				t.Fatalf("Tile %d,%d blocked %v", x, y, blocked)
+			}
+		}
+	}
+}
+
+// tileLevel has one tileset, tile 1 is a 16x16 image with a collision shape
+// over its bottom left quarter, tile 2 has no shapes
+func tileLevel() *Level {
+	level := openLevel(10, 10)
+	level.addTileset(LevelTileset{Firstgid: 1, Data: &assets.TilesetData{Tiles: []assets.TileData{
+		{ID: 0, ImageWidth: 16, ImageHeight: 16, ObjectGroup: &assets.ObjectGroup{Objects: []assets.Object{
+			{X: 0, Y: 8, Width: 8, Height: 8},
+		}}},
+		{ID: 1, ImageWidth: 16, ImageHeight: 16},
+	}}})
+	return level
+}
+
+func TestTileObjectFootprint(t *testing.T) {
+	level := tileLevel()
+	// drawn twice as large, the bottom left corner is at 32,64
+	object := func(gid uint32, rotation float64) assets.Object {
+		return assets.Object{GID: gid, X: 32, Y: 64, Width: 32, Height: 32, Rotation: rotation}
+	}
+	cases := []struct {
+		name   string
+		object assets.Object
+		want   utils.RectangleCollision
+	}{
+		{"shape", object(1, 0), utils.RectangleCollision{Minx: 32, Miny: 48, Maxx: 48, Maxy: 64}},
+		{"flipped horizontally", object(1|assets.FlipHorizontal, 0), utils.RectangleCollision{Minx: 48, Miny: 48, Maxx: 64, Maxy: 64}},
+		{"flipped vertically", object(1|assets.FlipVertical, 0), utils.RectangleCollision{Minx: 32, Miny: 32, Maxx: 48, Maxy: 48}},
+		{"flipped both", object(1|assets.FlipHorizontal|assets.FlipVertical, 0), utils.RectangleCollision{Minx: 48, Miny: 32, Maxx: 64, Maxy: 48}},
+		{"whole image", object(2, 0), utils.RectangleCollision{Minx: 32, Miny: 32, Maxx: 64, Maxy: 64}},
+		// rotates clockwise around the bottom left corner
+		{"rotated image", object(2, 90), utils.RectangleCollision{Minx: 32, Miny: 64, Maxx: 64, Maxy: 96}},
+		{"rotated shape", object(1, 90), utils.RectangleCollision{Minx: 32, Miny: 64, Maxx: 48, Maxy: 80}},
+	}
+	for _, c := range cases {
+		got := footprintBounds(t, level.ObjectFootprint(c.object))
+		if !sameBounds(got, c.want) {
+			t.Fatalf("%s: %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
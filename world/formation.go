@@ -0,0 +1,202 @@
+package world
+
+import (
+	"bilydaniel/rpg/utils"
+	"math"
+	"sort"
+)
+
+type Formation int
+
+const (
+	FormationLine Formation = iota
+	FormationWedge
+	FormationBox
+	FormationCircle
+	formationCount
+)
+
+// how far the snapping looks for a free tile around a slot
+const formationSearchRadius = 6
+
+func (f Formation) Next() Formation {
+	return (f + 1) % formationCount
+}
+
+func (f Formation) String() string {
+	switch f {
+	case FormationLine:
+		return "line"
+	case FormationWedge:
+		return "wedge"
+	case FormationBox:
+		return "box"
+	case FormationCircle:
+		return "circle"
+	}
+	return "unknown"
+}
+
+// Offsets returns n slots relative to the formation center, X is to the right
+// and Y is forward (towards the facing direction), in tiles
+func (f Formation) Offsets(n int) []utils.Point {
+	offsets := make([]utils.Point, n)
+	switch f {
+	case FormationLine:
+		for i := 0; i < n; i++ {
+			offsets[i] = utils.Point{X: float64(i) - float64(n-1)/2, Y: 0}
+		}
+	case FormationWedge:
+		// leader at the tip, then pairs behind spreading to the sides
+		for i := 0; i < n; i++ {
+			row := (i + 1) / 2
+			side := float64(row)
+			if i%2 == 1 {
+				side = -side
+			}
+			offsets[i] = utils.Point{X: side, Y: -float64(row)}
+		}
+	case FormationBox:
+		cols := int(math.Ceil(math.Sqrt(float64(n))))
+		rows := (n + cols - 1) / cols
+		for i := 0; i < n; i++ {
+			row := i / cols
+			col := i % cols
+			offsets[i] = utils.Point{X: float64(col) - float64(cols-1)/2, Y: float64(rows-1)/2 - float64(row)}
+		}
+	case FormationCircle:
+		if n == 1 {
+			break
+		}
+		radius := math.Max(1, float64(n)/(2*math.Pi)*1.5)
+		for i := 0; i < n; i++ {
+			angle := 2 * math.Pi * float64(i) / float64(n)
+			offsets[i] = utils.Point{X: radius * math.Sin(angle), Y: radius * math.Cos(angle)}
+		}
+	}
+	return offsets
+}
+
+// FormationGoals picks a distinct walkable and unoccupied goal tile for every
+// member around the center, the formation faces from the members towards the
+// center and members keep their relative positions when possible
+func (l *Level) FormationGoals(formation Formation, center utils.Node, members []utils.Point) []utils.Node {
+	n := len(members)
+	if n == 0 {
+		return nil
+	}
+
+	centroid := utils.Point{}
+	for _, member := range members {
+		centroid.X += member.X / float64(n)
+		centroid.Y += member.Y / float64(n)
+	}
+
+	// facing is the forward axis, right is the facing rotated clockwise
+	forward := utils.Point{X: float64(center.X) - centroid.X, Y: float64(center.Y) - centroid.Y}
+	length := math.Hypot(forward.X, forward.Y)
+	if length < 0.001 {
+		forward = utils.Point{X: 0, Y: -1}
+	} else {
+		forward = utils.Point{X: forward.X / length, Y: forward.Y / length}
+	}
+	right := utils.Point{X: -forward.Y, Y: forward.X}
+
+	slots := []utils.Point{}
+	for _, offset := range formation.Offsets(n) {
+		slots = append(slots, utils.Point{
+			X: float64(center.X) + right.X*offset.X + forward.X*offset.Y,
+			Y: float64(center.Y) + right.Y*offset.X + forward.Y*offset.Y,
+		})
+	}
+
+	assignment := assignSlots(members, centroid, slots, center)
+
+	taken := map[utils.Node]bool{}
+	goals := make([]utils.Node, n)
+	for i := range members {
+		slot := slots[assignment[i]]
+		goal := utils.Node{X: int(math.Round(slot.X)), Y: int(math.Round(slot.Y))}
+		free, ok := l.freeTileNear(goal, taken)
+		if !ok {
+			// nowhere to go, the member stays where it is
+			free = utils.Node{X: int(math.Round(members[i].X)), Y: int(math.Round(members[i].Y))}
+		}
+		goals[i] = free
+		taken[goals[i]] = true
+	}
+	return goals
+}
+
+// assignSlots greedily matches the members relative positions to the slots
+// relative positions, returns member index => slot index
+func assignSlots(members []utils.Point, centroid utils.Point, slots []utils.Point, center utils.Node) []int {
+	type pair struct {
+		member, slot int
+		dist         float64
+	}
+	pairs := []pair{}
+	for i, member := range members {
+		for j, slot := range slots {
+			dx := (member.X - centroid.X) - (slot.X - float64(center.X))
+			dy := (member.Y - centroid.Y) - (slot.Y - float64(center.Y))
+			pairs = append(pairs, pair{member: i, slot: j, dist: dx*dx + dy*dy})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].dist < pairs[j].dist
+	})
+
+	assignment := make([]int, len(members))
+	memberDone := make([]bool, len(members))
+	slotDone := make([]bool, len(slots))
+	for _, p := range pairs {
+		if memberDone[p.member] || slotDone[p.slot] {
+			continue
+		}
+		assignment[p.member] = p.slot
+		memberDone[p.member] = true
+		slotDone[p.slot] = true
+	}
+	return assignment
+}
+
+// freeTileNear searches rings around the node, false when there is no free
+// tile close enough
+func (l *Level) freeTileNear(node utils.Node, taken map[utils.Node]bool) (utils.Node, bool) {
+	free := func(x, y int) bool {
+		candidate := utils.Node{X: x, Y: y}
+		return l.InBounds(x, y) && l.Grid[y][x].Walkable && !l.OccupiedTile(&candidate) && !taken[candidate]
+	}
+	if free(node.X, node.Y) {
+		return node, true
+	}
+
+	for radius := 1; radius <= formationSearchRadius; radius++ {
+		best := utils.Node{}
+		bestDist := math.MaxFloat64
+		for dy := -radius; dy <= radius; dy++ {
+			for dx := -radius; dx <= radius; dx++ {
+				if max(abs(dx), abs(dy)) != radius || !free(node.X+dx, node.Y+dy) {
+					continue
+				}
+				dist := math.Hypot(float64(dx), float64(dy))
+				if dist < bestDist {
+					bestDist = dist
+					best = utils.Node{X: node.X + dx, Y: node.Y + dy}
+				}
+			}
+		}
+		if bestDist != math.MaxFloat64 {
+			return best, true
+		}
+	}
+	return utils.Node{}, false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,78 @@
+package world
+
+import (
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/utils"
+	"testing"
+)
+
+// openLevel is a walkable level without a map file
+func openLevel(width, height int) *Level {
+	level := InitLevel()
+	level.Width = width
+	level.Height = height
+	level.Grid = make([][]*Tile, height)
+	level.Occupancy = make([][]entities.Sprite, height)
+	for y := 0; y < height; y++ {
+		level.Grid[y] = make([]*Tile, width)
+		level.Occupancy[y] = make([]entities.Sprite, width)
+		for x := 0; x < width; x++ {
+			level.Grid[y][x] = &Tile{Node: utils.Node{X: x, Y: y}, Walkable: true, Cost: 1}
+		}
+	}
+	return &level
+}
+
+func TestFormationGoalsDistinct(t *testing.T) {
+	level := openLevel(20, 20)
+	members := []utils.Point{{X: 2, Y: 2}, {X: 3, Y: 2}, {X: 4, Y: 2}, {X: 5, Y: 2}}
+	for formation := FormationLine; formation < formationCount; formation++ {
+		goals := level.FormationGoals(formation, utils.Node{X: 10, Y: 10}, members)
+		seen := map[utils.Node]bool{}
+		for _, goal := range goals {
+			if seen[goal] {
+				t.Fatalf("%s gives %v twice", formation, goal)
+			}
+			seen[goal] = true
+		}
+	}
+}
+
+func TestFormationGoalsNoFreeTile(t *testing.T) {
+	// a single free tile, the second member has nowhere to go
+	level := openLevel(3, 1)
+	level.Grid[0][2].Walkable = false
+	members := []utils.Point{{X: 0, Y: 0}, {X: 1, Y: 0}}
+	level.SetTileOccupied(&entities.CircleSprite{X: 1}, 1, 0)
+
+	goals := level.FormationGoals(FormationLine, utils.Node{X: 0, Y: 0}, members)
+	if goals[0] == goals[1] {
+		t.Fatalf("Both members got %v", goals[0])
+	}
+	for i, goal := range goals {
+		if goal != (utils.Node{X: 0, Y: 0}) && goal != (utils.Node{X: int(members[i].X), Y: int(members[i].Y)}) {
+			t.Fatalf("Member %d got %v", i, goal)
+		}
+	}
+}
+
+func TestFreeTileNear(t *testing.T) {
+	level := openLevel(5, 5)
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 5; x++ {
+			level.SetTileOccupied(&entities.CircleSprite{}, x, y)
+		}
+	}
+	if node, ok := level.freeTileNear(utils.Node{X: 2, Y: 2}, map[utils.Node]bool{}); ok {
+		t.Fatalf("Full level has free tile %v", node)
+	}
+
+	level.SetTileOccupied(nil, 4, 1)
+	node, ok := level.freeTileNear(utils.Node{X: 2, Y: 2}, map[utils.Node]bool{})
+	if !ok || node != (utils.Node{X: 4, Y: 1}) {
+		t.Fatalf("Got %v %v, want the only free tile", node, ok)
+	}
+	if _, ok := level.freeTileNear(utils.Node{X: 2, Y: 2}, map[utils.Node]bool{node: true}); ok {
+		t.Fatal("Taken tile counts as free")
+	}
+}
@@ -0,0 +1,118 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/items"
+	"bilydaniel/rpg/utils"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// ItemsLayer is the Tiled object layer the items lying around are read from
+const ItemsLayer = "items"
+
+// GroundItem is a stack lying on a tile, ID is the Tiled object id
+type GroundItem struct {
+	ID    int
+	Item  string
+	Count int
+	Tile  utils.Node
+}
+
+// GroundItemFromObject reads the item and count properties of the object, it
+// lies on the tile under the middle of the object
+func GroundItemFromObject(object assets.Object) (GroundItem, error) {
+	item, ok := assets.PropertyString(object.Properties, "item")
+	if !ok {
+		return GroundItem{}, fmt.Errorf("Item object %d has no item property", object.ID)
+	}
+	count := 1
+	if _, ok := assets.FindProperty(object.Properties, "count"); ok {
+		value, ok := assets.PropertyInt(object.Properties, "count")
+		if !ok || value < 1 {
+			return GroundItem{}, fmt.Errorf("Item object %d needs a positive int count", object.ID)
+		}
+		count = value
+	}
+	x, y := object.X+object.Width/2, object.Y+object.Height/2
+	if object.GID != 0 {
+		// tile objects hang up from their bottom left corner
+		y = object.Y - object.Height/2
+	}
+	return GroundItem{
+		ID:    object.ID,
+		Item:  item,
+		Count: count,
+		Tile:  utils.Node{X: int(math.Floor(x / config.TileSize)), Y: int(math.Floor(y / config.TileSize))},
+	}, nil
+}
+
+// PickUpItems puts what lies under the character into its inventory, what
+// doesnt fit stays on the ground. It returns what was picked up.
+func (l *Level) PickUpItems(pchar *entities.PCharacter, catalog items.Catalog) []items.Stack {
+	picked := []items.Stack{}
+	tile := utils.Node{X: int(math.Round(pchar.GetX())), Y: int(math.Round(pchar.GetY()))}
+	remaining := l.GroundItems[:0]
+	for _, ground := range l.GroundItems {
+		if ground.Tile == tile {
+			added, err := pchar.Inventory.Add(catalog, ground.Item, ground.Count)
+			if err != nil {
+				log.Printf("Picking up %s: %v", ground.Item, err)
+			}
+			ground.Count -= added
+			if added > 0 {
+				picked = append(picked, items.Stack{Item: ground.Item, Count: added})
+			}
+		}
+		if ground.Count > 0 {
+			remaining = append(remaining, ground)
+		}
+	}
+	l.GroundItems = remaining
+	return picked
+}
+
+// SubmitGroundItems puts the items the party has seen the tiles of into the
+// render queue
+func (l *Level) SubmitGroundItems(queue *RenderQueue, cam *config.Camera, catalog items.Catalog) {
+	for _, ground := range l.GroundItems {
+		if l.Fog.At(ground.Tile.X, ground.Tile.Y) == Hidden {
+			continue
+		}
+		clr := color.RGBA{255, 255, 255, 255}
+		if rgb, err := parseColor(catalog[ground.Item].Color); err == nil {
+			clr = color.RGBA{uint8(rgb[0] * 255), uint8(rgb[1] * 255), uint8(rgb[2] * 255), 255}
+		}
+		// TODO item icons, a diamond for now
+		centerx := (float64(ground.Tile.X) + 0.5) * config.TileSize
+		centery := (float64(ground.Tile.Y) + 0.5) * config.TileSize
+		queue.Submit((float64(ground.Tile.Y)+0.5)*config.TileSize, func(target *ebiten.Image) {
+			path := vector.Path{}
+			for i, corner := range [][2]float64{{0, -4}, {4, 0}, {0, 4}, {-4, 0}} {
+				x, y := cam.WorldToScreen(centerx+corner[0], centery+corner[1])
+				if i == 0 {
+					path.MoveTo(float32(x), float32(y))
+				} else {
+					path.LineTo(float32(x), float32(y))
+				}
+			}
+			path.Close()
+			vertices, indices := path.AppendVerticesAndIndicesForFilling(nil, nil)
+			for i := range vertices {
+				vertices[i].SrcX, vertices[i].SrcY = 0.5, 0.5
+				vertices[i].ColorR = float32(clr.R) / 255
+				vertices[i].ColorG = float32(clr.G) / 255
+				vertices[i].ColorB = float32(clr.B) / 255
+				vertices[i].ColorA = 1
+			}
+			target.DrawTriangles(vertices, indices, whitePixel, &ebiten.DrawTrianglesOptions{AntiAlias: true})
+		})
+	}
+}
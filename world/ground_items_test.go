@@ -0,0 +1,87 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/items"
+	"bilydaniel/rpg/utils"
+	"reflect"
+	"testing"
+)
+
+var groundCatalog = items.Catalog{
+	"potion": {ID: "potion", Name: "Potion", Weight: 1, MaxStack: 10},
+	"arrow":  {ID: "arrow", Name: "Arrow", MaxStack: 50},
+}
+
+func TestPickUpItems(t *testing.T) {
+	level := openLevel(5, 5)
+	level.GroundItems = []GroundItem{
+		{ID: 1, Item: "potion", Count: 8, Tile: utils.Node{X: 2, Y: 2}},
+		{ID: 2, Item: "arrow", Count: 30, Tile: utils.Node{X: 3, Y: 2}},
+		{ID: 3, Item: "arrow", Count: 20, Tile: utils.Node{X: 2, Y: 2}},
+		{ID: 4, Item: "missing", Count: 1, Tile: utils.Node{X: 2, Y: 2}},
+	}
+	pchar := testPCharacter(2.2, 1.8)
+	pchar.Inventory = items.NewInventory(5, 5)
+
+	picked := level.PickUpItems(pchar, groundCatalog)
+	want := []items.Stack{{Item: "potion", Count: 5}, {Item: "arrow", Count: 20}}
+	if !reflect.DeepEqual(picked, want) {
+		t.Fatalf("Picked %v, want %v", picked, want)
+	}
+	// the potions that were too heavy stay, so does the unknown item
+	left := []GroundItem{
+		{ID: 1, Item: "potion", Count: 3, Tile: utils.Node{X: 2, Y: 2}},
+		{ID: 2, Item: "arrow", Count: 30, Tile: utils.Node{X: 3, Y: 2}},
+		{ID: 4, Item: "missing", Count: 1, Tile: utils.Node{X: 2, Y: 2}},
+	}
+	if !reflect.DeepEqual(level.GroundItems, left) {
+		t.Fatalf("Left %v, want %v", level.GroundItems, left)
+	}
+
+	if picked := level.PickUpItems(pchar, groundCatalog); len(picked) != 0 {
+		t.Fatalf("Picked %v with a full inventory", picked)
+	}
+}
+
+func TestGroundItemFromObject(t *testing.T) {
+	object := assets.Object{ID: 7, X: 40, Y: 20, Point: true, Properties: []assets.Property{
+		{Name: "item", Type: "string", Value: "arrow"},
+		{Name: "count", Type: "int", Value: 12.0},
+	}}
+	ground, err := GroundItemFromObject(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ground != (GroundItem{ID: 7, Item: "arrow", Count: 12, Tile: utils.Node{X: 2, Y: 1}}) {
+		t.Fatalf("Got %+v", ground)
+	}
+
+	object.Properties = object.Properties[:1]
+	if ground, _ := GroundItemFromObject(object); ground.Count != 1 {
+		t.Fatalf("Count %d without the property", ground.Count)
+	}
+
+	for _, count := range []assets.Property{
+		{Name: "count", Type: "float", Value: 2.5},
+		{Name: "count", Type: "int", Value: 0.0},
+		{Name: "count", Type: "string", Value: "3"},
+	} {
+		object.Properties = []assets.Property{object.Properties[0], count}
+		if _, err := GroundItemFromObject(object); err == nil {
+			t.Fatalf("Count %v accepted", count)
+		}
+	}
+}
+
+func TestLevelItemCounts(t *testing.T) {
+	level := loadLevel(t, "level_1")
+	if len(level.GroundItems) == 0 {
+		t.Fatal("No items on level_1")
+	}
+	for _, ground := range level.GroundItems {
+		if ground.Count < 1 {
+			t.Fatalf("Item %d has count %d", ground.ID, ground.Count)
+		}
+	}
+}
@@ -0,0 +1,493 @@
+package world
+
+import (
+	"bilydaniel/rpg/utils"
+	"container/heap"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+)
+
+const (
+	ClusterSize = 16
+	// entrances longer than this get one node at each end instead of one in
+	// the middle
+	entranceSplit = 6
+	// requests with the ends further apart than this go through the clusters
+	hierarchyDistance = 2 * ClusterSize
+)
+
+// HierarchicalPathFinder is an HPA* abstraction of a NavGrid, the map is split
+// into clusters, the entrances between them make up a small graph that gets
+// searched first and refined into tiles only along the found route.
+// It doesnt change once built so the path workers share it, a newer snapshot
+// gets its own one from derive.
+type HierarchicalPathFinder struct {
+	PathFinder  *PathFinder
+	ClusterSize int
+
+	grid    *NavGrid
+	cols    int
+	rows    int
+	nodes   map[utils.Node]*abstractNode
+	borders map[borderKey][][2]utils.Node //entrance pairs, first tile is in cluster A
+}
+
+// borderKey is the border between two neighboring clusters, A < B
+type borderKey struct {
+	A, B int
+}
+
+type abstractNode struct {
+	Node    utils.Node
+	Cluster int
+	Edges   []*abstractEdge
+	refs    int //number of entrance pairs using the node
+}
+
+type abstractEdge struct {
+	To   utils.Node
+	Cost float64
+	// path is the walking order from the node to To without the node itself,
+	// searched the first time the edge gets used. Edges of clean clusters are
+	// shared with derived finders, the cluster tiles are the same there.
+	path []utils.Node
+	once sync.Once
+}
+
+func NewHierarchicalPathFinder(grid *NavGrid, clusterSize int) *HierarchicalPathFinder {
+	h := &HierarchicalPathFinder{
+		PathFinder:  &PathFinder{},
+		ClusterSize: clusterSize,
+		grid:        grid,
+		cols:        (grid.Width + clusterSize - 1) / clusterSize,
+		rows:        (grid.Height + clusterSize - 1) / clusterSize,
+		nodes:       map[utils.Node]*abstractNode{},
+		borders:     map[borderKey][][2]utils.Node{},
+	}
+	dirty := map[int]bool{}
+	for c := 0; c < h.cols*h.rows; c++ {
+		dirty[c] = true
+	}
+	h.rebuild(dirty)
+	return h
+}
+
+// derive builds the finder of a newer snapshot of the same size, only the
+// dirty clusters and their neighbors get rebuilt
+func (h *HierarchicalPathFinder) derive(grid *NavGrid, dirty map[int]bool) *HierarchicalPathFinder {
+	if grid.Width != h.grid.Width || grid.Height != h.grid.Height {
+		return NewHierarchicalPathFinder(grid, h.ClusterSize)
+	}
+	derived := &HierarchicalPathFinder{
+		PathFinder:  h.PathFinder,
+		ClusterSize: h.ClusterSize,
+		grid:        grid,
+		cols:        h.cols,
+		rows:        h.rows,
+		nodes:       map[utils.Node]*abstractNode{},
+		borders:     maps.Clone(h.borders),
+	}
+	for tile, node := range h.nodes {
+		copied := *node
+		copied.Edges = slices.Clone(node.Edges)
+		derived.nodes[tile] = &copied
+	}
+	if len(dirty) > 0 {
+		derived.rebuild(dirty)
+	}
+	return derived
+}
+
+func (h *HierarchicalPathFinder) clusterOf(x, y int) int {
+	return clusterIndex(h.grid.Width, h.ClusterSize, x, y)
+}
+
+// clusterIndex is the cluster of the tile on a map of the width
+func clusterIndex(width, clusterSize, x, y int) int {
+	cols := (width + clusterSize - 1) / clusterSize
+	return (y/clusterSize)*cols + x/clusterSize
+}
+
+func (h *HierarchicalPathFinder) clusterBounds(cluster int) Bounds {
+	bounds := Bounds{
+		X: (cluster % h.cols) * h.ClusterSize,
+		Y: (cluster / h.cols) * h.ClusterSize,
+		W: h.ClusterSize,
+		H: h.ClusterSize,
+	}
+	return bounds.Intersect(h.grid.Bounds())
+}
+
+func (h *HierarchicalPathFinder) clusterNeighbors(cluster int) []int {
+	neighbors := []int{}
+	cx := cluster % h.cols
+	cy := cluster / h.cols
+	if cx > 0 {
+		neighbors = append(neighbors, cluster-1)
+	}
+	if cx < h.cols-1 {
+		neighbors = append(neighbors, cluster+1)
+	}
+	if cy > 0 {
+		neighbors = append(neighbors, cluster-h.cols)
+	}
+	if cy < h.rows-1 {
+		neighbors = append(neighbors, cluster+h.cols)
+	}
+	return neighbors
+}
+
+// rebuild recomputes the entrances and edges around the dirty clusters, only
+// while the finder is being built
+func (h *HierarchicalPathFinder) rebuild(dirtyClusters map[int]bool) {
+	dirty := []int{}
+	for cluster := range dirtyClusters {
+		if cluster >= 0 && cluster < h.cols*h.rows {
+			dirty = append(dirty, cluster)
+		}
+	}
+	sort.Ints(dirty)
+
+	borders := []borderKey{}
+	seen := map[borderKey]bool{}
+	affected := map[int]bool{}
+	for _, cluster := range dirty {
+		affected[cluster] = true
+		for _, neighbor := range h.clusterNeighbors(cluster) {
+			affected[neighbor] = true
+			key := borderKey{A: min(cluster, neighbor), B: max(cluster, neighbor)}
+			if !seen[key] {
+				seen[key] = true
+				borders = append(borders, key)
+			}
+		}
+	}
+
+	for _, key := range borders {
+		for _, pair := range h.borders[key] {
+			h.release(pair[0])
+			h.release(pair[1])
+		}
+		h.borders[key] = h.findEntrances(key)
+		for _, pair := range h.borders[key] {
+			h.acquire(pair[0])
+			h.acquire(pair[1])
+		}
+	}
+
+	clusters := []int{}
+	for cluster := range affected {
+		clusters = append(clusters, cluster)
+	}
+	sort.Ints(clusters)
+	for _, cluster := range clusters {
+		h.connectCluster(cluster)
+	}
+}
+
+func (h *HierarchicalPathFinder) acquire(tile utils.Node) {
+	node, ok := h.nodes[tile]
+	if !ok {
+		node = &abstractNode{Node: tile, Cluster: h.clusterOf(tile.X, tile.Y)}
+		h.nodes[tile] = node
+	}
+	node.refs++
+}
+
+func (h *HierarchicalPathFinder) release(tile utils.Node) {
+	node, ok := h.nodes[tile]
+	if !ok {
+		return
+	}
+	node.refs--
+	if node.refs <= 0 {
+		delete(h.nodes, tile)
+	}
+}
+
+// findEntrances scans the border for runs of tiles walkable on both sides
+func (h *HierarchicalPathFinder) findEntrances(key borderKey) [][2]utils.Node {
+	a := h.clusterBounds(key.A)
+	pairs := [][2]utils.Node{}
+
+	vertical := key.B == key.A+1
+	length := a.W
+	if vertical {
+		length = a.H
+	}
+	sides := func(i int) (utils.Node, utils.Node) {
+		if vertical {
+			x := a.X + a.W - 1
+			return utils.Node{X: x, Y: a.Y + i}, utils.Node{X: x + 1, Y: a.Y + i}
+		}
+		y := a.Y + a.H - 1
+		return utils.Node{X: a.X + i, Y: y}, utils.Node{X: a.X + i, Y: y + 1}
+	}
+
+	runStart := -1
+	for i := 0; i <= length; i++ {
+		open := false
+		if i < length {
+			inA, inB := sides(i)
+			open = h.grid.IsWalkable(inA.X, inA.Y) && h.grid.IsWalkable(inB.X, inB.Y)
+		}
+		if open && runStart == -1 {
+			runStart = i
+		}
+		if !open && runStart != -1 {
+			runEnd := i - 1
+			if runEnd-runStart+1 < entranceSplit {
+				inA, inB := sides((runStart + runEnd) / 2)
+				pairs = append(pairs, [2]utils.Node{inA, inB})
+			} else {
+				inA, inB := sides(runStart)
+				pairs = append(pairs, [2]utils.Node{inA, inB})
+				inA, inB = sides(runEnd)
+				pairs = append(pairs, [2]utils.Node{inA, inB})
+			}
+			runStart = -1
+		}
+	}
+	return pairs
+}
+
+// clusterNodes returns the abstract nodes of the cluster in a stable order
+func (h *HierarchicalPathFinder) clusterNodes(cluster int) []*abstractNode {
+	nodes := []*abstractNode{}
+	for _, node := range h.nodes {
+		if node.Cluster == cluster {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Node.Y != nodes[j].Node.Y {
+			return nodes[i].Node.Y < nodes[j].Node.Y
+		}
+		return nodes[i].Node.X < nodes[j].Node.X
+	})
+	return nodes
+}
+
+// connectCluster recomputes all edges going out of the nodes of the cluster
+func (h *HierarchicalPathFinder) connectCluster(cluster int) {
+	nodes := h.clusterNodes(cluster)
+	for _, node := range nodes {
+		node.Edges = nil
+	}
+
+	for _, neighbor := range h.clusterNeighbors(cluster) {
+		key := borderKey{A: min(cluster, neighbor), B: max(cluster, neighbor)}
+		for _, pair := range h.borders[key] {
+			from, to := pair[0], pair[1]
+			if key.A != cluster {
+				from, to = to, from
+			}
+			node := h.nodes[from]
+			node.Edges = append(node.Edges, &abstractEdge{To: to, Cost: h.grid.StepCost(from, to), path: []utils.Node{to}})
+		}
+	}
+
+	bounds := h.clusterBounds(cluster)
+	for i := 0; i < len(nodes); i++ {
+		for j := i + 1; j < len(nodes); j++ {
+			_, cost := h.PathFinder.SearchBounded(h.grid, bounds, nodes[i].Node, nodes[j].Node, PathAStar, nil)
+			if cost == 0 {
+				continue
+			}
+			nodes[i].Edges = append(nodes[i].Edges, &abstractEdge{To: nodes[j].Node, Cost: cost})
+			nodes[j].Edges = append(nodes[j].Edges, &abstractEdge{To: nodes[i].Node, Cost: cost})
+		}
+	}
+}
+
+// refine turns an edge into tiles, intra cluster edges are searched the first
+// time they are used
+func (h *HierarchicalPathFinder) refine(from utils.Node, edge *abstractEdge) []utils.Node {
+	edge.once.Do(func() {
+		if edge.path != nil {
+			return
+		}
+		bounds := h.clusterBounds(h.clusterOf(from.X, from.Y))
+		reversedpath, _ := h.PathFinder.SearchBounded(h.grid, bounds, from, edge.To, PathAStar, nil)
+		edge.path = ForwardPath(reversedpath)
+	})
+	return edge.path
+}
+
+// link connects a tile that is not an entrance to the entrances of its
+// cluster. The edges go into links instead of the graph so searches dont
+// touch the shared nodes, towards makes them point to the tile.
+func (h *HierarchicalPathFinder) link(tile utils.Node, links map[utils.Node][]*abstractEdge, towards bool) {
+	if _, ok := h.nodes[tile]; ok {
+		return
+	}
+	cluster := h.clusterOf(tile.X, tile.Y)
+	bounds := h.clusterBounds(cluster)
+	for _, other := range h.clusterNodes(cluster) {
+		if towards {
+			_, cost := h.PathFinder.SearchBounded(h.grid, bounds, other.Node, tile, PathAStar, nil)
+			if cost != 0 {
+				links[other.Node] = append(links[other.Node], &abstractEdge{To: tile, Cost: cost})
+			}
+			continue
+		}
+		_, cost := h.PathFinder.SearchBounded(h.grid, bounds, tile, other.Node, PathAStar, nil)
+		if cost != 0 {
+			links[tile] = append(links[tile], &abstractEdge{To: other.Node, Cost: cost})
+		}
+	}
+}
+
+// FindPath returns the path from end to start (reversed, start included) like
+// PathFinder.FindPath, nil if there is no path or the search got cancelled.
+// The any angle modes get string pulled afterwards.
+func (h *HierarchicalPathFinder) FindPath(start utils.Node, end utils.Node, mode PathMode, cancelled func() bool) []utils.Node {
+	if !h.grid.InBounds(start.X, start.Y) || !h.grid.IsWalkable(end.X, end.Y) {
+		return nil
+	}
+
+	startCluster := h.clusterOf(start.X, start.Y)
+	if startCluster == h.clusterOf(end.X, end.Y) {
+		path, _ := h.PathFinder.SearchBounded(h.grid, h.clusterBounds(startCluster), start, end, mode, cancelled)
+		if path != nil {
+			return path
+		}
+	}
+
+	links := map[utils.Node][]*abstractEdge{}
+	h.link(start, links, false)
+	h.link(end, links, true)
+	route := h.searchAbstract(start, end, links, cancelled)
+	if route == nil {
+		return nil
+	}
+
+	path := []utils.Node{start}
+	for i, edge := range route {
+		from := start
+		if i > 0 {
+			from = route[i-1].To
+		}
+		path = append(path, h.refine(from, edge)...)
+	}
+
+	reversed := make([]utils.Node, len(path))
+	for i, node := range path {
+		reversed[len(path)-1-i] = node
+	}
+	if mode != PathAStar {
+		reversed = h.PathFinder.SmoothPath(h.grid, reversed)
+		for i := 1; i < len(reversed); i++ {
+			if !h.PathFinder.LineOfSight(h.grid, reversed[i-1], reversed[i]) {
+				// the cluster paths squeeze past a corner, rare enough to
+				// search the whole grid
+				return h.PathFinder.FindPath(h.grid, start, end, mode, cancelled)
+			}
+		}
+	}
+	return reversed
+}
+
+// searchAbstract runs A* over the entrance graph and the links of the ends,
+// returns the used edges
+func (h *HierarchicalPathFinder) searchAbstract(start utils.Node, end utils.Node, links map[utils.Node][]*abstractEdge, cancelled func() bool) []*abstractEdge {
+	type state struct {
+		g      float64
+		parent utils.Node
+		edge   *abstractEdge
+		closed bool
+	}
+	bounds := h.grid.Bounds()
+	states := map[utils.Node]*state{start: {g: 0}}
+	open := &openSet{}
+	seq := 0
+	expanded := 0
+	heap.Push(open, openItem{index: bounds.Index(start.X, start.Y), f: h.PathFinder.Distance(start, end) * h.grid.MinCost})
+
+	for open.Len() > 0 {
+		item := heap.Pop(open).(openItem)
+		tile := bounds.Node(item.index)
+		current := states[tile]
+		if current.closed {
+			continue
+		}
+		if tile == end {
+			route := []*abstractEdge{}
+			for tile != start {
+				route = append(route, states[tile].edge)
+				tile = states[tile].parent
+			}
+			for i, j := 0, len(route)-1; i < j; i, j = i+1, j-1 {
+				route[i], route[j] = route[j], route[i]
+			}
+			return route
+		}
+		current.closed = true
+
+		expanded++
+		if cancelled != nil && expanded%64 == 0 && cancelled() {
+			return nil
+		}
+
+		edges := links[tile]
+		if node, ok := h.nodes[tile]; ok {
+			edges = append(slices.Clip(node.Edges), edges...)
+		}
+		for _, edge := range edges {
+			next, ok := states[edge.To]
+			if ok && next.closed {
+				continue
+			}
+			g := current.g + edge.Cost
+			if !ok || g < next.g {
+				states[edge.To] = &state{g: g, parent: tile, edge: edge}
+				seq++
+				heuristic := h.PathFinder.Distance(edge.To, end) * h.grid.MinCost
+				heap.Push(open, openItem{index: bounds.Index(edge.To.X, edge.To.Y), f: g + heuristic, h: heuristic, seq: seq})
+			}
+		}
+	}
+	return nil
+}
+
+// hierarchySource builds the finder of a snapshot the first time a long path
+// is searched on it, from the finder of an older snapshot when there is one
+type hierarchySource struct {
+	mu    sync.Mutex
+	grid  *NavGrid
+	base  *HierarchicalPathFinder
+	dirty map[int]bool //clusters changed since base
+	built *HierarchicalPathFinder
+}
+
+func (s *hierarchySource) finder() *HierarchicalPathFinder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.built == nil {
+		if s.base == nil {
+			s.built = NewHierarchicalPathFinder(s.grid, ClusterSize)
+		} else {
+			s.built = s.base.derive(s.grid, s.dirty)
+		}
+		s.base = nil
+		s.dirty = nil
+	}
+	return s.built
+}
+
+// next is the source of a newer snapshot, dirty are the clusters that changed
+// since this one. Snapshots nobody searched long paths on are skipped over.
+func (s *hierarchySource) next(grid *NavGrid, dirty map[int]bool) *hierarchySource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.built != nil {
+		return &hierarchySource{grid: grid, base: s.built, dirty: dirty}
+	}
+	merged := maps.Clone(dirty)
+	for cluster := range s.dirty {
+		merged[cluster] = true
+	}
+	return &hierarchySource{grid: grid, base: s.base, dirty: merged}
+}
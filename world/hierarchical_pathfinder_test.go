@@ -0,0 +1,130 @@
+package world
+
+import (
+	"bilydaniel/rpg/utils"
+	"slices"
+	"testing"
+)
+
+// pathLength is the cost of walking the path, neighbors are steps of A* and
+// longer segments need a clear line
+func pathLength(t *testing.T, grid *NavGrid, path []utils.Node) float64 {
+	t.Helper()
+	pf := &PathFinder{}
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		from, to := path[i-1], path[i]
+		if max(abs(to.X-from.X), abs(to.Y-from.Y)) == 1 {
+			if !grid.IsWalkable(from.X, from.Y) || !grid.IsWalkable(to.X, to.Y) {
+				t.Fatalf("Step from %v to %v is blocked", from, to)
+			}
+			total += pf.Distance(from, to) * grid.StepCost(from, to)
+			continue
+		}
+		cost, ok := pf.LineCost(grid, from, to)
+		if !ok {
+			t.Fatalf("Cant walk from %v to %v", path[i-1], path[i])
+		}
+		total += cost
+	}
+	return total
+}
+
+func TestHierarchicalPathLength(t *testing.T) {
+	level := loadLevel(t, "level_1")
+	grid := level.NavGrid()
+	hierarchy := grid.Hierarchy()
+	pf := &PathFinder{}
+
+	for _, mode := range []PathMode{PathAStar, PathSmoothed, PathThetaStar} {
+		for _, route := range benchmarkRoutes(t, grid) {
+			flat := pf.FindPath(grid, route[0], route[1], mode, nil)
+			path := hierarchy.FindPath(route[0], route[1], mode, nil)
+			if flat == nil || path == nil {
+				t.Fatalf("%v to %v: flat %v, hierarchical %v", route[0], route[1], flat != nil, path != nil)
+			}
+			if path[0] != route[1] || path[len(path)-1] != route[0] {
+				t.Fatalf("Path from %v to %v runs from %v to %v", route[0], route[1], path[len(path)-1], path[0])
+			}
+			flatLength := pathLength(t, grid, flat)
+			length := pathLength(t, grid, path)
+			if length > flatLength*1.15 {
+				t.Fatalf("Mode %d %v to %v: %.1f, A* %.1f", mode, route[0], route[1], length, flatLength)
+			}
+		}
+	}
+}
+
+func TestHierarchicalSameCluster(t *testing.T) {
+	level := openLevel(40, 40)
+	hierarchy := level.NavGrid().Hierarchy()
+	path := hierarchy.FindPath(utils.Node{X: 1, Y: 1}, utils.Node{X: 10, Y: 1}, PathAStar, nil)
+	if len(path) != 10 {
+		t.Fatalf("Got %v", path)
+	}
+}
+
+func TestHierarchicalAfterChange(t *testing.T) {
+	// a wall through the middle with a single gap, then the gap closes and
+	// another one opens
+	level := openLevel(64, 64)
+	for y := 0; y < 64; y++ {
+		if y != 5 {
+			level.SetWalkable(32, y, false)
+		}
+	}
+	start, end := utils.Node{X: 2, Y: 60}, utils.Node{X: 60, Y: 60}
+
+	before := level.NavGrid()
+	path := before.Hierarchy().FindPath(start, end, PathAStar, nil)
+	pathLength(t, before, path)
+	if !contains(path, utils.Node{X: 32, Y: 5}) {
+		t.Fatalf("Path misses the gap %v", path)
+	}
+
+	level.SetWalkable(32, 5, false)
+	level.SetWalkable(32, 50, true)
+	after := level.NavGrid()
+	path = after.Hierarchy().FindPath(start, end, PathAStar, nil)
+	pathLength(t, after, path)
+	if !contains(path, utils.Node{X: 32, Y: 50}) {
+		t.Fatalf("Path misses the new gap %v", path)
+	}
+
+	// the old snapshot keeps its own abstraction
+	path = before.Hierarchy().FindPath(start, end, PathAStar, nil)
+	if !contains(path, utils.Node{X: 32, Y: 5}) {
+		t.Fatalf("Old snapshot changed %v", path)
+	}
+
+	level.SetWalkable(32, 50, false)
+	if path := level.NavGrid().Hierarchy().FindPath(start, end, PathAStar, nil); path != nil {
+		t.Fatalf("Path through the wall %v", path)
+	}
+}
+
+func TestHierarchicalAvoidingGrid(t *testing.T) {
+	level := openLevel(10, 10)
+	if level.NavGridAvoiding(nil).Hierarchy() != nil {
+		t.Fatal("Avoiding snapshots should search flat")
+	}
+}
+
+func contains(path []utils.Node, node utils.Node) bool {
+	return slices.Contains(path, node)
+}
+
+func BenchmarkHierarchicalFindPath(b *testing.B) {
+	level := loadLevel(b, "level_1")
+	grid := level.NavGrid()
+	hierarchy := grid.Hierarchy()
+	routes := benchmarkRoutes(b, grid)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		route := routes[i%len(routes)]
+		if hierarchy.FindPath(route[0], route[1], PathAStar, nil) == nil {
+			b.Fatalf("No path from %v to %v", route[0], route[1])
+		}
+	}
+}
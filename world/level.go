@@ -31,7 +31,25 @@ type Level struct {
 	Sources        map[string]int      //source => firstgid
 	SourceData     map[string]*assets.TilesetData
 	Obstacles      map[string][]assets.Object
+	Terrains       map[int]TerrainInfo            //gid => terrain, only tiles that have properties
+	Tilesets       []LevelTileset                 //sorted by firstgid
+	Footprints     map[int][]utils.CollisionShape //building object id => shapes in world pixels
+	Layers         []assets.TilemapLayer          //all the layers of the map in draw order
+	Portals        map[string]Portal              //name => portal
+	Areas          []Area
+	Routes         map[string][]utils.Node //patrol routes by name
+	NpcSpawns      []assets.Object         //the npcs the world creates with the level
+	GroundItems    []GroundItem
 	LightingSystem *LightingSystem
+	Fog            *FogOfWar
+	navGrid        *NavGrid
+	hierarchy      *hierarchySource
+	dirtyClusters  map[int]bool       //changed since the last snapshot
+	changed        map[tileKey]uint32 //tiles changed since loading, for saving
+	chunks         map[chunkKey]*chunk
+	frame          int
+	worldImage     *ebiten.Image
+	occluderImage  *ebiten.Image
 }
 
 func InitLevel() Level {
@@ -46,79 +64,85 @@ func InitLevel() Level {
 	if l.Obstacles == nil {
 		l.Obstacles = map[string][]assets.Object{}
 	}
+	if l.Terrains == nil {
+		l.Terrains = map[int]TerrainInfo{}
+	}
+	if l.Footprints == nil {
+		l.Footprints = map[int][]utils.CollisionShape{}
+	}
+	if l.Portals == nil {
+		l.Portals = map[string]Portal{}
+	}
+	if l.Routes == nil {
+		l.Routes = map[string][]utils.Node{}
+	}
+	if l.changed == nil {
+		l.changed = map[tileKey]uint32{}
+	}
 
 	return l
 }
 
-func (l *Level) Draw(screen *ebiten.Image, cam *config.Camera, assets assets.Assets, pcharacters []*entities.PCharacter) {
-	opts := ebiten.DrawImageOptions{}
-	worldImage := ebiten.NewImage(screen.Bounds().Dx(), screen.Bounds().Dy())
-	for y := 0; y < len(l.Grid); y++ {
-		for x := 0; x < len(l.Grid[y]); x++ {
-			tile := l.Grid[y][x]
-			//TODO REMOVE HARDCODE
-			image := assets.GetTileImage(l.SourceData["floors"].Name, l.SourceData["floors"].Image, l.SourceData["floors"].Columns, tile.ID)
-			if image != nil {
-				opts.GeoM.Reset()
-				cam.WorldToScreenGeom(&opts, x*config.TileSize, y*config.TileSize)
-
-				//screen.DrawImage(image, &opts)
-				worldImage.DrawImage(image, &opts)
-			}
-		}
+// Draw draws the level together with whatever is already in the queue, tile
+// layers under the first object layer are the ground, the ones above it are
+// drawn over everything sorted, the lighting goes over all of it
+func (l *Level) Draw(screen *ebiten.Image, cam *config.Camera, assets assets.Assets, queue *RenderQueue, clock *GameClock) {
+	l.frame++
+	if l.worldImage == nil || l.worldImage.Bounds() != screen.Bounds() {
+		l.worldImage = ebiten.NewImage(screen.Bounds().Dx(), screen.Bounds().Dy())
+		l.occluderImage = ebiten.NewImage(screen.Bounds().Dx(), screen.Bounds().Dy())
 	}
-
-	for _, v := range l.Obstacles["buildings"] {
-		gid := v.GID
-		firstgid := l.Sources["buildings.tsj"] //TODO REMOVE HARDCODE
-		id := gid - firstgid
-
-		resultimage := ""
-
-		//TODO change from O(n) to O(1)
-		for _, data := range l.SourceData["buildings"].Tiles {
-			if data.ID == id {
-				resultimage = data.Image
+	l.worldImage.Clear()
+
+	overlay := []layerEntry{}
+	objectsSeen := false
+	for _, entry := range visibleLayers(l.Layers, 1) {
+		switch entry.Layer.Type {
+		case "tilelayer":
+			if objectsSeen {
+				overlay = append(overlay, entry)
+				continue
+			}
+			l.drawChunkedTileLayer(l.worldImage, cam, &assets, entry.Layer, entry.Opacity)
+		case "objectgroup":
+			if dataLayer(entry.Layer.Name) {
+				continue
 			}
+			objectsSeen = true
+			l.submitObjectLayer(queue, cam, &assets, entry.Layer, entry.Opacity)
 		}
+	}
+	queue.Flush(l.worldImage)
+	for _, entry := range overlay {
+		l.drawChunkedTileLayer(l.worldImage, cam, &assets, entry.Layer, entry.Opacity)
+	}
 
-		image := assets.GetImage(l.SourceData["buildings"].Name, resultimage)
-		if image != nil {
-			opts.GeoM.Reset()
-			cam.WorldToScreenGeom(&opts, v.X, v.Y)
-			//screen.DrawImage(image, &opts)
-			worldImage.DrawImage(image, &opts)
-		}
+	if l.frame%chunkEvictFrames == 0 {
+		l.evictChunks()
 	}
 
-	l.LightingSystem.Draw(screen, worldImage, pcharacters)
+	l.drawOccluders(l.occluderImage, cam, &assets)
+	l.LightingSystem.Draw(screen, l.worldImage, l.occluderImage, cam, clock.Ambient())
+	l.Fog.Draw(screen, cam)
 
 }
 
 func (l *Level) NodeFromPoint(point utils.Point) *utils.Node {
-	//TODO breaks when I zoom out really far, out of bounds, probably just put a max to the zoomout
-	x := int(point.X / config.TileSize)
-	y := int(point.Y / config.TileSize)
+	x := int(math.Floor(point.X / config.TileSize))
+	y := int(math.Floor(point.Y / config.TileSize))
 
 	y = int(math.Max(float64(y), 0))
-	y = int(math.Min(float64(y), float64(l.Height)))
+	y = int(math.Min(float64(y), float64(l.Height-1)))
 
 	x = int(math.Max(float64(x), 0))
-	x = int(math.Min(float64(x), float64(l.Width)))
+	x = int(math.Min(float64(x), float64(l.Width-1)))
 
 	tile := l.Grid[y][x]
 	return &tile.Node
 }
 
-func (l *Level) ResetValues() {
-	for i := 0; i < l.Height; i++ {
-		for j := 0; j < l.Width; j++ {
-			l.Grid[i][j].F = 0
-			l.Grid[i][j].G = 0
-			l.Grid[i][j].H = 0
-			l.Grid[i][j].Parent = nil
-		}
-	}
+func (l *Level) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < l.Width && y < l.Height
 }
 
 func (l *Level) LoadLevel(name string) error {
@@ -159,16 +183,25 @@ func (l *Level) LoadLevel(name string) error {
 			}
 		}
 
+		for _, tile := range sourceData.Tiles {
+			if len(tile.Properties) > 0 {
+				l.Terrains[source.Firstgid+tile.ID] = TerrainInfoFromProperties(tile.Properties)
+			}
+		}
+
 		l.SourceData[sourceData.Name] = &sourceData
+		l.addTileset(LevelTileset{Firstgid: source.Firstgid, Source: source.Source, Data: &sourceData})
 	}
 	l.Height = tilemap.Height
 	l.Width = tilemap.Width
+	l.Layers = tilemap.Layers
 
-	l.LightingSystem, err = NewLightingSystem(l.Width, l.Height)
+	l.LightingSystem, err = NewLightingSystem()
 	if err != nil {
 		return err
 	}
-	l.LightingSystem.AddLight(0, 0)
+
+	l.Fog = NewFogOfWar(l.Width, l.Height)
 
 	l.Occupancy = make([][]entities.Sprite, l.Height)
 	for i := 0; i < l.Height; i++ {
@@ -176,9 +209,11 @@ func (l *Level) LoadLevel(name string) error {
 	}
 
 	l.Grid = make([][]*Tile, l.Height)
-	for _, layer := range tilemap.Layers {
+	buildings := []assets.Object{}
+	groundLayer := groundLayerName(tilemap.Layers)
+	for _, layer := range flattenLayers(tilemap.Layers) {
 		if layer.Type == "tilelayer" {
-			if layer.Name == "tiles" {
+			if layer.Name == groundLayer {
 				if len(layer.Data) < l.Width*l.Height {
 					return fmt.Errorf("Tile layer has not enough data")
 				}
@@ -188,7 +223,12 @@ func (l *Level) LoadLevel(name string) error {
 					l.Grid[i] = make([]*Tile, l.Width)
 					for j := 0; j < l.Width; j++ {
 						//X
-						l.Grid[i][j] = &Tile{ID: layer.Data[(i*l.Width)+j], Node: utils.Node{X: j, Y: i}, Walkable: true}
+						gid, _ := assets.SplitGID(layer.Data[(i*l.Width)+j])
+						terrain, ok := l.Terrains[gid]
+						if !ok {
+							terrain = DefaultTerrainInfo()
+						}
+						l.Grid[i][j] = &Tile{ID: gid, Node: utils.Node{X: j, Y: i}, Walkable: terrain.Walkable, Cost: terrain.Cost, Terrain: terrain.Terrain}
 					}
 				}
 			}
@@ -196,38 +236,83 @@ func (l *Level) LoadLevel(name string) error {
 
 		if layer.Type == "objectgroup" {
 			if layer.Name == "buildings" {
-				for _, v := range layer.Objects {
-					l.Obstacles[layer.Name] = append(l.Obstacles[layer.Name], v)
-					//TODO solve rotation
-					xgrid := v.X / 16
-					ygrid := v.Y / 16
-
-					heightgrid := v.Height / 16
-					widthgrid := v.Width / 16
-					l.Grid[ygrid][xgrid].Walkable = false
-					for i := 0; i < heightgrid; i++ {
-						l.Grid[ygrid+i][xgrid].Walkable = false
-						l.Grid[ygrid+i][xgrid+widthgrid-1].Walkable = false
-					}
-					for j := 0; j < widthgrid; j++ {
-						l.Grid[ygrid][xgrid+j].Walkable = false
-						l.Grid[ygrid+heightgrid-1][xgrid+j].Walkable = false
+				buildings = append(buildings, layer.Objects...)
+			}
+			if layer.Name == PortalsLayer {
+				for _, object := range layer.Objects {
+					l.Portals[object.Name] = PortalFromObject(l.Name, object)
+				}
+			}
+			if layer.Name == NpcsLayer {
+				l.NpcSpawns = append(l.NpcSpawns, layer.Objects...)
+			}
+			if layer.Name == RoutesLayer {
+				for _, object := range layer.Objects {
+					l.Routes[object.Name] = RouteFromObject(object)
+				}
+			}
+			if layer.Name == AreasLayer {
+				for _, object := range layer.Objects {
+					l.Areas = append(l.Areas, AreaFromObject(object))
+				}
+			}
+			if layer.Name == ItemsLayer {
+				for _, object := range layer.Objects {
+					ground, err := GroundItemFromObject(object)
+					if err != nil {
+						return err
 					}
+					l.GroundItems = append(l.GroundItems, ground)
+				}
+			}
+			if layer.Name == LightsLayer {
+				for _, object := range layer.Objects {
+					l.LightingSystem.AddLight(LightFromObject(object))
 				}
 			}
 		}
 	}
+
+	if len(l.Grid) != l.Height || (l.Height > 0 && l.Grid[0] == nil) {
+		return fmt.Errorf("Level has no tile layer")
+	}
+	// after the tiles, the layers dont have to be in this order in the file
+	for _, building := range buildings {
+		l.AddBuilding(building)
+	}
 	return nil
 }
 
 func (level *Level) WalkableTile(node *utils.Node) bool {
+	if !level.InBounds(node.X, node.Y) {
+		return false
+	}
 	return level.Grid[node.Y][node.X].Walkable
 }
 
 func (level *Level) OccupiedTile(node *utils.Node) bool {
+	if !level.InBounds(node.X, node.Y) {
+		return false
+	}
 	return level.Occupancy[node.Y][node.X] != nil
 }
 
+// dataLayer tells if the object layer only holds game data and isnt drawn
+func dataLayer(name string) bool {
+	switch name {
+	case LightsLayer, PortalsLayer, ItemsLayer, AreasLayer, RoutesLayer, NpcsLayer:
+		return true
+	}
+	return false
+}
+
+func (level *Level) TileOccupant(node *utils.Node) entities.Sprite {
+	if !level.InBounds(node.X, node.Y) {
+		return nil
+	}
+	return level.Occupancy[node.Y][node.X]
+}
+
 func (level *Level) SetTileOccupied(sprite entities.Sprite, x, y int) {
 	if x < 0 || y < 0 {
 		return
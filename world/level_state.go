@@ -0,0 +1,72 @@
+package world
+
+import (
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/save"
+	"bilydaniel/rpg/utils"
+	"sort"
+)
+
+// State is the part of the level that changed while playing, the rest comes
+// from the map file. ref names the occupants of the tiles.
+func (l *Level) State(ref func(sprite entities.Sprite) (kind string, id string, ok bool)) save.Level {
+	state := save.Level{
+		Name:        l.Name,
+		Changed:     []save.TileChange{},
+		Explored:    l.Fog.ExploredBits(),
+		GroundItems: []save.GroundItem{},
+	}
+	for _, ground := range l.GroundItems {
+		state.GroundItems = append(state.GroundItems, save.GroundItem{ID: ground.ID, Item: ground.Item, Count: ground.Count, X: ground.Tile.X, Y: ground.Tile.Y})
+	}
+	for key, gid := range l.changed {
+		state.Changed = append(state.Changed, save.TileChange{Layer: key.Layer, X: key.X, Y: key.Y, GID: gid})
+	}
+	sort.Slice(state.Changed, func(i, j int) bool {
+		a, b := state.Changed[i], state.Changed[j]
+		if a.Layer != b.Layer {
+			return a.Layer < b.Layer
+		}
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+
+	for y, row := range l.Occupancy {
+		for x, sprite := range row {
+			if sprite == nil {
+				continue
+			}
+			if kind, id, ok := ref(sprite); ok {
+				state.Occupancy = append(state.Occupancy, save.Occupant{X: x, Y: y, Kind: kind, ID: id})
+			}
+		}
+	}
+	return state
+}
+
+// RestoreState applies a saved state to a freshly loaded level, resolve turns
+// the saved occupants back into sprites
+func (l *Level) RestoreState(state save.Level, resolve func(kind string, id string) entities.Sprite) error {
+	for _, change := range state.Changed {
+		l.SetTileGID(change.Layer, change.X, change.Y, change.GID)
+	}
+	for y := range l.Occupancy {
+		for x := range l.Occupancy[y] {
+			l.Occupancy[y][x] = nil
+		}
+	}
+	for _, occupant := range state.Occupancy {
+		if sprite := resolve(occupant.Kind, occupant.ID); sprite != nil {
+			l.SetTileOccupied(sprite, occupant.X, occupant.Y)
+		}
+	}
+	if state.GroundItems != nil {
+		l.GroundItems = []GroundItem{}
+		for _, ground := range state.GroundItems {
+			l.GroundItems = append(l.GroundItems, GroundItem{ID: ground.ID, Item: ground.Item, Count: ground.Count, Tile: utils.Node{X: ground.X, Y: ground.Y}})
+		}
+	}
+	return l.Fog.SetExploredBits(state.Explored)
+}
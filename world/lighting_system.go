@@ -1,47 +1,115 @@
 package world
 
 import (
+	"bilydaniel/rpg/assets"
 	"bilydaniel/rpg/config"
-	"bilydaniel/rpg/entities"
+	"cmp"
 	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
+const (
+	// MaxLights has to match MAX_LIGHTS in the shader, when more lights are on
+	// screen the ones furthest from its middle are dropped
+	MaxLights = 100
+	// LightsLayer is the Tiled object layer the level lights are read from
+	LightsLayer = "lights"
+
+	defaultLightRadius    = 64
+	defaultLightIntensity = 0.75
+)
+
 const shaderConst = `
-	package main
+//kage:unit pixels
+package main
 
-	const MAX_LIGHTS int = 100
+const MAX_LIGHTS = 100
+const SHADOW_STEPS = 32
 
-	// Uniforms
-	var NumLights int
-	var Lights[100]vec4
-	var PlayerPost vec2
-	var ViewDistance float
-	var ScreenSize vec2
+// Uniforms
+var NumLights int
+var Lights [100]vec4      // x, y, radius, intensity in screen pixels
+var LightColors [100]vec4 // r, g, b, unused
+var Ambient vec3
 
-func Fragment(position vec4, texCoord vec2, color vec4) vec4{
-	//worldPos := texCoord * ScreenSize 
-	return vec4(0.0, 0.0, 0.0, 0.0)
-		
+// occluders image, red is a building footprint, green is a building image
+func occluder(pos vec2) vec4 {
+	return imageSrc1At(pos - imageSrc0Origin() + imageSrc1Origin())
 }
 
-	`
+func Fragment(dstPos vec4, srcPos vec2, color vec4) vec4 {
+	// buildings themselves are lit, only the ground around them gets shadows
+	mask := occluder(srcPos)
+	receivesShadow := mask.r == 0.0 && mask.g == 0.0
 
+	light := Ambient
+	for i := 0; i < MAX_LIGHTS; i++ {
+		if i >= NumLights {
+			break
+		}
+		source := Lights[i]
+		dist := distance(dstPos.xy, source.xy)
+		if dist < source.z {
+			lit := 1.0
+			if receivesShadow {
+				toLight := source.xy - dstPos.xy
+				for step := 1; step < SHADOW_STEPS; step++ {
+					if occluder(srcPos+toLight*float(step)/float(SHADOW_STEPS)).r > 0.5 {
+						lit = 0.0
+						break
+					}
+				}
+			}
+			falloff := 1.0 - (dist*dist)/(source.z*source.z)
+			light += LightColors[i].rgb * source.w * falloff * lit
+		}
+	}
+	light = clamp(light, 0.0, 1.0)
+
+	original := imageSrc0At(srcPos)
+	return vec4(original.rgb*light, original.a)
+}
+`
+
+// LightOwner is anything a light can be carried by, position in tiles
+type LightOwner interface {
+	GetX() float64
+	GetY() float64
+}
+
+// LightSource is a point light, X and Y are world pixels, an owned light
+// ignores them and sits in the middle of its owners tile
 type LightSource struct {
-	X, Y, R   float32
+	X, Y      float32
+	Radius    float32
 	Intensity float32
+	Color     [3]float32
+	Owner     LightOwner
 }
+
 type LightingSystem struct {
 	Shader       *ebiten.Shader
 	Lights       []LightSource
 	ShaderLights []float32
-	ViewDistance float32
-	ScreenW      float32
-	ScreenH      float32
+	ShaderColors []float32
+	visible      []screenLight //reused every frame
 }
 
-func NewLightingSystem(W, H int) (*LightingSystem, error) {
+// screenLight is a light in screen pixels
+type screenLight struct {
+	X, Y      float64
+	Radius    float64
+	Intensity float32
+	Color     [3]float32
+	distance  float64 //squared, from the middle of the screen
+}
+
+func NewLightingSystem() (*LightingSystem, error) {
 	shader, err := ebiten.NewShader([]byte(shaderConst))
 	if err != nil {
 		fmt.Println("SHADER ERROR:")
@@ -51,44 +119,142 @@ func NewLightingSystem(W, H int) (*LightingSystem, error) {
 	return &LightingSystem{
 		Shader:       shader,
 		Lights:       []LightSource{},
-		ShaderLights: []float32{},
-		ViewDistance: 64,
-		ScreenW:      float32(W),
-		ScreenH:      float32(H),
+		ShaderLights: make([]float32, MaxLights*4),
+		ShaderColors: make([]float32, MaxLights*4),
 	}, nil
 }
 
-func (l *LightingSystem) Draw(screen *ebiten.Image, worldImage *ebiten.Image, pcharacters []*entities.PCharacter) {
-	playerX := pcharacters[0].GetX() * config.TileSize
-	playerY := pcharacters[0].GetY() * config.TileSize
+// Torch is the light the characters carry around
+func Torch() LightSource {
+	return LightSource{
+		Radius:    72,
+		Intensity: 0.9,
+		Color:     [3]float32{1, 0.75, 0.45},
+	}
+}
+
+// Draw lights the world image onto the screen, the occluders image says where
+// the light cant go through
+func (l *LightingSystem) Draw(screen *ebiten.Image, worldImage *ebiten.Image, occluders *ebiten.Image, cam *config.Camera, ambient [3]float32) {
+	bounds := screen.Bounds()
+	centerx, centery := float64(bounds.Dx())/2, float64(bounds.Dy())/2
+	l.visible = l.visible[:0]
+	for _, light := range l.Lights {
+		x, y := float64(light.X), float64(light.Y)
+		if light.Owner != nil {
+			x = (light.Owner.GetX() + 0.5) * config.TileSize
+			y = (light.Owner.GetY() + 0.5) * config.TileSize
+		}
+		screenx, screeny := cam.WorldToScreen(x, y)
+		radius := float64(light.Radius) * cam.Scale
+		if screenx+radius < 0 || screeny+radius < 0 || screenx-radius > float64(bounds.Dx()) || screeny-radius > float64(bounds.Dy()) {
+			continue
+		}
+		dx, dy := screenx-centerx, screeny-centery
+		l.visible = append(l.visible, screenLight{
+			X:         screenx,
+			Y:         screeny,
+			Radius:    radius,
+			Intensity: light.Intensity,
+			Color:     light.Color,
+			distance:  dx*dx + dy*dy,
+		})
+	}
+	if len(l.visible) > MaxLights {
+		slices.SortStableFunc(l.visible, func(a, b screenLight) int {
+			return cmp.Compare(a.distance, b.distance)
+		})
+		l.visible = l.visible[:MaxLights]
+	}
+
+	count := len(l.visible)
+	for i, light := range l.visible {
+		baseIndex := i * 4
+		l.ShaderLights[baseIndex] = float32(light.X)
+		l.ShaderLights[baseIndex+1] = float32(light.Y)
+		l.ShaderLights[baseIndex+2] = float32(light.Radius)
+		l.ShaderLights[baseIndex+3] = light.Intensity
+		copy(l.ShaderColors[baseIndex:baseIndex+3], light.Color[:])
+	}
 
 	op := &ebiten.DrawRectShaderOptions{}
 	op.Uniforms = map[string]interface{}{
-		"Lights":       l.ShaderLights,
-		"NumLights":    len(l.Lights),
-		"PlayerPos":    []float32{float32(playerX), float32(playerY)},
-		"ViewDistance": l.ViewDistance,
-		"ScreenSize":   []float32{l.ScreenW, l.ScreenH},
+		"Lights":      l.ShaderLights,
+		"LightColors": l.ShaderColors,
+		"NumLights":   count,
+		"Ambient":     ambient[:],
 	}
 
 	op.Images[0] = worldImage
+	op.Images[1] = occluders
 
-	screen.DrawRectShader(screen.Bounds().Dx(), screen.Bounds().Dy(), l.Shader, op)
+	screen.DrawRectShader(bounds.Dx(), bounds.Dy(), l.Shader, op)
 }
-func (l *LightingSystem) AddLight(x, y int) {
-	l.Lights = append(l.Lights, LightSource{
-		X:         float32(x * config.TileSize),
-		Y:         float32(y * config.TileSize),
-		R:         64.0,
-		Intensity: 0.75,
-	})
-
-	l.ShaderLights = make([]float32, 400)
-	for i, light := range l.Lights {
-		baseIndex := i * 4
-		l.ShaderLights[baseIndex] = light.X
-		l.ShaderLights[baseIndex+1] = light.Y
-		l.ShaderLights[baseIndex+2] = light.R
-		l.ShaderLights[baseIndex+3] = light.Intensity
+
+func (l *LightingSystem) AddLight(light LightSource) {
+	l.Lights = append(l.Lights, light)
+}
+
+// Attach gives the owner a light that moves with it, an owner has one light
+func (l *LightingSystem) Attach(owner LightOwner, light LightSource) {
+	l.Detach(owner)
+	light.Owner = owner
+	l.AddLight(light)
+}
+
+func (l *LightingSystem) Detach(owner LightOwner) {
+	lights := l.Lights[:0]
+	for _, light := range l.Lights {
+		if light.Owner != owner {
+			lights = append(lights, light)
+		}
+	}
+	l.Lights = lights
+}
+
+// LightFromObject reads a light from a Tiled object, it sits in the middle of
+// the object and uses the radius, intensity and color properties
+func LightFromObject(object assets.Object) LightSource {
+	light := LightSource{
+		X:         float32(object.X + object.Width/2),
+		Y:         float32(object.Y + object.Height/2),
+		Radius:    defaultLightRadius,
+		Intensity: defaultLightIntensity,
+		Color:     [3]float32{1, 1, 1},
 	}
+	if radius, ok := assets.PropertyFloat(object.Properties, "radius"); ok {
+		light.Radius = float32(radius)
+	}
+	if intensity, ok := assets.PropertyFloat(object.Properties, "intensity"); ok {
+		light.Intensity = float32(intensity)
+	}
+	if value, ok := assets.PropertyString(object.Properties, "color"); ok {
+		if color, err := parseColor(value); err == nil {
+			light.Color = color
+		} else {
+			log.Printf("Light %d: %v", object.ID, err)
+		}
+	}
+	return light
+}
+
+// parseColor reads Tiled and data file colors, #rrggbb or #aarrggbb, alpha
+// is ignored
+func parseColor(value string) ([3]float32, error) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 8 {
+		hex = hex[2:]
+	}
+	if len(hex) != 6 {
+		return [3]float32{}, fmt.Errorf("Wrong color %q", value)
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return [3]float32{}, fmt.Errorf("Wrong color %q: %w", value, err)
+	}
+	return [3]float32{
+		float32(rgb>>16&0xff) / 255,
+		float32(rgb>>8&0xff) / 255,
+		float32(rgb&0xff) / 255,
+	}, nil
 }
@@ -0,0 +1,157 @@
+package world
+
+import (
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/utils"
+)
+
+// NavGrid is a read-only copy of the level walkability, safe to share between
+// goroutines, the level makes a new one whenever walkability changes
+type NavGrid struct {
+	Width    int
+	Height   int
+	Walkable []bool
+	Cost     []float64
+	// MinCost scales the heuristic so it never overestimates on cheap roads
+	MinCost float64
+
+	hierarchy *hierarchySource //nil on the avoiding snapshots
+}
+
+// Bounds is a rectangle of tiles
+type Bounds struct {
+	X, Y int
+	W, H int
+}
+
+func (b Bounds) Contains(x, y int) bool {
+	return x >= b.X && y >= b.Y && x < b.X+b.W && y < b.Y+b.H
+}
+
+func (b Bounds) Intersect(other Bounds) Bounds {
+	x0 := max(b.X, other.X)
+	y0 := max(b.Y, other.Y)
+	x1 := min(b.X+b.W, other.X+other.W)
+	y1 := min(b.Y+b.H, other.Y+other.H)
+	if x1 <= x0 || y1 <= y0 {
+		return Bounds{}
+	}
+	return Bounds{X: x0, Y: y0, W: x1 - x0, H: y1 - y0}
+}
+
+// Index of the tile inside of the bounds, row by row
+func (b Bounds) Index(x, y int) int {
+	return (y-b.Y)*b.W + (x - b.X)
+}
+
+func (b Bounds) Node(index int) utils.Node {
+	return utils.Node{X: b.X + index%b.W, Y: b.Y + index/b.W}
+}
+
+func (g *NavGrid) Bounds() Bounds {
+	return Bounds{X: 0, Y: 0, W: g.Width, H: g.Height}
+}
+
+func (g *NavGrid) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < g.Width && y < g.Height
+}
+
+func (g *NavGrid) IsWalkable(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	return g.Walkable[y*g.Width+x]
+}
+
+// StepCost of moving between two neighboring tiles, per tile of distance
+func (g *NavGrid) StepCost(from utils.Node, to utils.Node) float64 {
+	return (g.Cost[from.Y*g.Width+from.X] + g.Cost[to.Y*g.Width+to.X]) / 2
+}
+
+// NavGrid returns the current walkability snapshot, never modify it
+func (l *Level) NavGrid() *NavGrid {
+	if l.navGrid != nil {
+		return l.navGrid
+	}
+
+	grid := &NavGrid{
+		Width:    l.Width,
+		Height:   l.Height,
+		Walkable: make([]bool, l.Width*l.Height),
+		Cost:     make([]float64, l.Width*l.Height),
+		MinCost:  1,
+	}
+	for y := 0; y < l.Height; y++ {
+		for x := 0; x < l.Width; x++ {
+			tile := l.Grid[y][x]
+			grid.Walkable[y*l.Width+x] = tile.Walkable
+			grid.Cost[y*l.Width+x] = tile.Cost
+			if tile.Walkable && tile.Cost < grid.MinCost {
+				grid.MinCost = tile.Cost
+			}
+		}
+	}
+	if l.hierarchy == nil {
+		grid.hierarchy = &hierarchySource{grid: grid}
+	} else {
+		grid.hierarchy = l.hierarchy.next(grid, l.dirtyClusters)
+	}
+	l.hierarchy = grid.hierarchy
+	l.dirtyClusters = nil
+	l.navGrid = grid
+	return grid
+}
+
+// Hierarchy is the HPA* abstraction of the snapshot, built on the first call.
+// Nil for snapshots with occupied tiles, they change too often to be worth it.
+func (g *NavGrid) Hierarchy() *HierarchicalPathFinder {
+	if g.hierarchy == nil {
+		return nil
+	}
+	return g.hierarchy.finder()
+}
+
+// NavGridAvoiding is a fresh snapshot where every occupied tile is blocked,
+// except the ones occupied by ignore
+func (l *Level) NavGridAvoiding(ignore entities.Sprite) *NavGrid {
+	base := l.NavGrid()
+	grid := &NavGrid{
+		Width:    base.Width,
+		Height:   base.Height,
+		Walkable: make([]bool, len(base.Walkable)),
+		Cost:     base.Cost,
+		MinCost:  base.MinCost,
+	}
+	copy(grid.Walkable, base.Walkable)
+
+	for y := 0; y < l.Height; y++ {
+		for x := 0; x < l.Width; x++ {
+			occupant := l.Occupancy[y][x]
+			if occupant != nil && occupant != ignore {
+				grid.Walkable[y*l.Width+x] = false
+			}
+		}
+	}
+	return grid
+}
+
+func (l *Level) SetWalkable(x, y int, walkable bool) {
+	if !l.InBounds(x, y) {
+		return
+	}
+	if l.Grid[y][x].Walkable == walkable {
+		return
+	}
+	l.Grid[y][x].Walkable = walkable
+	// snapshots already handed out stay untouched
+	l.navGrid = nil
+	l.markClusterDirty(x, y)
+}
+
+// markClusterDirty makes the next snapshot rebuild the cluster of the tile
+func (l *Level) markClusterDirty(x, y int) {
+	if l.dirtyClusters == nil {
+		l.dirtyClusters = map[int]bool{}
+	}
+	l.dirtyClusters[clusterIndex(l.Width, ClusterSize, x, y)] = true
+}
@@ -0,0 +1,222 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/stats"
+	"bilydaniel/rpg/utils"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// NpcsLayer is the Tiled object layer the npcs spawn from
+	NpcsLayer = "npcs"
+	// RoutesLayer has the polylines patrols walk along, by object name
+	RoutesLayer = "routes"
+	// clips of the npc sprite for now, they get loaded with the first npc
+	npcClipsPath = "assets/images/Shaman/SeparateAnim/clips.json"
+)
+
+var npcAnimations *entities.Animations
+
+// SortedNpcIDs lists the npcs in a fixed order, so the decisions drawn from
+// the world seed come out the same every run
+func (w *World) SortedNpcIDs() []string {
+	ids := []string{}
+	for id := range w.Npcs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// UpdateNpcs lets every living npc decide and walk on its own level
+func (w *World) UpdateNpcs(paths *PathSystem, pcharacters []*entities.PCharacter) error {
+	for _, id := range w.SortedNpcIDs() {
+		npc := w.Npcs[id]
+		level, ok := w.Levels[npc.LevelName]
+		if !ok || !npc.Stats.Alive() {
+			continue
+		}
+		w.UpdateBehaviour(npc, level, paths, pcharacters)
+		npc.Update(level)
+		err := w.CheckNpcPortal(npc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AnimateNpcs advances the animations of the npcs on the current level
+func (w *World) AnimateNpcs() {
+	for _, npc := range w.Npcs {
+		if npc.LevelName == w.CurrentLevel.Name {
+			npc.Animate()
+		}
+	}
+}
+
+// RouteFromObject turns a polyline into the tiles it goes through
+func RouteFromObject(object assets.Object) []utils.Node {
+	route := []utils.Node{}
+	for _, point := range object.Polyline {
+		route = append(route, utils.Node{
+			X: int(math.Floor((object.X + point.X) / config.TileSize)),
+			Y: int(math.Floor((object.Y + point.Y) / config.TileSize)),
+		})
+	}
+	return route
+}
+
+// NpcID is the id of the npc spawned from the object, it has to stay the
+// same between runs for saves
+func NpcID(levelName string, object assets.Object) string {
+	return levelName + ":" + strconv.Itoa(object.ID)
+}
+
+// spawnNpcs creates the npcs of a freshly loaded level, ones that already
+// exist keep their state
+func (w *World) spawnNpcs(level *Level) error {
+	for _, object := range level.NpcSpawns {
+		id := NpcID(level.Name, object)
+		if _, ok := w.Npcs[id]; ok {
+			continue
+		}
+		npc, err := w.npcFromObject(level, object)
+		if err != nil {
+			return fmt.Errorf("Npc %s: %w", id, err)
+		}
+		w.Npcs[id] = npc
+		if npc.Hostile {
+			w.addEnemy(id, npc, object)
+		}
+		node := spriteNode(npc)
+		if level.TileOccupant(&node) == nil {
+			level.SetTileOccupied(npc, node.X, node.Y)
+		}
+	}
+	return nil
+}
+
+// npcFromObject reads the class, dialogue, behaviour and schedule properties.
+// The behaviour property is the kind, area, route, target and distance go
+// with it. The schedule is "hour kind [area|route|target], ..." like
+// "6 wander market, 20 patrol night_watch, 23 idle".
+func (w *World) npcFromObject(level *Level, object assets.Object) (*entities.Npc, error) {
+	if npcAnimations == nil {
+		//TODO put into assets
+		animations, err := entities.LoadAnimations(npcClipsPath)
+		if err != nil {
+			return nil, err
+		}
+		npcAnimations = animations
+	}
+
+	className, ok := assets.PropertyString(object.Properties, "class")
+	if !ok {
+		className = "villager"
+	}
+	class, ok := w.Classes[className]
+	if !ok {
+		return nil, fmt.Errorf("Unknown class %s", className)
+	}
+	home := utils.Node{X: int(math.Floor(object.X / config.TileSize)), Y: int(math.Floor(object.Y / config.TileSize))}
+	npc := &entities.Npc{
+		Sprite: entities.NewAnimatedSprite(npcAnimations, float64(home.X), float64(home.Y), 0),
+		Character: entities.Character{
+			Id:    NpcID(level.Name, object),
+			Stats: stats.New(class),
+		},
+		LevelName: level.Name,
+		Home:      home,
+		State:     entities.BehaviourState{Active: -1},
+	}
+	npc.Dialogue, _ = assets.PropertyString(object.Properties, "dialogue")
+	npc.Hostile, _ = assets.PropertyBool(object.Properties, "hostile")
+
+	kind, _ := assets.PropertyString(object.Properties, "behaviour")
+	arg := ""
+	for _, name := range []string{"area", "route", "target"} {
+		if value, ok := assets.PropertyString(object.Properties, name); ok {
+			arg = value
+		}
+	}
+	behaviour, err := level.parseBehaviour(kind, arg)
+	if err != nil {
+		return nil, err
+	}
+	if distance, ok := assets.PropertyFloat(object.Properties, "distance"); ok {
+		behaviour.Distance = distance
+	}
+	npc.Behaviour = behaviour
+
+	schedule, _ := assets.PropertyString(object.Properties, "schedule")
+	npc.Schedule, err = level.parseSchedule(schedule, behaviour.Distance)
+	if err != nil {
+		return nil, err
+	}
+	return npc, nil
+}
+
+// parseBehaviour checks that the area or route the behaviour needs exists
+func (l *Level) parseBehaviour(kind string, arg string) (entities.Behaviour, error) {
+	behaviour := entities.Behaviour{Kind: entities.BehaviourKind(kind), Distance: defaultBehaviourDistance}
+	switch behaviour.Kind {
+	case "", entities.Idle:
+		behaviour.Kind = entities.Idle
+	case entities.Wander:
+		if arg != "" && l.AreaByName(arg) == nil {
+			return behaviour, fmt.Errorf("Unknown area %s", arg)
+		}
+		behaviour.Area = arg
+	case entities.Patrol:
+		route, ok := l.Routes[arg]
+		if !ok || len(route) == 0 {
+			return behaviour, fmt.Errorf("Unknown route %s", arg)
+		}
+		behaviour.Route = route
+	case entities.Follow, entities.Flee:
+		if arg == "" {
+			arg = "party"
+		}
+		behaviour.Target = arg
+	default:
+		return behaviour, fmt.Errorf("Unknown behaviour %s", kind)
+	}
+	return behaviour, nil
+}
+
+func (l *Level) parseSchedule(schedule string, distance float64) ([]entities.ScheduleEntry, error) {
+	entries := []entities.ScheduleEntry{}
+	for _, part := range strings.Split(schedule, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("Bad schedule entry %q", part)
+		}
+		hour, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || hour < 0 || hour >= 24 {
+			return nil, fmt.Errorf("Bad schedule hour %q", fields[0])
+		}
+		arg := ""
+		if len(fields) == 3 {
+			arg = fields[2]
+		}
+		behaviour, err := l.parseBehaviour(fields[1], arg)
+		if err != nil {
+			return nil, err
+		}
+		behaviour.Distance = distance
+		entries = append(entries, entities.ScheduleEntry{Hour: hour, Behaviour: behaviour})
+	}
+	entities.SortSchedule(entries)
+	return entries, nil
+}
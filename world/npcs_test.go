@@ -0,0 +1,170 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/enemy"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/utils"
+	"reflect"
+	"testing"
+)
+
+// behaviourLevel has a market area and a route along the top row
+func behaviourLevel() *Level {
+	level := openLevel(20, 20)
+	level.Areas = append(level.Areas, Area{Name: "market", Rect: utils.RectangleCollision{Minx: 0, Miny: 0, Maxx: 64, Maxy: 64}})
+	level.Routes["walls"] = []utils.Node{{X: 2, Y: 2}, {X: 8, Y: 2}, {X: 14, Y: 2}}
+	return level
+}
+
+func TestParseBehaviour(t *testing.T) {
+	level := behaviourLevel()
+	cases := []struct {
+		kind, arg string
+		want      entities.Behaviour
+	}{
+		{"", "", entities.Behaviour{Kind: entities.Idle}},
+		{"idle", "", entities.Behaviour{Kind: entities.Idle}},
+		{"wander", "", entities.Behaviour{Kind: entities.Wander}},
+		{"wander", "market", entities.Behaviour{Kind: entities.Wander, Area: "market"}},
+		{"follow", "", entities.Behaviour{Kind: entities.Follow, Target: "party"}},
+		{"flee", "wolf", entities.Behaviour{Kind: entities.Flee, Target: "wolf"}},
+	}
+	for _, c := range cases {
+		got, err := level.parseBehaviour(c.kind, c.arg)
+		if err != nil {
+			t.Fatalf("%s %s: %v", c.kind, c.arg, err)
+		}
+		c.want.Distance = defaultBehaviourDistance
+		if got.Kind != c.want.Kind || got.Area != c.want.Area || got.Target != c.want.Target || got.Distance != c.want.Distance {
+			t.Fatalf("%s %s gives %+v, want %+v", c.kind, c.arg, got, c.want)
+		}
+	}
+	patrol, err := level.parseBehaviour("patrol", "walls")
+	if err != nil || len(patrol.Route) != 3 {
+		t.Fatalf("Patrol %+v, %v", patrol, err)
+	}
+
+	for _, bad := range [][2]string{{"dance", ""}, {"wander", "harbour"}, {"patrol", "gate"}, {"patrol", ""}} {
+		if _, err := level.parseBehaviour(bad[0], bad[1]); err == nil {
+			t.Fatalf("%s %s: no error", bad[0], bad[1])
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	level := behaviourLevel()
+	schedule, err := level.parseSchedule("22 idle, 6 wander market,18.5 patrol walls,", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		hour float64
+		kind entities.BehaviourKind
+	}{{6, entities.Wander}, {18.5, entities.Patrol}, {22, entities.Idle}}
+	if len(schedule) != len(want) {
+		t.Fatalf("Schedule %+v", schedule)
+	}
+	for i, entry := range schedule {
+		if entry.Hour != want[i].hour || entry.Behaviour.Kind != want[i].kind || entry.Behaviour.Distance != 5 {
+			t.Fatalf("Entry %d is %+v, want %v %s", i, entry, want[i].hour, want[i].kind)
+		}
+	}
+
+	// the night entry lasts until the morning one
+	npc := &entities.Npc{Schedule: schedule}
+	if behaviour, _ := npc.BehaviourAt(2); behaviour.Kind != entities.Idle {
+		t.Fatalf("At 2 the npc does %s", behaviour.Kind)
+	}
+
+	empty, err := level.parseSchedule("", 3)
+	if err != nil || len(empty) != 0 {
+		t.Fatalf("Empty schedule %+v, %v", empty, err)
+	}
+	for _, bad := range []string{"6", "6 wander market now", "24 idle", "-1 idle", "noon idle", "6 dance", "6 patrol gate"} {
+		if _, err := level.parseSchedule(bad, 3); err == nil {
+			t.Fatalf("%q: no error", bad)
+		}
+	}
+}
+
+func TestPatrolSkipsBlockedNode(t *testing.T) {
+	level := behaviourLevel()
+	// a building went up over the second route tile
+	level.AddBuilding(assets.Object{ID: 1, X: 8 * config.TileSize, Y: 2 * config.TileSize, Width: config.TileSize, Height: config.TileSize})
+	w := &World{Clock: NewGameClock(12), Enemies: enemy.NewDirector(1, config.EnemyThinkTicks, config.EnemyAlertRadius)}
+	paths := NewPathSystem(1)
+	defer paths.Close()
+
+	npc := &entities.Npc{
+		Sprite:    &entities.CircleSprite{X: 2, Y: 2},
+		Behaviour: entities.Behaviour{Kind: entities.Patrol, Route: level.Routes["walls"]},
+		State:     entities.BehaviourState{Active: -1},
+	}
+	w.UpdateBehaviour(npc, level, paths, nil)
+	drain(t, paths)
+	if npc.State.RouteIndex != 2 || len(npc.Path) == 0 {
+		t.Fatalf("Route index %d, path %v", npc.State.RouteIndex, npc.Path)
+	}
+	if goal := npc.Path[len(npc.Path)-1]; goal != (utils.Node{X: 14, Y: 2}) {
+		t.Fatalf("Walking to %v", goal)
+	}
+
+	// with every other tile covered it stays where it is
+	level.AddBuilding(assets.Object{ID: 2, X: 14 * config.TileSize, Y: 2 * config.TileSize, Width: config.TileSize, Height: config.TileSize})
+	npc.SetPath(nil)
+	npc.State = entities.BehaviourState{Active: -1}
+	w.UpdateBehaviour(npc, level, paths, nil)
+	drain(t, paths)
+	if len(npc.Path) != 0 {
+		t.Fatalf("Walking %v with nowhere to go", npc.Path)
+	}
+}
+
+func TestNpcDecisionsRepeat(t *testing.T) {
+	type decision struct {
+		id         string
+		x, y       float64
+		wait, path int
+		routeIndex int
+	}
+	// both worlds come from the same seed
+	run := func() [][]decision {
+		w, party := testWorld(t)
+		paths := NewPathSystem(4)
+		defer paths.Close()
+		ticks := [][]decision{}
+		for i := 0; i < 600; i++ {
+			w.Clock.Update()
+			if err := w.UpdateNpcs(paths, party); err != nil {
+				t.Fatal(err)
+			}
+			w.UpdateEnemies(paths, party)
+			drain(t, paths)
+			tick := []decision{}
+			for _, id := range w.SortedNpcIDs() {
+				npc := w.Npcs[id]
+				tick = append(tick, decision{id, npc.GetX(), npc.GetY(), npc.State.Wait, len(npc.Path), npc.State.RouteIndex})
+			}
+			ticks = append(ticks, tick)
+		}
+		return ticks
+	}
+
+	first := run()
+	if got := run(); !reflect.DeepEqual(got, first) {
+		for i := range first {
+			if !reflect.DeepEqual(got[i], first[i]) {
+				t.Fatalf("Tick %d differs:\n%+v\n%+v", i, first[i], got[i])
+			}
+		}
+	}
+	moved := false
+	for i, d := range first[len(first)-1] {
+		moved = moved || d.x != first[0][i].x || d.y != first[0][i].y
+	}
+	if !moved {
+		t.Fatal("Nobody did anything")
+	}
+}
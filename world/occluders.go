@@ -0,0 +1,71 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/utils"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+var whitePixel = func() *ebiten.Image {
+	image := ebiten.NewImage(1, 1)
+	image.Fill(color.White)
+	return image
+}()
+
+// drawOccluders draws the screen sized mask the lighting shader casts shadows
+// with, footprints of the buildings go to red and their images to green
+func (l *Level) drawOccluders(target *ebiten.Image, cam *config.Camera, gameAssets *assets.Assets) {
+	target.Clear()
+
+	for _, object := range l.Obstacles["buildings"] {
+		image, opts, _ := l.tileObjectDraw(gameAssets, cam, object)
+		if image == nil {
+			continue
+		}
+		opts.ColorScale.Scale(0, 1, 0, 1)
+		opts.Blend = ebiten.BlendLighter
+		target.DrawImage(image, opts)
+	}
+
+	path := vector.Path{}
+	for _, shape := range l.CollisionShapes() {
+		outline := shapeOutline(shape)
+		for i, point := range outline {
+			x, y := cam.WorldToScreen(point.X, point.Y)
+			if i == 0 {
+				path.MoveTo(float32(x), float32(y))
+			} else {
+				path.LineTo(float32(x), float32(y))
+			}
+		}
+		path.Close()
+	}
+	vertices, indices := path.AppendVerticesAndIndicesForFilling(nil, nil)
+	for i := range vertices {
+		vertices[i].SrcX, vertices[i].SrcY = 0.5, 0.5
+		vertices[i].ColorR, vertices[i].ColorG, vertices[i].ColorB, vertices[i].ColorA = 1, 0, 0, 1
+	}
+	opts := &ebiten.DrawTrianglesOptions{
+		Blend:    ebiten.BlendLighter,
+		FillRule: ebiten.FillRuleNonZero,
+	}
+	target.DrawTriangles(vertices, indices, whitePixel, opts)
+}
+
+// shapeOutline returns the corners of a collision shape in world pixels
+func shapeOutline(shape utils.CollisionShape) []utils.Point {
+	switch s := shape.(type) {
+	case *utils.RectangleCollision:
+		return s.Corners()
+	case *utils.PolygonCollision:
+		return s.Points
+	case *utils.EllipseCollision:
+		return s.Polygon(ellipseSegments)
+	}
+	bounds := shape.Bounds()
+	return bounds.Corners()
+}
@@ -0,0 +1,190 @@
+package world
+
+import (
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/utils"
+	"sync"
+	"sync/atomic"
+)
+
+// PathOwner is anything that can walk a path (PCharacters, NPCs)
+type PathOwner interface {
+	SetPath(path []utils.Node)
+}
+
+type PathRequest struct {
+	ID    uint64
+	Owner PathOwner
+	Start utils.Node
+	End   utils.Node
+	Mode  PathMode
+	Grid  *NavGrid
+
+	cancelled atomic.Bool
+	done      bool
+	path      []utils.Node
+}
+
+func (r *PathRequest) Cancel() {
+	r.cancelled.Store(true)
+}
+
+func (r *PathRequest) Cancelled() bool {
+	return r.cancelled.Load()
+}
+
+// PathSystem computes paths on worker goroutines, results are handed back to
+// the owners in Update on the game goroutine, always in the request order so
+// the outcome doesnt depend on the number of workers
+type PathSystem struct {
+	PathFinder *PathFinder
+
+	jobs    chan *PathRequest
+	quit    chan struct{}
+	mu      sync.Mutex
+	nextID  uint64
+	pending []*PathRequest //ordered by ID
+	byOwner map[PathOwner]*PathRequest
+}
+
+func NewPathSystem(workers int) *PathSystem {
+	if workers < 1 {
+		workers = 1
+	}
+	ps := &PathSystem{
+		PathFinder: &PathFinder{},
+		jobs:       make(chan *PathRequest, 256),
+		quit:       make(chan struct{}),
+		byOwner:    map[PathOwner]*PathRequest{},
+	}
+	for i := 0; i < workers; i++ {
+		go ps.worker()
+	}
+	return ps
+}
+
+func (ps *PathSystem) worker() {
+	for {
+		select {
+		case <-ps.quit:
+			return
+		case request := <-ps.jobs:
+			var path []utils.Node
+			if !request.Cancelled() {
+				reversedpath := ps.findPath(request)
+				if reversedpath != nil {
+					path = ForwardPath(reversedpath)
+				}
+			}
+			ps.mu.Lock()
+			request.path = path
+			request.done = true
+			ps.mu.Unlock()
+		}
+	}
+}
+
+// findPath goes through the clusters when the ends are far apart, the flat
+// search is cheaper on short ones
+func (ps *PathSystem) findPath(request *PathRequest) []utils.Node {
+	dx := request.End.X - request.Start.X
+	dy := request.End.Y - request.Start.Y
+	if max(dx, -dx, dy, -dy) >= hierarchyDistance {
+		if hierarchy := request.Grid.Hierarchy(); hierarchy != nil {
+			return hierarchy.FindPath(request.Start, request.End, request.Mode, request.Cancelled)
+		}
+	}
+	return ps.PathFinder.FindPath(request.Grid, request.Start, request.End, request.Mode, request.Cancelled)
+}
+
+// RequestPath queues a search on the current walkability of the level, an
+// older request of the same owner is cancelled
+func (ps *PathSystem) RequestPath(owner PathOwner, level *Level, start utils.Node, end utils.Node, mode PathMode) *PathRequest {
+	return ps.requestPath(owner, level.NavGrid(), start, end, mode)
+}
+
+// RequestPathAround is RequestPath that also goes around every occupied tile,
+// used when the owner got stuck behind another entity
+func (ps *PathSystem) RequestPathAround(owner PathOwner, self entities.Sprite, level *Level, start utils.Node, end utils.Node, mode PathMode) *PathRequest {
+	return ps.requestPath(owner, level.NavGridAvoiding(self), start, end, mode)
+}
+
+func (ps *PathSystem) requestPath(owner PathOwner, grid *NavGrid, start utils.Node, end utils.Node, mode PathMode) *PathRequest {
+	ps.mu.Lock()
+	if previous, ok := ps.byOwner[owner]; ok {
+		previous.Cancel()
+	}
+	ps.nextID++
+	request := &PathRequest{
+		ID:    ps.nextID,
+		Owner: owner,
+		Start: start,
+		End:   end,
+		Mode:  mode,
+		Grid:  grid,
+	}
+	ps.byOwner[owner] = request
+	ps.pending = append(ps.pending, request)
+	ps.mu.Unlock()
+
+	select {
+	case ps.jobs <- request:
+	default:
+		// queue is full, dont block the game loop
+		go func() {
+			select {
+			case ps.jobs <- request:
+			case <-ps.quit:
+			}
+		}()
+	}
+	return request
+}
+
+// CancelOwner drops the pending request of the owner, if any
+func (ps *PathSystem) CancelOwner(owner PathOwner) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if request, ok := ps.byOwner[owner]; ok {
+		request.Cancel()
+		delete(ps.byOwner, owner)
+	}
+}
+
+// Update delivers finished paths, called once per tick
+func (ps *PathSystem) Update() {
+	ps.mu.Lock()
+	delivered := []*PathRequest{}
+	remaining := ps.pending[:0]
+	blocked := false
+	for _, request := range ps.pending {
+		if !blocked && request.done {
+			delivered = append(delivered, request)
+			continue
+		}
+		if !blocked && request.Cancelled() {
+			// nobody waits for it, the worker result gets thrown away
+			continue
+		}
+		blocked = true
+		remaining = append(remaining, request)
+	}
+	ps.pending = remaining
+	for _, request := range delivered {
+		if ps.byOwner[request.Owner] == request {
+			delete(ps.byOwner, request.Owner)
+		}
+	}
+	ps.mu.Unlock()
+
+	for _, request := range delivered {
+		if request.Cancelled() {
+			continue
+		}
+		request.Owner.SetPath(request.path)
+	}
+}
+
+func (ps *PathSystem) Close() {
+	close(ps.quit)
+}
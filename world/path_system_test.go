@@ -0,0 +1,135 @@
+package world
+
+import (
+	"bilydaniel/rpg/utils"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type delivery struct {
+	owner string
+	path  []utils.Node
+}
+
+// recordingOwner writes every path it gets into the shared log
+type recordingOwner struct {
+	name string
+	log  *[]delivery
+}
+
+func (o *recordingOwner) SetPath(path []utils.Node) {
+	*o.log = append(*o.log, delivery{owner: o.name, path: path})
+}
+
+// drain updates until every request is delivered or dropped
+func drain(t *testing.T, ps *PathSystem) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		ps.Update()
+		ps.mu.Lock()
+		left := len(ps.pending)
+		ps.mu.Unlock()
+		if left == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("Paths never arrived")
+}
+
+// waitDone waits until a worker finished the request
+func waitDone(t *testing.T, ps *PathSystem, request *PathRequest) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		ps.mu.Lock()
+		done := request.done
+		ps.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("Request never finished")
+}
+
+func TestPathSystemWorkers(t *testing.T) {
+	level := loadLevel(t, "level_1")
+	grid := level.NavGrid()
+	r := rand.New(rand.NewSource(1))
+	tile := func() utils.Node {
+		return walkableNear(t, grid, r.Intn(grid.Width), r.Intn(grid.Height))
+	}
+	type route struct {
+		owner      int
+		start, end utils.Node
+		mode       PathMode
+	}
+	// some owners ask more than once, only their last request counts
+	routes := []route{}
+	for i := 0; i < 60; i++ {
+		routes = append(routes, route{owner: r.Intn(20), start: tile(), end: tile(), mode: PathMode(r.Intn(3))})
+	}
+
+	run := func(workers int) []delivery {
+		ps := NewPathSystem(workers)
+		defer ps.Close()
+		log := []delivery{}
+		owners := []*recordingOwner{}
+		for i := 0; i < 20; i++ {
+			owners = append(owners, &recordingOwner{name: fmt.Sprint(i), log: &log})
+		}
+		for i, route := range routes {
+			ps.RequestPath(owners[route.owner], level, route.start, route.end, route.mode)
+			if i%20 == 19 {
+				// a few ticks worth of orders, then everything arrives
+				drain(t, ps)
+			}
+		}
+		return log
+	}
+
+	single := run(1)
+	if len(single) == 0 {
+		t.Fatal("Nothing delivered")
+	}
+	for _, workers := range []int{2, 4, 8} {
+		if got := run(workers); !reflect.DeepEqual(got, single) {
+			t.Fatalf("%d workers delivered %d paths differently than 1 worker (%d)", workers, len(got), len(single))
+		}
+	}
+}
+
+func TestPathSystemReplaced(t *testing.T) {
+	level := openLevel(20, 20)
+	ps := NewPathSystem(4)
+	defer ps.Close()
+	log := []delivery{}
+	owner := &recordingOwner{name: "red", log: &log}
+
+	// the first one is already computed when the new order comes
+	first := ps.RequestPath(owner, level, utils.Node{X: 0, Y: 0}, utils.Node{X: 19, Y: 0}, PathAStar)
+	waitDone(t, ps, first)
+	ps.RequestPath(owner, level, utils.Node{X: 0, Y: 0}, utils.Node{X: 0, Y: 19}, PathAStar)
+	drain(t, ps)
+
+	if len(log) != 1 {
+		t.Fatalf("Delivered %d paths, want 1", len(log))
+	}
+	if goal := log[0].path[len(log[0].path)-1]; goal != (utils.Node{X: 0, Y: 19}) {
+		t.Fatalf("Got the replaced path to %v", goal)
+	}
+
+	// cancelled without a new one
+	log = log[:0]
+	ps.RequestPath(owner, level, utils.Node{X: 0, Y: 0}, utils.Node{X: 19, Y: 19}, PathAStar)
+	ps.CancelOwner(owner)
+	drain(t, ps)
+	if len(log) != 0 {
+		t.Fatalf("Cancelled path delivered %v", log)
+	}
+}
@@ -2,22 +2,80 @@ package world
 
 import (
 	"bilydaniel/rpg/utils"
+	"container/heap"
 	"math"
-	"sort"
 )
 
 type Tile struct {
 	ID int
 	utils.Node
-	G, H, F  float64
-	Parent   *Tile
 	Walkable bool //TODO change to something more complex, gonna need to check for building, enemies, etc.
+	Cost     float64
+	Terrain  Terrain
 }
 
+type PathMode int
+
+const (
+	PathAStar PathMode = iota
+	// PathSmoothed is A* with string pulling afterwards
+	PathSmoothed
+	// PathThetaStar gives any angle paths straight from the search
+	PathThetaStar
+)
+
+// lineEpsilon keeps rounding from choosing a bent path over an equally long
+// straight one
+const lineEpsilon = 0.0001
+
 type PathFinder struct {
 	CollisionShapes []utils.CollisionShape
 }
 
+// searchNode is the per-search scratch state of one tile, kept outside of the
+// level grid so searches dont touch the shared tiles
+type searchNode struct {
+	G      float64
+	Parent int
+	Opened bool
+	Closed bool
+}
+
+type openItem struct {
+	index int
+	f, h  float64
+	seq   int
+}
+
+// openSet is a binary min heap ordered by F, ties are broken by H and then
+// by insertion order so the results are deterministic
+type openSet []openItem
+
+func (o openSet) Len() int { return len(o) }
+func (o openSet) Less(i, j int) bool {
+	if o[i].f != o[j].f {
+		return o[i].f < o[j].f
+	}
+	if o[i].h != o[j].h {
+		return o[i].h < o[j].h
+	}
+	return o[i].seq < o[j].seq
+}
+func (o openSet) Swap(i, j int)       { o[i], o[j] = o[j], o[i] }
+func (o *openSet) Push(x interface{}) { *o = append(*o, x.(openItem)) }
+func (o *openSet) Pop() interface{} {
+	old := *o
+	item := old[len(old)-1]
+	*o = old[:len(old)-1]
+	return item
+}
+
+var neighborOffsets = [8][2]int{
+	{-1, -1}, {-1, 0}, {-1, 1},
+	{0, -1}, {0, 1},
+	{1, -1}, {1, 0}, {1, 1},
+}
+
 func (pf *PathFinder) Distance(start utils.Node, end utils.Node) float64 {
 	dx := start.X - end.X
 	dy := start.Y - end.Y
@@ -25,94 +83,248 @@ func (pf *PathFinder) Distance(start utils.Node, end utils.Node) float64 {
 	return math.Hypot(float64(dx), float64(dy))
 }
 
-func (pf *PathFinder) ReconstructPath(node *Tile) []utils.Node {
-	currentNode := node
+// ReconstructPath returns the path from end back to start (reversed)
+func (pf *PathFinder) ReconstructPath(nodes []searchNode, bounds Bounds, end int) []utils.Node {
 	path := []utils.Node{}
 
-	for currentNode != nil {
-		path = append(path, currentNode.Node)
-		currentNode = currentNode.Parent
+	for current := end; current != -1; current = nodes[current].Parent {
+		path = append(path, bounds.Node(current))
 	}
 	return path
 }
+
 func (level *Level) GetNeighbors(node utils.Node) []*Tile {
 	neighbors := []*Tile{}
 
-	offsets := [][]int{
-		{-1, -1}, {-1, 0}, {-1, 1},
-		{0, -1}, {0, 1},
-		{1, -1}, {1, 0}, {1, 1},
-	}
-
-	for _, offset := range offsets {
+	for _, offset := range neighborOffsets {
 		offsetx := node.X + offset[0]
 		offsety := node.Y + offset[1]
 
-		if offsetx >= 0 && offsety >= 0 && offsetx < level.Width && offsety < level.Height {
+		if level.InBounds(offsetx, offsety) {
 			neighbors = append(neighbors, level.Grid[offsety][offsetx])
 		}
 	}
 	return neighbors
 }
 
+// AlfaStar returns the path from end to start (reversed, start included),
+// nil if there is no path
 func (pf *PathFinder) AlfaStar(level Level, start utils.Node, end utils.Node) []utils.Node {
-	startNode := level.Grid[start.Y][start.X]
-	endNode := level.Grid[end.Y][end.X]
-
-	openSet := []*Tile{}
-	closedSet := map[*Tile]bool{}
-
-	startNode.G = 0
-	startNode.H = pf.Distance(start, end)
-	startNode.F = startNode.G + startNode.H
-	startNode.Parent = nil
-
-	openSet = append(openSet, startNode)
-	for len(openSet) > 0 {
-		sort.Slice(openSet, func(i, j int) bool {
-			return openSet[i].F < openSet[j].F
-		})
-
-		current := openSet[0]
-		if current == endNode {
-			return pf.ReconstructPath(current)
+	return pf.FindPath(level.NavGrid(), start, end, PathAStar, nil)
+}
+
+func (pf *PathFinder) ThetaStar(level Level, start utils.Node, end utils.Node) []utils.Node {
+	return pf.FindPath(level.NavGrid(), start, end, PathThetaStar, nil)
+}
+
+// FindPath searches over a walkability snapshot, cancelled is polled every few
+// hundred expansions and can be nil. The result is reversed like AlfaStar.
+func (pf *PathFinder) FindPath(grid *NavGrid, start utils.Node, end utils.Node, mode PathMode, cancelled func() bool) []utils.Node {
+	path, _ := pf.SearchBounded(grid, grid.Bounds(), start, end, mode, cancelled)
+	return path
+}
+
+// SearchBounded is FindPath that never leaves the bounds, it also returns the
+// cost of the path
+func (pf *PathFinder) SearchBounded(grid *NavGrid, bounds Bounds, start utils.Node, end utils.Node, mode PathMode, cancelled func() bool) ([]utils.Node, float64) {
+	bounds = bounds.Intersect(grid.Bounds())
+	if !bounds.Contains(start.X, start.Y) || !bounds.Contains(end.X, end.Y) {
+		return nil, 0
+	}
+	if !grid.IsWalkable(end.X, end.Y) {
+		// the whole map would get searched just to find out there is no path
+		return nil, 0
+	}
+
+	nodes := make([]searchNode, bounds.W*bounds.H)
+	startIndex := bounds.Index(start.X, start.Y)
+	endIndex := bounds.Index(end.X, end.Y)
+
+	open := &openSet{}
+	seq := 0
+	expanded := 0
+
+	nodes[startIndex] = searchNode{G: 0, Parent: -1, Opened: true}
+	h := pf.Distance(start, end) * grid.MinCost
+	heap.Push(open, openItem{index: startIndex, f: h, h: h, seq: seq})
+
+	for open.Len() > 0 {
+		item := heap.Pop(open).(openItem)
+		current := &nodes[item.index]
+		if current.Closed {
+			// stale entry, the node was already expanded with a better G
+			continue
+		}
+		if item.index == endIndex {
+			path := pf.ReconstructPath(nodes, bounds, endIndex)
+			if mode == PathSmoothed {
+				path = pf.SmoothPath(grid, path)
+			}
+			return path, current.G
 		}
+		current.Closed = true
 
-		openSet = openSet[1:]
-		closedSet[current] = true
+		expanded++
+		if cancelled != nil && expanded%256 == 0 && cancelled() {
+			return nil, 0
+		}
+
+		currentNode := bounds.Node(item.index)
+		for _, offset := range neighborOffsets {
+			neighbor := utils.Node{X: currentNode.X + offset[0], Y: currentNode.Y + offset[1]}
+			if !bounds.Contains(neighbor.X, neighbor.Y) {
+				continue
+			}
 
-		neighbors := level.GetNeighbors(current.Node)
-		for _, neighbor := range neighbors {
+			neighborIndex := bounds.Index(neighbor.X, neighbor.Y)
+			next := &nodes[neighborIndex]
 			//TODO probably gonna need something more complex than walkable??
-			if closedSet[neighbor] || !neighbor.Walkable {
+			if next.Closed || !grid.Walkable[neighbor.Y*grid.Width+neighbor.X] {
 				continue
 			}
 
-			tentativeG := current.G + pf.Distance(current.Node, neighbor.Node)
-			// POSSIBLE UPGRADE FROM A* TO THETA*, DOESENT SEEM NEEDED
-			/*
-				if current.Parent != nil && pf.LineOfSight(current.Parent.Pos, neighbor.Pos) {
-					newG := current.Parent.G + pf.Distance(current.Parent.Pos, neighbor.Pos)
-					if newG < neighbor.G {
-						neighbor.Parent = current.Parent // Rewire the parent
-						neighbor.G = newG
-						neighbor.F = neighbor.G + neighbor.H
-					}
+			if mode != PathAStar && offset[0] != 0 && offset[1] != 0 &&
+				(!grid.IsWalkable(neighbor.X, currentNode.Y) || !grid.IsWalkable(currentNode.X, neighbor.Y)) {
+				// any angle paths dont go past blocked corners, same as their lines
+				continue
+			}
+
+			parent := item.index
+			tentativeG := current.G + pf.Distance(currentNode, neighbor)*grid.StepCost(currentNode, neighbor)
+			if mode == PathThetaStar && current.Parent != -1 {
+				parentNode := bounds.Node(current.Parent)
+				if cost, ok := pf.LineCost(grid, parentNode, neighbor); ok && nodes[current.Parent].G+cost <= tentativeG+lineEpsilon {
+					parent = current.Parent
+					tentativeG = nodes[parent].G + cost
 				}
-			*/
+			}
 
-			sliceContains := utils.SliceContains(openSet, neighbor)
-			if !sliceContains || tentativeG < neighbor.G {
-				neighbor.Parent = current
-				neighbor.G = tentativeG
-				neighbor.H = pf.Distance(neighbor.Node, endNode.Node)
-				neighbor.F = neighbor.G + neighbor.H
+			if !next.Opened || tentativeG < next.G {
+				next.Opened = true
+				next.Parent = parent
+				next.G = tentativeG
 
-				if !sliceContains {
-					openSet = append(openSet, neighbor)
-				}
+				seq++
+				h := pf.Distance(neighbor, end) * grid.MinCost
+				heap.Push(open, openItem{index: neighborIndex, f: tentativeG + h, h: h, seq: seq})
+			}
+		}
+	}
+	return nil, 0
+}
+
+// walkLine visits every tile the segment between the tile centers touches,
+// corners included, so the paths never squeeze diagonally between two blocks.
+// Stops when visit returns false.
+func walkLine(start utils.Node, end utils.Node, visit func(x, y int) bool) bool {
+	x, y := start.X, start.Y
+	dx := end.X - start.X
+	dy := end.Y - start.Y
+
+	stepx, stepy := 1, 1
+	if dx < 0 {
+		stepx = -1
+		dx = -dx
+	}
+	if dy < 0 {
+		stepy = -1
+		dy = -dy
+	}
+
+	// error is kept in doubled units to stay in integers
+	err := dx - dy
+	dx *= 2
+	dy *= 2
+	for n := 1 + dx/2 + dy/2; n > 0; n-- {
+		if !visit(x, y) {
+			return false
+		}
+		if err > 0 {
+			x += stepx
+			err -= dy
+		} else if err < 0 {
+			y += stepy
+			err += dx
+		} else {
+			// the line goes exactly through a corner, both side tiles count
+			if n > 1 && (!visit(x+stepx, y) || !visit(x, y+stepy)) {
+				return false
+			}
+			x += stepx
+			y += stepy
+			err += dx - dy
+			n--
+		}
+	}
+	return true
+}
+
+func (pf *PathFinder) LineOfSight(grid *NavGrid, start utils.Node, end utils.Node) bool {
+	return walkLine(start, end, grid.IsWalkable)
+}
+
+// LineCost is the cost of walking straight between the tiles, the distance
+// times the average cost of the tiles on the way, false when blocked. Steps
+// to a neighbor cost the same as in the search.
+func (pf *PathFinder) LineCost(grid *NavGrid, start utils.Node, end utils.Node) (float64, bool) {
+	total := 0.0
+	count := 0
+	ok := walkLine(start, end, func(x, y int) bool {
+		if !grid.IsWalkable(x, y) {
+			return false
+		}
+		total += grid.Cost[y*grid.Width+x]
+		count++
+		return true
+	})
+	if !ok || count == 0 {
+		return 0, false
+	}
+	if max(abs(end.X-start.X), abs(end.Y-start.Y)) == 1 {
+		// a single step costs what it costs in the search
+		return pf.Distance(start, end) * grid.StepCost(start, end), true
+	}
+	return pf.Distance(start, end) * total / float64(count), true
+}
+
+// SmoothPath removes every node that can be skipped with a straight line that
+// is not more expensive, works on paths in any order
+func (pf *PathFinder) SmoothPath(grid *NavGrid, path []utils.Node) []utils.Node {
+	if len(path) <= 2 {
+		return path
+	}
+
+	smoothedPath := []utils.Node{path[0]}
+	current := 0
+
+	for current < len(path)-1 {
+		next := current + 1
+		walked, _ := pf.LineCost(grid, path[current], path[next])
+
+		// look ahead to find the furthest visible node
+		for next+1 < len(path) {
+			step, _ := pf.LineCost(grid, path[next], path[next+1])
+			cost, ok := pf.LineCost(grid, path[current], path[next+1])
+			if !ok || cost > walked+step+lineEpsilon {
+				break
 			}
+			walked += step
+			next++
 		}
+
+		smoothedPath = append(smoothedPath, path[next])
+		current = next
 	}
-	return nil
+
+	return smoothedPath
+}
+
+// ForwardPath turns a reversed path into walking order without the start node
+func ForwardPath(reversedpath []utils.Node) []utils.Node {
+	path := []utils.Node{}
+	// starts at 1, dont need the 0th element (my own location)
+	for i := len(reversedpath) - 2; i >= 0; i-- {
+		path = append(path, reversedpath[i])
+	}
+	return path
 }
@@ -0,0 +1,278 @@
+package world
+
+import (
+	"bilydaniel/rpg/utils"
+	"container/heap"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+)
+
+var chdirOnce sync.Once
+
+// toRepoRoot makes the asset paths work, they are relative to the
+// repository root
+func toRepoRoot(tb testing.TB) {
+	tb.Helper()
+	chdirOnce.Do(func() {
+		err := os.Chdir("..")
+		if err != nil {
+			tb.Fatal(err)
+		}
+	})
+}
+
+// loadLevel loads a map the way the game does
+func loadLevel(tb testing.TB, name string) *Level {
+	tb.Helper()
+	toRepoRoot(tb)
+	level := InitLevel()
+	err := level.LoadLevel(name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return &level
+}
+
+// walkableNear is the closest walkable tile to x, y going outwards in rings
+func walkableNear(tb testing.TB, grid *NavGrid, x, y int) utils.Node {
+	tb.Helper()
+	for r := 0; r < max(grid.Width, grid.Height); r++ {
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				if grid.IsWalkable(x+dx, y+dy) {
+					return utils.Node{X: x + dx, Y: y + dy}
+				}
+			}
+		}
+	}
+	tb.Fatal("No walkable tile")
+	return utils.Node{}
+}
+
+// benchmarkRoutes are corner to corner and across the middle of the map
+func benchmarkRoutes(tb testing.TB, grid *NavGrid) [][2]utils.Node {
+	w, h := grid.Width-1, grid.Height-1
+	return [][2]utils.Node{
+		{walkableNear(tb, grid, 0, 0), walkableNear(tb, grid, w, h)},
+		{walkableNear(tb, grid, w, 0), walkableNear(tb, grid, 0, h)},
+		{walkableNear(tb, grid, 0, h/2), walkableNear(tb, grid, w, h/2)},
+	}
+}
+
+// asciiLevel is a level drawn in rows, # is blocked
+func asciiLevel(rows ...string) *Level {
+	level := openLevel(len(rows[0]), len(rows))
+	for y, row := range rows {
+		for x, c := range row {
+			level.Grid[y][x].Walkable = c != '#'
+		}
+	}
+	return level
+}
+
+// the shortest paths are unique so the search order cant change them, they
+// are what the sorted slice version returned
+func TestAlfaStarShapes(t *testing.T) {
+	cases := []struct {
+		rows       []string
+		start, end utils.Node
+		want       []utils.Node
+	}{
+		{
+			rows:  []string{"........", "........", "........"},
+			start: utils.Node{X: 0, Y: 1}, end: utils.Node{X: 7, Y: 1},
+			want: []utils.Node{{X: 7, Y: 1}, {X: 6, Y: 1}, {X: 5, Y: 1}, {X: 4, Y: 1}, {X: 3, Y: 1}, {X: 2, Y: 1}, {X: 1, Y: 1}, {X: 0, Y: 1}},
+		},
+		{
+			rows:  []string{"......", "......", "......", "......", "......", "......"},
+			start: utils.Node{X: 0, Y: 0}, end: utils.Node{X: 5, Y: 5},
+			want: []utils.Node{{X: 5, Y: 5}, {X: 4, Y: 4}, {X: 3, Y: 3}, {X: 2, Y: 2}, {X: 1, Y: 1}, {X: 0, Y: 0}},
+		},
+		{
+			// diagonal steps go past the wall corners
+			rows: []string{
+				".....",
+				"####.",
+				".....",
+				".####",
+				".....",
+			},
+			start: utils.Node{X: 0, Y: 0}, end: utils.Node{X: 0, Y: 4},
+			want: []utils.Node{{X: 0, Y: 4}, {X: 0, Y: 3}, {X: 1, Y: 2}, {X: 2, Y: 2}, {X: 3, Y: 2}, {X: 4, Y: 1}, {X: 3, Y: 0}, {X: 2, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 0}},
+		},
+		{
+			rows: []string{
+				"......",
+				".####.",
+				".#..#.",
+				".#.##.",
+				"......",
+			},
+			start: utils.Node{X: 2, Y: 2}, end: utils.Node{X: 5, Y: 0},
+			want: []utils.Node{{X: 5, Y: 0}, {X: 5, Y: 1}, {X: 5, Y: 2}, {X: 5, Y: 3}, {X: 4, Y: 4}, {X: 3, Y: 4}, {X: 2, Y: 3}, {X: 2, Y: 2}},
+		},
+		{
+			rows:  []string{"...", "...", "..."},
+			start: utils.Node{X: 1, Y: 1}, end: utils.Node{X: 1, Y: 1},
+			want: []utils.Node{{X: 1, Y: 1}},
+		},
+		{
+			rows:  []string{"..#..", "..#..", "..#.."},
+			start: utils.Node{X: 0, Y: 1}, end: utils.Node{X: 4, Y: 1},
+		},
+	}
+	pf := &PathFinder{}
+	for i, c := range cases {
+		got := pf.AlfaStar(*asciiLevel(c.rows...), c.start, c.end)
+		if !slices.Equal(got, c.want) {
+			t.Fatalf("Case %d: got %v, want %v", i, got, c.want)
+		}
+	}
+}
+
+func TestAnyAngleStraight(t *testing.T) {
+	level := openLevel(20, 20)
+	grid := level.NavGrid()
+	pf := &PathFinder{}
+	ends := []utils.Node{{X: 19, Y: 0}, {X: 7, Y: 3}, {X: 3, Y: 17}, {X: 19, Y: 19}, {X: 12, Y: 5}}
+	for _, mode := range []PathMode{PathSmoothed, PathThetaStar} {
+		for _, end := range ends {
+			path := pf.FindPath(grid, utils.Node{X: 0, Y: 0}, end, mode, nil)
+			if len(path) != 2 {
+				t.Fatalf("Mode %d to %v on open ground: %v", mode, end, path)
+			}
+		}
+	}
+}
+
+// checkerLevel has pillars whose corners touch diagonally, straight lines
+// through those corners would squeeze between two blocks
+func checkerLevel() *Level {
+	level := openLevel(24, 24)
+	for y := 2; y < 22; y += 2 {
+		for x := 2 + y%4/2; x < 22; x += 2 {
+			level.Grid[y][x].Walkable = false
+			level.Grid[y+1][x+1].Walkable = false
+		}
+	}
+	return level
+}
+
+func TestAnyAngleCorners(t *testing.T) {
+	level := checkerLevel()
+	grid := level.NavGrid()
+	pf := &PathFinder{}
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 200; i++ {
+		start := walkableNear(t, grid, r.Intn(24), r.Intn(24))
+		end := walkableNear(t, grid, r.Intn(24), r.Intn(24))
+		for _, mode := range []PathMode{PathSmoothed, PathThetaStar} {
+			path := pf.FindPath(grid, start, end, mode, nil)
+			for j := 1; j < len(path); j++ {
+				if !pf.LineOfSight(grid, path[j-1], path[j]) {
+					t.Fatalf("Mode %d from %v to %v cuts a corner between %v and %v", mode, start, end, path[j-1], path[j])
+				}
+			}
+		}
+	}
+}
+
+// keptOffCorners is the length of the shortest grid path that doesnt go past
+// blocked corners, a plain Dijkstra to check the searches against
+func keptOffCorners(grid *NavGrid, start utils.Node, end utils.Node) (float64, bool) {
+	dist := map[utils.Node]float64{start: 0}
+	done := map[utils.Node]bool{}
+	open := &openSet{}
+	heap.Push(open, openItem{index: start.Y*grid.Width + start.X})
+	for open.Len() > 0 {
+		item := heap.Pop(open).(openItem)
+		current := utils.Node{X: item.index % grid.Width, Y: item.index / grid.Width}
+		if done[current] {
+			continue
+		}
+		if current == end {
+			return item.f, true
+		}
+		done[current] = true
+		for _, offset := range neighborOffsets {
+			next := utils.Node{X: current.X + offset[0], Y: current.Y + offset[1]}
+			if !grid.IsWalkable(next.X, next.Y) || !grid.IsWalkable(next.X, current.Y) || !grid.IsWalkable(current.X, next.Y) {
+				continue
+			}
+			d := item.f + math.Hypot(float64(offset[0]), float64(offset[1]))*grid.StepCost(current, next)
+			if old, ok := dist[next]; !ok || d < old {
+				dist[next] = d
+				heap.Push(open, openItem{index: next.Y*grid.Width + next.X, f: d})
+			}
+		}
+	}
+	return 0, false
+}
+
+func TestAnyAngleNotLonger(t *testing.T) {
+	pf := &PathFinder{}
+	check := func(grid *NavGrid, start utils.Node, end utils.Node) {
+		shortest, ok := keptOffCorners(grid, start, end)
+		for _, mode := range []PathMode{PathSmoothed, PathThetaStar} {
+			path := pf.FindPath(grid, start, end, mode, nil)
+			if (path != nil) != ok {
+				t.Fatalf("Mode %d from %v to %v: path %v, reachable %v", mode, start, end, path, ok)
+			}
+			if !ok {
+				continue
+			}
+			if length := pathLength(t, grid, path); length > shortest+lineEpsilon {
+				t.Fatalf("Mode %d from %v to %v: %.2f, grid path %.2f", mode, start, end, length, shortest)
+			}
+		}
+	}
+
+	// walls with gaps, crossing the middle needs going round their ends
+	level := openLevel(30, 30)
+	for i := 4; i < 30; i += 6 {
+		for j := 0; j < 22; j++ {
+			level.Grid[j+(i/6%2)*8][i].Walkable = false
+			level.Grid[i][29-j-(i/6%2)*8].Walkable = false
+		}
+	}
+	grid := level.NavGrid()
+	r := rand.New(rand.NewSource(4))
+	for i := 0; i < 300; i++ {
+		check(grid, walkableNear(t, grid, r.Intn(30), r.Intn(30)), walkableNear(t, grid, r.Intn(30), r.Intn(30)))
+	}
+	level = loadLevel(t, "level_1")
+	grid = level.NavGrid()
+	for _, route := range benchmarkRoutes(t, grid) {
+		check(grid, route[0], route[1])
+	}
+}
+
+func BenchmarkFindPath(b *testing.B) {
+	level := loadLevel(b, "level_1")
+	grid := level.NavGrid()
+	routes := benchmarkRoutes(b, grid)
+	modes := []struct {
+		name string
+		mode PathMode
+	}{
+		{"AStar", PathAStar},
+		{"Smoothed", PathSmoothed},
+		{"ThetaStar", PathThetaStar},
+	}
+	for _, m := range modes {
+		b.Run(m.name, func(b *testing.B) {
+			pf := &PathFinder{}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				route := routes[i%len(routes)]
+				if pf.FindPath(grid, route[0], route[1], m.mode, nil) == nil {
+					b.Fatalf("No path from %v to %v", route[0], route[1])
+				}
+			}
+		})
+	}
+}
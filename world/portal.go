@@ -0,0 +1,67 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/utils"
+	"math"
+)
+
+// PortalsLayer is the Tiled object layer the portals are read from
+const PortalsLayer = "portals"
+
+// Portal is an area of a level that leads to a portal of another level, read
+// from a Tiled object named after the portal with the target-level and
+// target-portal properties
+type Portal struct {
+	Name         string
+	Level        string
+	Area         utils.RectangleCollision //world pixels
+	TargetLevel  string
+	TargetPortal string
+}
+
+func PortalFromObject(levelName string, object assets.Object) Portal {
+	portal := Portal{
+		Name:  object.Name,
+		Level: levelName,
+		Area: utils.RectangleCollision{
+			Minx: object.X,
+			Miny: object.Y,
+			Maxx: object.X + math.Max(object.Width, config.TileSize),
+			Maxy: object.Y + math.Max(object.Height, config.TileSize),
+		},
+	}
+	portal.TargetLevel, _ = assets.PropertyString(object.Properties, "target-level")
+	portal.TargetPortal, _ = assets.PropertyString(object.Properties, "target-portal")
+	return portal
+}
+
+// Contains tells if the middle of the tile is inside of the portal
+func (p Portal) Contains(node utils.Node) bool {
+	return tileInRect(node, p.Area)
+}
+
+func tileInRect(node utils.Node, rect utils.RectangleCollision) bool {
+	x := (float64(node.X) + 0.5) * config.TileSize
+	y := (float64(node.Y) + 0.5) * config.TileSize
+	return x >= rect.Minx && x < rect.Maxx && y >= rect.Miny && y < rect.Maxy
+}
+
+// Center is the tile in the middle of the portal
+func (p Portal) Center() utils.Node {
+	return utils.Node{
+		X: int((p.Area.Minx + p.Area.Maxx) / 2 / config.TileSize),
+		Y: int((p.Area.Miny + p.Area.Maxy) / 2 / config.TileSize),
+	}
+}
+
+// PortalAt returns the portal the tile is in
+func (l *Level) PortalAt(node utils.Node) (Portal, bool) {
+	for _, portal := range l.Portals {
+		if portal.Contains(node) {
+			return portal, true
+		}
+	}
+	return Portal{}, false
+}
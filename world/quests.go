@@ -0,0 +1,71 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/quest"
+	"bilydaniel/rpg/utils"
+	"math"
+)
+
+// AreasLayer is the Tiled object layer with the named areas quests can ask
+// the party to reach
+const AreasLayer = "areas"
+
+type Area struct {
+	Name string
+	Rect utils.RectangleCollision //world pixels
+}
+
+func AreaFromObject(object assets.Object) Area {
+	return Area{
+		Name: object.Name,
+		Rect: utils.RectangleCollision{
+			Minx: object.X,
+			Miny: object.Y,
+			Maxx: object.X + math.Max(object.Width, config.TileSize),
+			Maxy: object.Y + math.Max(object.Height, config.TileSize),
+		},
+	}
+}
+
+// Contains tells if the middle of the tile is inside of the area
+func (a Area) Contains(node utils.Node) bool {
+	return tileInRect(node, a.Rect)
+}
+
+// AreaByName returns nil when the level has no such area
+func (l *Level) AreaByName(name string) *Area {
+	for i := range l.Areas {
+		if l.Areas[i].Name == name {
+			return &l.Areas[i]
+		}
+	}
+	return nil
+}
+
+// Fire tells the quests about the event and keeps the news for the player
+func (w *World) Fire(event quest.Event) {
+	w.Notices = append(w.Notices, w.Journal.Fire(event)...)
+}
+
+// CheckAreas fires a reach event when the party steps into an area of the
+// current level, only again after everyone left it
+func (w *World) CheckAreas(pcharacters []*entities.PCharacter) {
+	if w.inAreas == nil {
+		w.inAreas = map[string]bool{}
+	}
+	for _, area := range w.CurrentLevel.Areas {
+		key := w.CurrentLevel.Name + "/" + area.Name
+		inside := false
+		for _, pchar := range pcharacters {
+			node := utils.Node{X: int(math.Round(pchar.GetX())), Y: int(math.Round(pchar.GetY()))}
+			inside = inside || area.Contains(node)
+		}
+		if inside && !w.inAreas[key] {
+			w.Fire(quest.Event{Kind: quest.Reach, Target: area.Name})
+		}
+		w.inAreas[key] = inside
+	}
+}
@@ -0,0 +1,38 @@
+package world
+
+import (
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type renderItem struct {
+	depth float64
+	order int
+	draw  func(target *ebiten.Image)
+}
+
+// RenderQueue draws everything that stands in the world back to front, the
+// depth is the base line (feet, bottom of a house) in world pixels
+type RenderQueue struct {
+	items []renderItem
+}
+
+// Submit adds a draw call, things with the same depth keep submission order
+func (q *RenderQueue) Submit(depth float64, draw func(target *ebiten.Image)) {
+	q.items = append(q.items, renderItem{depth: depth, order: len(q.items), draw: draw})
+}
+
+// Flush draws the queue onto the target and empties it
+func (q *RenderQueue) Flush(target *ebiten.Image) {
+	sort.Slice(q.items, func(i, j int) bool {
+		if q.items[i].depth != q.items[j].depth {
+			return q.items[i].depth < q.items[j].depth
+		}
+		return q.items[i].order < q.items[j].order
+	})
+	for _, item := range q.items {
+		item.draw(target)
+	}
+	q.items = q.items[:0]
+}
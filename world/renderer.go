@@ -0,0 +1,161 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/config"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// groundLayerName is the tile layer the walkability and terrain come from,
+// the one called "tiles" or the first tile layer of the map
+func groundLayerName(layers []assets.TilemapLayer) string {
+	first := ""
+	for _, layer := range flattenLayers(layers) {
+		if layer.Type != "tilelayer" {
+			continue
+		}
+		if layer.Name == "tiles" {
+			return layer.Name
+		}
+		if first == "" {
+			first = layer.Name
+		}
+	}
+	return first
+}
+
+// flattenLayers returns the layers with the groups replaced by their children
+func flattenLayers(layers []assets.TilemapLayer) []assets.TilemapLayer {
+	flat := []assets.TilemapLayer{}
+	for _, layer := range layers {
+		if layer.Type == "group" {
+			flat = append(flat, flattenLayers(layer.Layers)...)
+			continue
+		}
+		flat = append(flat, layer)
+	}
+	return flat
+}
+
+// TileImage resolves a global tile ID (flags already removed) to its image
+func (l *Level) TileImage(gameAssets *assets.Assets, gid int) *ebiten.Image {
+	tileset, id := l.TilesetForGID(gid)
+	if tileset == nil {
+		return nil
+	}
+	return gameAssets.GetTilesetTile(tileset.Data, id)
+}
+
+// applyFlip mirrors a tile image of the given size in place, Tiled applies the
+// diagonal flip first
+func applyFlip(geom *ebiten.GeoM, flags uint32, w, h float64) {
+	if flags&assets.FlipDiagonal != 0 {
+		swap := ebiten.GeoM{}
+		swap.SetElement(0, 0, 0)
+		swap.SetElement(0, 1, 1)
+		swap.SetElement(1, 0, 1)
+		swap.SetElement(1, 1, 0)
+		geom.Concat(swap)
+		w, h = h, w
+	}
+	if flags&assets.FlipHorizontal != 0 {
+		geom.Scale(-1, 1)
+		geom.Translate(w, 0)
+	}
+	if flags&assets.FlipVertical != 0 {
+		geom.Scale(1, -1)
+		geom.Translate(0, h)
+	}
+}
+
+type layerEntry struct {
+	Layer   assets.TilemapLayer
+	Opacity float64
+}
+
+// visibleLayers flattens the groups and multiplies their opacity in
+func visibleLayers(layers []assets.TilemapLayer, opacity float64) []layerEntry {
+	entries := []layerEntry{}
+	for _, layer := range layers {
+		if !layer.Visible {
+			continue
+		}
+		if layer.Type == "group" {
+			entries = append(entries, visibleLayers(layer.Layers, opacity*layer.Opacity)...)
+			continue
+		}
+		entries = append(entries, layerEntry{Layer: layer, Opacity: opacity * layer.Opacity})
+	}
+	return entries
+}
+
+// ObjectBaseline is the depth of a tile object, the lowest point of its
+// rotated image
+func ObjectBaseline(object assets.Object) float64 {
+	angle := object.Rotation * math.Pi / 180
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	baseline := object.Y
+	// corners relative to the bottom left anchor
+	for _, corner := range [][2]float64{{object.Width, 0}, {0, -object.Height}, {object.Width, -object.Height}} {
+		baseline = math.Max(baseline, object.Y+corner[0]*sin+corner[1]*cos)
+	}
+	return baseline
+}
+
+// submitObjectLayer puts the tile objects of the layer into the queue so they
+// get sorted together with the characters
+func (l *Level) submitObjectLayer(queue *RenderQueue, cam *config.Camera, gameAssets *assets.Assets, layer assets.TilemapLayer, opacity float64) {
+	objects := layer.Objects
+	if obstacles, ok := l.Obstacles[layer.Name]; ok {
+		// buildings can be added and removed while playing
+		objects = obstacles
+	}
+
+	for _, object := range objects {
+		image, opts, baseline := l.tileObjectDraw(gameAssets, cam, object)
+		if image == nil {
+			continue
+		}
+		opts.ColorScale.ScaleAlpha(float32(opacity))
+		queue.Submit(baseline, func(target *ebiten.Image) {
+			target.DrawImage(image, opts)
+		})
+	}
+}
+
+// tileObjectDraw returns the image of a tile object with the options that put
+// it on the screen and its baseline, nil image when there is nothing to draw
+func (l *Level) tileObjectDraw(gameAssets *assets.Assets, cam *config.Camera, object assets.Object) (*ebiten.Image, *ebiten.DrawImageOptions, float64) {
+	if !object.Visible {
+		return nil, nil, 0
+	}
+	gid, flags := assets.SplitGID(object.GID)
+	if gid == 0 {
+		//TODO shapes are only collisions for now, nothing to draw
+		return nil, nil, 0
+	}
+	image := l.TileImage(gameAssets, gid)
+	if image == nil {
+		return nil, nil, 0
+	}
+
+	bounds := image.Bounds()
+	width, height := object.Width, object.Height
+	if width == 0 || height == 0 {
+		width, height = float64(bounds.Dx()), float64(bounds.Dy())
+	}
+
+	opts := &ebiten.DrawImageOptions{}
+	applyFlip(&opts.GeoM, flags, float64(bounds.Dx()), float64(bounds.Dy()))
+	// tile objects are anchored at the bottom left and rotate around it
+	opts.GeoM.Scale(width/float64(bounds.Dx()), height/float64(bounds.Dy()))
+	opts.GeoM.Translate(0, -height)
+	opts.GeoM.Rotate(object.Rotation * math.Pi / 180)
+	cam.WorldToScreenGeom(opts, int(object.X), int(object.Y))
+
+	sized := object
+	sized.Width, sized.Height = width, height
+	return image, opts, ObjectBaseline(sized)
+}
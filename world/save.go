@@ -0,0 +1,225 @@
+package world
+
+import (
+	"bilydaniel/rpg/enemy"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/quest"
+	"bilydaniel/rpg/save"
+	"bilydaniel/rpg/utils"
+	"fmt"
+	"maps"
+	"sort"
+	"time"
+)
+
+const (
+	occupantPCharacter = "pcharacter"
+	occupantNpc        = "npc"
+)
+
+// Snapshot turns the world and the party into a save file
+func (w *World) Snapshot(pcharacters []*entities.PCharacter) save.File {
+	file := save.File{
+		Version:      save.Version,
+		SavedAt:      time.Now(),
+		CurrentLevel: w.CurrentLevel.Name,
+		ClockMinutes: w.Clock.Minutes,
+		Flags:        maps.Clone(w.Journal.Flags),
+		Quests:       w.Journal.Snapshot(),
+	}
+
+	refs := map[entities.Sprite][2]string{}
+	for _, pchar := range pcharacters {
+		refs[pchar] = [2]string{occupantPCharacter, pchar.Name}
+	}
+	for id, npc := range w.Npcs {
+		refs[npc] = [2]string{occupantNpc, id}
+	}
+	ref := func(sprite entities.Sprite) (string, string, bool) {
+		r, ok := refs[sprite]
+		return r[0], r[1], ok
+	}
+
+	names := []string{}
+	for name := range w.Levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file.Levels = append(file.Levels, w.Levels[name].State(ref))
+	}
+
+	for _, pchar := range pcharacters {
+		file.PCharacters = append(file.PCharacters, save.PCharacter{
+			Name:         pchar.Name,
+			X:            pchar.GetX(),
+			Y:            pchar.GetY(),
+			Selected:     pchar.Selected,
+			Path:         saveTiles(pchar.Path),
+			PathProgress: pchar.PathProgress,
+			Stats:        pchar.Stats.Clone(),
+			Inventory:    pchar.Inventory.Clone(),
+			Equipment:    pchar.Equipment.Clone(),
+		})
+	}
+
+	for _, id := range w.SortedNpcIDs() {
+		npc := w.Npcs[id]
+		file.Npcs = append(file.Npcs, save.Npc{
+			ID:           id,
+			LevelName:    npc.LevelName,
+			X:            npc.GetX(),
+			Y:            npc.GetY(),
+			Path:         saveTiles(npc.Path),
+			PathProgress: npc.PathProgress,
+			Movement:     npc.Movement,
+			Home:         save.Tile{X: npc.Home.X, Y: npc.Home.Y},
+			Stats:        npc.Stats.Clone(),
+		})
+	}
+	return file
+}
+
+// Restore throws the loaded levels away and rebuilds them from the map files
+// and the save, the party and the npcs get their saved state. Everything is
+// built on the side first, a save that cant be restored leaves the world as
+// it was.
+func (w *World) Restore(file save.File, pcharacters []*entities.PCharacter) error {
+	byName := map[string]*entities.PCharacter{}
+	for _, pchar := range pcharacters {
+		byName[pchar.Name] = pchar
+	}
+	for _, saved := range file.PCharacters {
+		if _, ok := byName[saved.Name]; !ok {
+			return fmt.Errorf("Save has unknown character %s", saved.Name)
+		}
+	}
+
+	// the npcs spawn again from the map files, the saved ones get their state
+	// and the ones that didnt exist yet when saving start fresh
+	staged := *w
+	staged.Levels = map[string]*Level{}
+	staged.Npcs = map[string]*entities.Npc{}
+	staged.Enemies = enemy.NewDirector(w.Rand.Int63(), w.Enemies.ThinkTicks, w.Enemies.AlertRadius)
+	for _, state := range file.Levels {
+		_, err := staged.GetLevel(state.Name)
+		if err != nil {
+			return err
+		}
+	}
+	current, err := staged.GetLevel(file.CurrentLevel)
+	if err != nil {
+		return err
+	}
+
+	for _, saved := range file.Npcs {
+		npc, ok := staged.Npcs[saved.ID]
+		if !ok {
+			return fmt.Errorf("Save has unknown npc %s", saved.ID)
+		}
+		npc.LevelName = saved.LevelName
+		npc.SetPosition(saved.X, saved.Y)
+		npc.SetPath(nodes(saved.Path))
+		npc.PathProgress = saved.PathProgress
+		npc.Movement = saved.Movement
+		npc.Home = utils.Node{X: saved.Home.X, Y: saved.Home.Y}
+		if saved.Stats != nil {
+			npc.Stats = saved.Stats.Clone()
+		}
+	}
+
+	resolve := func(kind string, id string) entities.Sprite {
+		switch kind {
+		case occupantPCharacter:
+			if pchar, ok := byName[id]; ok {
+				return pchar
+			}
+		case occupantNpc:
+			if npc, ok := staged.Npcs[id]; ok {
+				return npc
+			}
+		}
+		return nil
+	}
+	for _, state := range file.Levels {
+		err := staged.Levels[state.Name].RestoreState(state, resolve)
+		if err != nil {
+			return fmt.Errorf("Restoring level %s: %w", state.Name, err)
+		}
+	}
+
+	journal := quest.NewJournal(w.Journal.Definitions)
+	err = journal.Restore(file.Quests, file.Flags)
+	if err != nil {
+		return err
+	}
+	notices := []string{}
+	if file.Quests == nil {
+		// saved before there were quests
+		notices, err = journal.StartAuto()
+		if err != nil {
+			return err
+		}
+	}
+
+	// nothing can fail from here on
+	for _, pchar := range pcharacters {
+		pchar.LeaveLevel(w.CurrentLevel)
+		w.CurrentLevel.LightingSystem.Detach(pchar)
+	}
+	for _, saved := range file.PCharacters {
+		pchar := byName[saved.Name]
+		pchar.SetPosition(saved.X, saved.Y)
+		pchar.Selected = saved.Selected
+		pchar.SetPath(nodes(saved.Path))
+		pchar.PathProgress = saved.PathProgress
+		if saved.Stats != nil {
+			pchar.Stats = saved.Stats.Clone()
+		}
+		if saved.Inventory != nil {
+			pchar.Inventory = saved.Inventory.Clone()
+		}
+		if saved.Equipment != nil {
+			// the modifiers of the equipment came with the stats
+			pchar.Equipment = saved.Equipment.Clone()
+		}
+	}
+
+	w.Levels = staged.Levels
+	w.Npcs = staged.Npcs
+	w.Enemies = staged.Enemies
+	w.CurrentLevel = current
+	for _, pchar := range pcharacters {
+		current.LightingSystem.Attach(pchar, Torch())
+	}
+
+	w.Clock.Minutes = file.ClockMinutes
+	for _, npc := range w.Npcs {
+		// carry on with the behaviour of the saved hour without dropping the path
+		_, active := npc.BehaviourAt(w.Clock.Hour())
+		npc.State = entities.BehaviourState{Active: active}
+	}
+	w.Journal = journal
+	w.Notices = notices
+	w.Transition = nil
+	w.onPortal = map[entities.Sprite]bool{}
+	w.inAreas = nil
+	w.Enemies.Reset()
+	return nil
+}
+
+func saveTiles(path []utils.Node) []save.Tile {
+	tiles := []save.Tile{}
+	for _, node := range path {
+		tiles = append(tiles, save.Tile{X: node.X, Y: node.Y})
+	}
+	return tiles
+}
+
+func nodes(tiles []save.Tile) []utils.Node {
+	path := []utils.Node{}
+	for _, tile := range tiles {
+		path = append(path, utils.Node{X: tile.X, Y: tile.Y})
+	}
+	return path
+}
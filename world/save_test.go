@@ -0,0 +1,107 @@
+package world
+
+import (
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/items"
+	"bilydaniel/rpg/quest"
+	"bilydaniel/rpg/save"
+	"testing"
+)
+
+func testWorld(t *testing.T) (*World, []*entities.PCharacter) {
+	t.Helper()
+	toRepoRoot(t)
+	w, err := InitWorld(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	party := []*entities.PCharacter{testPCharacter(2, 2), testPCharacter(3, 2)}
+	party[1].Name = "blue"
+	for _, pchar := range party {
+		pchar.Inventory = items.NewInventory(config.InventorySlots, config.InventoryWeight)
+		w.CurrentLevel.SetTileOccupied(pchar, int(pchar.GetX()), int(pchar.GetY()))
+	}
+	return w, party
+}
+
+func TestRestoreRoundTrip(t *testing.T) {
+	w, party := testWorld(t)
+	file := w.Snapshot(party)
+
+	party[0].SetPosition(10, 10)
+	w.Clock.Minutes += 300
+	w.Journal.Flags["changed"] = 1
+	for _, npc := range w.Npcs {
+		npc.SetPosition(0, 0)
+	}
+
+	err := w.Restore(file, party)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if party[0].GetX() != 2 || party[0].GetY() != 2 {
+		t.Fatalf("Character at %v,%v", party[0].GetX(), party[0].GetY())
+	}
+	if w.Clock.Minutes != file.ClockMinutes {
+		t.Fatalf("Clock %v, want %v", w.Clock.Minutes, file.ClockMinutes)
+	}
+	if w.Journal.Flags["changed"] != 0 {
+		t.Fatal("Flag set after saving survived")
+	}
+	for _, saved := range file.Npcs {
+		npc := w.Npcs[saved.ID]
+		if npc.GetX() != saved.X || npc.GetY() != saved.Y {
+			t.Fatalf("Npc %s at %v,%v, want %v,%v", saved.ID, npc.GetX(), npc.GetY(), saved.X, saved.Y)
+		}
+	}
+	hostile := 0
+	for _, npc := range w.Npcs {
+		if npc.Hostile {
+			hostile++
+		}
+	}
+	if len(w.Enemies.Brains) != hostile {
+		t.Fatalf("%d brains for %d enemies", len(w.Enemies.Brains), hostile)
+	}
+	for id := range w.Enemies.Brains {
+		if _, ok := w.Npcs[id]; !ok {
+			t.Fatalf("Brain %s has no npc", id)
+		}
+	}
+	node := spriteNode(party[1])
+	if w.CurrentLevel.TileOccupant(&node) != entities.Sprite(party[1]) {
+		t.Fatal("Character doesnt occupy its tile")
+	}
+}
+
+func TestRestoreBrokenSave(t *testing.T) {
+	breaks := map[string]func(file *save.File){
+		"unknown character": func(file *save.File) { file.PCharacters[0].Name = "nobody" },
+		"unknown npc":       func(file *save.File) { file.Npcs = append(file.Npcs, save.Npc{ID: "level_1:9999"}) },
+		"missing level":     func(file *save.File) { file.CurrentLevel = "no_such_level" },
+		"unknown quest":     func(file *save.File) { file.Quests = append(file.Quests, quest.Progress{ID: "no_such_quest"}) },
+		"bad fog":           func(file *save.File) { file.Levels[0].Explored = []byte{1} },
+	}
+	for name, breakFile := range breaks {
+		w, party := testWorld(t)
+		file := w.Snapshot(party)
+		breakFile(&file)
+
+		party[0].SetPosition(5, 5)
+		w.Clock.Minutes += 60
+		level, npcs, journal, enemies := w.CurrentLevel, w.Npcs, w.Journal, w.Enemies
+		minutes := w.Clock.Minutes
+		npcCount := len(w.Npcs)
+
+		if err := w.Restore(file, party); err == nil {
+			t.Fatalf("%s: restored", name)
+		}
+		if w.CurrentLevel != level || w.Journal != journal || w.Enemies != enemies || len(w.Npcs) != npcCount || len(npcs) != npcCount {
+			t.Fatalf("%s: world was replaced", name)
+		}
+		if party[0].GetX() != 5 || party[0].GetY() != 5 || w.Clock.Minutes != minutes {
+			t.Fatalf("%s: state changed", name)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package world
+
+import (
+	"bilydaniel/rpg/assets"
+	"bilydaniel/rpg/utils"
+)
+
+type Terrain string
+
+const (
+	TerrainNone         Terrain = ""
+	TerrainRoad         Terrain = "road"
+	TerrainGrass        Terrain = "grass"
+	TerrainMud          Terrain = "mud"
+	TerrainShallowWater Terrain = "shallow water"
+	TerrainWater        Terrain = "water"
+)
+
+// TerrainInfo is what a tile from a tileset brings to the level, read from the
+// Tiled custom properties "terrain", "cost" and "walkable"
+type TerrainInfo struct {
+	Terrain  Terrain
+	Cost     float64
+	Walkable bool
+}
+
+// default movement costs when the tile has a terrain but no cost property,
+// cost 1 is normal speed
+var terrainCosts = map[Terrain]float64{
+	TerrainNone:         1,
+	TerrainRoad:         0.75,
+	TerrainGrass:        1,
+	TerrainMud:          2,
+	TerrainShallowWater: 3,
+	TerrainWater:        1,
+}
+
+func DefaultTerrainInfo() TerrainInfo {
+	return TerrainInfo{Terrain: TerrainNone, Cost: 1, Walkable: true}
+}
+
+func TerrainInfoFromProperties(properties []assets.Property) TerrainInfo {
+	info := DefaultTerrainInfo()
+
+	if terrain, ok := assets.PropertyString(properties, "terrain"); ok {
+		info.Terrain = Terrain(terrain)
+		if cost, ok := terrainCosts[info.Terrain]; ok {
+			info.Cost = cost
+		}
+		info.Walkable = info.Terrain != TerrainWater
+	}
+	if cost, ok := assets.PropertyFloat(properties, "cost"); ok && cost > 0 {
+		info.Cost = cost
+	}
+	if walkable, ok := assets.PropertyBool(properties, "walkable"); ok {
+		info.Walkable = walkable
+	}
+	return info
+}
+
+// MovementCost of the tile, 1 outside of the level
+func (l *Level) MovementCost(node *utils.Node) float64 {
+	if !l.InBounds(node.X, node.Y) {
+		return 1
+	}
+	return l.Grid[node.Y][node.X].Cost
+}
@@ -0,0 +1,96 @@
+package world
+
+import (
+	"bilydaniel/rpg/utils"
+	"slices"
+	"testing"
+)
+
+func TestFloorTerrains(t *testing.T) {
+	level := loadLevel(t, "level_1")
+	found := map[Terrain]bool{}
+	for _, info := range level.Terrains {
+		found[info.Terrain] = true
+		if info.Terrain == TerrainWater && info.Walkable {
+			t.Fatal("Deep water is walkable")
+		}
+	}
+	for _, terrain := range []Terrain{TerrainRoad, TerrainGrass, TerrainMud, TerrainShallowWater, TerrainWater} {
+		if !found[terrain] {
+			t.Fatalf("No floor tile has terrain %q", terrain)
+		}
+	}
+}
+
+// terrainLevel has two equally long ways round a wall, the terrain above and
+// grass below
+func terrainLevel(top Terrain) *Level {
+	level := openLevel(9, 3)
+	for x := 0; x < 9; x++ {
+		level.Grid[0][x].Terrain, level.Grid[0][x].Cost = top, terrainCosts[top]
+		level.Grid[2][x].Terrain, level.Grid[2][x].Cost = TerrainGrass, terrainCosts[TerrainGrass]
+		if x > 0 && x < 8 {
+			level.Grid[1][x].Walkable = false
+		}
+	}
+	return level
+}
+
+func TestPathPrefersRoad(t *testing.T) {
+	level := terrainLevel(TerrainRoad)
+	grid := level.NavGrid()
+	pf := &PathFinder{}
+	for _, mode := range []PathMode{PathAStar, PathSmoothed, PathThetaStar} {
+		path := pf.FindPath(grid, utils.Node{X: 0, Y: 1}, utils.Node{X: 8, Y: 1}, mode, nil)
+		if path == nil {
+			t.Fatalf("Mode %d found no path", mode)
+		}
+		for _, node := range path {
+			if node.Y == 2 {
+				t.Fatalf("Mode %d walks on the grass %v", mode, path)
+			}
+		}
+	}
+
+	// with the road turned to mud the grass wins
+	path := pf.FindPath(terrainLevel(TerrainMud).NavGrid(), utils.Node{X: 0, Y: 1}, utils.Node{X: 8, Y: 1}, PathAStar, nil)
+	if !slices.Contains(path, utils.Node{X: 4, Y: 2}) {
+		t.Fatalf("Walks through the mud %v", path)
+	}
+}
+
+func TestTerrainSpeed(t *testing.T) {
+	// ticks to walk along a row of the terrain
+	walk := func(terrain Terrain) int {
+		level := openLevel(10, 1)
+		for x := 0; x < 10; x++ {
+			level.Grid[0][x].Terrain, level.Grid[0][x].Cost = terrain, terrainCosts[terrain]
+		}
+		pchar := testPCharacter(0, 0)
+		path := []utils.Node{}
+		for x := 1; x < 10; x++ {
+			path = append(path, utils.Node{X: x, Y: 0})
+		}
+		pchar.SetPath(path)
+		for tick := 1; tick < 10000; tick++ {
+			pchar.Update(level)
+			if len(pchar.Path) == 0 {
+				return tick
+			}
+		}
+		t.Fatalf("Never got over the %s", terrain)
+		return 0
+	}
+
+	grass := walk(TerrainGrass)
+	road := walk(TerrainRoad)
+	mud := walk(TerrainMud)
+	if road >= grass || grass >= mud {
+		t.Fatalf("Road %d, grass %d, mud %d ticks", road, grass, mud)
+	}
+	// roughly in the ratio of the costs, the last step of each tile rounds
+	ratio := float64(mud) / float64(grass)
+	if ratio < 1.7 || ratio > 2.3 {
+		t.Fatalf("Mud takes %.2f times as long as grass", ratio)
+	}
+}
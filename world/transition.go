@@ -0,0 +1,193 @@
+package world
+
+import (
+	"bilydaniel/rpg/entities"
+	"bilydaniel/rpg/utils"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// ticks the fade out and the fade in take each
+const transitionTicks = 30
+
+// LevelTransition is the party going through a portal, the screen fades out,
+// the level switches and the screen fades back in
+type LevelTransition struct {
+	Portal   Portal
+	Ticks    int
+	Switched bool
+}
+
+// GetLevel returns the level, loading it the first time it is needed, loaded
+// levels stay in memory with their state
+func (w *World) GetLevel(name string) (*Level, error) {
+	if level, ok := w.Levels[name]; ok {
+		return level, nil
+	}
+	level := InitLevel()
+	err := level.LoadLevel(name)
+	if err != nil {
+		return nil, err
+	}
+	w.Levels[name] = &level
+	err = w.spawnNpcs(&level)
+	if err != nil {
+		return nil, err
+	}
+	return &level, nil
+}
+
+// CheckPortals starts a transition when one of the characters steps on a
+// portal, standing on it since the last transition doesnt count
+func (w *World) CheckPortals(pcharacters []*entities.PCharacter) {
+	if w.Transition != nil {
+		return
+	}
+	for _, pchar := range pcharacters {
+		node := utils.Node{X: int(math.Round(pchar.GetX())), Y: int(math.Round(pchar.GetY()))}
+		portal, ok := w.CurrentLevel.PortalAt(node)
+		if !ok {
+			delete(w.onPortal, pchar)
+			continue
+		}
+		if w.onPortal[pchar] {
+			continue
+		}
+		w.onPortal[pchar] = true
+		if portal.TargetLevel == "" {
+			continue
+		}
+		w.Transition = &LevelTransition{Portal: portal}
+		return
+	}
+}
+
+// UpdateTransition moves the transition along, it returns true on the tick
+// the party arrives to the new level
+func (w *World) UpdateTransition(pcharacters []*entities.PCharacter) (bool, error) {
+	if w.Transition == nil {
+		return false, nil
+	}
+	w.Transition.Ticks++
+	if w.Transition.Ticks < transitionTicks {
+		return false, nil
+	}
+
+	if !w.Transition.Switched {
+		w.Transition.Switched = true
+		w.Transition.Ticks = 0
+		err := w.MoveParty(pcharacters, w.Transition.Portal)
+		if err != nil {
+			w.Transition = nil
+			return false, err
+		}
+		return true, nil
+	}
+
+	w.Transition = nil
+	return false, nil
+}
+
+// MoveParty puts the characters around the target portal and makes its level
+// the current one
+func (w *World) MoveParty(pcharacters []*entities.PCharacter, portal Portal) error {
+	target, arrival, err := w.portalTarget(portal)
+	if err != nil {
+		return err
+	}
+
+	taken := map[utils.Node]bool{}
+	nodes := []utils.Node{}
+	for _, pchar := range pcharacters {
+		node, ok := target.freeTileNear(arrival.Center(), taken)
+		if !ok {
+			return fmt.Errorf("Portal %s has no free tile for %s", arrival.Name, pchar.Name)
+		}
+		taken[node] = true
+		nodes = append(nodes, node)
+	}
+	for i, pchar := range pcharacters {
+		pchar.LeaveLevel(w.CurrentLevel)
+		w.CurrentLevel.LightingSystem.Detach(pchar)
+
+		node := nodes[i]
+		pchar.SetPosition(float64(node.X), float64(node.Y))
+		target.SetTileOccupied(pchar, node.X, node.Y)
+		target.LightingSystem.Attach(pchar, Torch())
+		// arriving on the portal doesnt send them right back
+		w.onPortal[pchar] = arrival.Contains(node)
+	}
+	w.CurrentLevel = target
+	return nil
+}
+
+// MoveNpc sends an NPC through the portal on its own, no fading
+func (w *World) MoveNpc(npc *entities.Npc, portal Portal) error {
+	target, arrival, err := w.portalTarget(portal)
+	if err != nil {
+		return err
+	}
+	node, ok := target.freeTileNear(arrival.Center(), map[utils.Node]bool{})
+	if !ok {
+		// the arrival is crowded, the npc tries again later
+		return nil
+	}
+	if from, ok := w.Levels[npc.LevelName]; ok {
+		npc.LeaveLevel(from)
+	}
+
+	npc.SetPosition(float64(node.X), float64(node.Y))
+	npc.SetPath(nil)
+	npc.Home = node
+	npc.LevelName = target.Name
+	target.SetTileOccupied(npc, node.X, node.Y)
+	w.onPortal[npc] = arrival.Contains(node)
+	return nil
+}
+
+// CheckNpcPortal moves the NPC when it stepped on a portal of its level
+func (w *World) CheckNpcPortal(npc *entities.Npc) error {
+	level, ok := w.Levels[npc.LevelName]
+	if !ok {
+		return nil
+	}
+	node := utils.Node{X: int(math.Round(npc.GetX())), Y: int(math.Round(npc.GetY()))}
+	portal, ok := level.PortalAt(node)
+	if !ok {
+		delete(w.onPortal, npc)
+		return nil
+	}
+	if w.onPortal[npc] || portal.TargetLevel == "" {
+		return nil
+	}
+	return w.MoveNpc(npc, portal)
+}
+
+func (w *World) portalTarget(portal Portal) (*Level, Portal, error) {
+	target, err := w.GetLevel(portal.TargetLevel)
+	if err != nil {
+		return nil, Portal{}, err
+	}
+	arrival, ok := target.Portals[portal.TargetPortal]
+	if !ok {
+		return nil, Portal{}, fmt.Errorf("Level %s has no portal %s", portal.TargetLevel, portal.TargetPortal)
+	}
+	return target, arrival, nil
+}
+
+// DrawTransition darkens the screen while fading
+func (w *World) DrawTransition(screen *ebiten.Image) {
+	if w.Transition == nil {
+		return
+	}
+	alpha := float64(w.Transition.Ticks) / transitionTicks
+	if w.Transition.Switched {
+		alpha = 1 - alpha
+	}
+	bounds := screen.Bounds()
+	vector.DrawFilledRect(screen, 0, 0, float32(bounds.Dx()), float32(bounds.Dy()), color.RGBA{A: uint8(alpha * 255)}, false)
+}
@@ -1,58 +1,74 @@
 package world
 
 import (
+	"bilydaniel/rpg/config"
+	"bilydaniel/rpg/dialogue"
+	"bilydaniel/rpg/enemy"
 	"bilydaniel/rpg/entities"
-	"strconv"
-
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"bilydaniel/rpg/items"
+	"bilydaniel/rpg/quest"
+	"bilydaniel/rpg/stats"
+	"math/rand"
 )
 
 type World struct {
-	//TODO level switching for player and npc
-	CurrentLevel *Level
-	Levels       map[string]*Level
-	Npcs         map[string]*entities.Npc
+	CurrentLevel   *Level
+	Levels         map[string]*Level //loaded levels, they stay loaded to keep their state
+	Npcs           map[string]*entities.Npc
+	Clock          *GameClock
+	Transition     *LevelTransition
+	onPortal       map[entities.Sprite]bool //standing on a portal since the last check
+	inAreas        map[string]bool          //level/area the party is in since the last check
+	Classes        map[string]stats.Class
+	Items          items.Catalog
+	Dialogues      map[string]dialogue.Dialogue
+	Journal        *quest.Journal
+	Notices        []string   //quest news for the player, taken by the ui
+	Rand           *rand.Rand //for npc decisions
+	Enemies        *enemy.Director
+	engageCooldown int
 }
 
-func InitWorld() (*World, error) {
-	currentLevel := InitLevel()
-	world := World{
-		CurrentLevel: &currentLevel,
-		Levels:       map[string]*Level{},
+// InitWorld loads the data files and the first level, the npc decisions come
+// from the seed
+func InitWorld(seed int64) (*World, error) {
+	classes, err := stats.LoadClasses(stats.ClassesPath)
+	if err != nil {
+		return nil, err
 	}
-
-	err := currentLevel.LoadLevel("level_1")
+	catalog, err := items.LoadCatalog(items.ItemsPath)
 	if err != nil {
 		return nil, err
 	}
-
-	world.Levels[world.CurrentLevel.Name] = &currentLevel
-	npcs := map[string]*entities.Npc{}
-	//TODO put into assets
-	image, _, err := ebitenutil.NewImageFromFile("assets/images/greenchar.png")
+	dialogues, err := dialogue.Load(dialogue.DialoguesPath)
+	if err != nil {
+		return nil, err
+	}
+	quests, err := quest.Load(quest.QuestsPath)
+	if err != nil {
+		return nil, err
+	}
+	world := World{
+		Levels:    map[string]*Level{},
+		Npcs:      map[string]*entities.Npc{},
+		Clock:     NewGameClock(18),
+		onPortal:  map[entities.Sprite]bool{},
+		Classes:   classes,
+		Items:     catalog,
+		Dialogues: dialogues,
+		Journal:   quest.NewJournal(quests),
+		Rand:      rand.New(rand.NewSource(seed)),
+	}
+	world.Enemies = enemy.NewDirector(world.Rand.Int63(), config.EnemyThinkTicks, config.EnemyAlertRadius)
+	world.Notices, err = world.Journal.StartAuto()
 	if err != nil {
 		return nil, err
 	}
 
-	for i := 0; i < 100; i++ {
-		id := strconv.Itoa(i)
-		npc := &entities.Npc{
-			Sprite: &entities.CircleSprite{
-				X:   float64(i),
-				Y:   float64(i),
-				Img: image,
-			},
-			Character: entities.Character{
-				Speed:    1.0 / 30,
-				Movement: 1.0 / 30,
-			},
-		}
-		world.CurrentLevel.Occupancy[i][i] = *npc
-
-		npcs[id] = npc
-
+	world.CurrentLevel, err = world.GetLevel("level_1")
+	if err != nil {
+		return nil, err
 	}
-	world.Npcs = npcs
 
 	return &world, nil
 }